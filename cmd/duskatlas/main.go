@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/WhoBrokeTheBuild/GoDusk/dusk"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: duskatlas [options] IMAGE...\n\n")
+	fmt.Fprintf(os.Stderr, "Packs the images into OUTPUT.png, and writes their regions to OUTPUT.json.\n\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	output := flag.String("o", "atlas", "output filename, without extension")
+	maxSize := flag.Int("size", 4096, "maximum width and height of the atlas")
+	padding := flag.Int("padding", 2, "pixels between images")
+	trim := flag.Bool("trim", true, "name regions by filename without directory or extension")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Images are read from disk, no GL context is needed to pack them
+	app := &dusk.App{
+		AssetFunction: dusk.AssetFromFile,
+	}
+
+	builder := dusk.NewAtlasBuilder(*maxSize, *padding)
+	for _, filename := range flag.Args() {
+		if err := builder.AddFile(app, filename); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	rgba, regions, err := builder.Pack()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if *trim {
+		trimmed := map[string]dusk.AtlasRegion{}
+		for name, region := range regions {
+			base := path.Base(filepath.ToSlash(name))
+			trimmed[strings.TrimSuffix(base, path.Ext(base))] = region
+		}
+		if len(trimmed) != len(regions) {
+			fmt.Fprintf(os.Stderr, "Images with the same name in different directories, use -trim=false\n")
+			os.Exit(1)
+		}
+		regions = trimmed
+	}
+
+	imageFile, err := os.Create(*output + ".png")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	err = png.Encode(imageFile, rgba)
+	imageFile.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	manifestFile, err := os.Create(*output + ".json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	err = dusk.WriteAtlasManifest(manifestFile, path.Base(*output)+".png", rgba.Rect.Dx(), rgba.Rect.Dy(), regions)
+	manifestFile.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Packed %v images into %vx%v\n", len(regions), rgba.Rect.Dx(), rgba.Rect.Dy())
+}
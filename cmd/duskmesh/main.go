@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/WhoBrokeTheBuild/GoDusk/dusk"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: duskmesh [options] MODEL...\n\n")
+	fmt.Fprintf(os.Stderr, "Converts OBJ, glTF, PLY and STL models to the %v mesh cache format, next to each input.\n", dusk.MESH_CACHE_EXT)
+	fmt.Fprintf(os.Stderr, "Texture paths are stored as loaded, so run it from the directory the game loads assets from.\n\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	output := flag.String("o", "", "output filename, only with a single model")
+	embed := flag.Bool("embed", false, "store textures in the mesh cache")
+	crease := flag.Float64("crease", 0, "crease angle in degrees for models without normals")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 || (*output != "" && flag.NArg() > 1) {
+		usage()
+		os.Exit(2)
+	}
+
+	// Models are read from disk, no GL context is needed to convert them
+	app := &dusk.App{
+		AssetFunction: dusk.AssetFromFile,
+		CreaseAngle:   float32(*crease * math.Pi / 180),
+	}
+
+	for _, filename := range flag.Args() {
+		mesh, err := dusk.LoadMeshData(app, filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if *embed {
+			if err := embedTextures(app, mesh); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		outFilename := *output
+		if outFilename == "" {
+			outFilename = strings.TrimSuffix(filename, path.Ext(filename)) + dusk.MESH_CACHE_EXT
+		}
+
+		file, err := os.Create(outFilename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		err = dusk.WriteMeshCache(file, mesh)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Wrote %v, %v vertices, %v triangles, %v submeshes\n",
+			outFilename, mesh.VertexCount(), mesh.TriangleCount(), len(mesh.Submeshes))
+	}
+}
+
+// embedTextures reads the textures that materials refer to into their maps
+func embedTextures(app *dusk.App, mesh *dusk.MeshData) error {
+	for _, def := range mesh.Materials {
+		for _, texMap := range def.TextureMaps() {
+			if texMap.Filename == "" || texMap.Data != nil {
+				continue
+			}
+			data, err := app.AssetFunction(texMap.Filename)
+			if err != nil {
+				return err
+			}
+			texMap.Data = data
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/WhoBrokeTheBuild/GoDusk/dusk"
+)
+
+// Matches the line numbers in the info logs of the common drivers
+//
+//	Mesa:        0:12(5): error: ...
+//	NVIDIA:      0(12) : error C0000: ...
+//	AMD / Intel: ERROR: 0:12: ...
+var logLineRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*\d+:(\d+)\(\d+\):\s*(.*)$`),
+	regexp.MustCompile(`^\s*\d+\((\d+)\)\s*:\s*(.*)$`),
+	regexp.MustCompile(`^\s*(?:ERROR|WARNING):\s*\d+:(\d+):\s*(.*)$`),
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: duskshader [options] FILE.glsl...\n\n")
+	fmt.Fprintf(os.Stderr, "Files that share a name, such as default.vs.glsl and default.fs.glsl, are linked together.\n\n")
+	flag.PrintDefaults()
+}
+
+// programName strips the stage suffix, so all stages of a program group together
+func programName(filename string) string {
+	name := strings.TrimSuffix(filename, ".glsl")
+	if ext := path.Ext(name); ext != "" {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// printLog prints each line of an info log prefixed with filename:line where possible
+func printLog(filename string, log string) {
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		matched := false
+		for _, re := range logLineRegexps {
+			if m := re.FindStringSubmatch(line); m != nil {
+				fmt.Fprintf(os.Stderr, "%v:%v: %v\n", filename, m[1], m[2])
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", filename, line)
+		}
+	}
+}
+
+func main() {
+	runtime.LockOSThread()
+
+	verbose := flag.Bool("v", false, "print every program that validates")
+	noLink := flag.Bool("c", false, "compile each file separately, without linking")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	programs := map[string][]string{}
+	for _, filename := range flag.Args() {
+		if _, err := dusk.GetShaderType(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+
+		name := programName(filename)
+		if *noLink {
+			name = filename
+		}
+		programs[name] = append(programs[name], filename)
+	}
+
+	names := []string{}
+	for name := range programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	app, err := dusk.NewOffscreenApp()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	failed := 0
+	for _, name := range names {
+		filenames := programs[name]
+
+		shader, err := dusk.NewShader(&app, filenames...)
+		if err != nil {
+			failed++
+			if shaderErr, ok := err.(*dusk.ShaderError); ok {
+				if shaderErr.Linking {
+					printLog(strings.Join(shaderErr.Filenames, ","), shaderErr.Log)
+				} else {
+					printLog(shaderErr.Filenames[0], shaderErr.Log)
+				}
+			} else {
+				fmt.Fprintf(os.Stderr, "%v: %v\n", name, err)
+			}
+			continue
+		}
+		shader.Cleanup()
+
+		if *verbose {
+			fmt.Printf("%v: OK\n", strings.Join(filenames, ","))
+		}
+	}
+
+	app.Cleanup()
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
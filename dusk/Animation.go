@@ -0,0 +1,361 @@
+package dusk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// keyframe returns the keyframe before time and how far time is towards the next one. Times
+// outside the sampler's range are clamped to its first or last keyframe.
+func (sampler *AnimationSampler) keyframe(time float32) (int, float32) {
+	last := len(sampler.Times) - 1
+	if last <= 0 || time <= sampler.Times[0] {
+		return 0, 0
+	}
+	if time >= sampler.Times[last] {
+		return last, 0
+	}
+
+	next := sort.Search(len(sampler.Times), func(i int) bool {
+		return sampler.Times[i] > time
+	})
+	key := next - 1
+	span := sampler.Times[next] - sampler.Times[key]
+	if span <= 0 {
+		return key, 0
+	}
+	return key, (time - sampler.Times[key]) / span
+}
+
+// value returns the components of a keyframe, skipping the tangents of cubic splines
+func (sampler *AnimationSampler) value(key int) []float32 {
+	c := sampler.Components
+	if sampler.Interpolation == INTERPOLATION_CUBICSPLINE {
+		return sampler.Values[(key*3+1)*c : (key*3+2)*c]
+	}
+	return sampler.Values[key*c : (key+1)*c]
+}
+
+// Sample writes the sampler's value at time to out, which needs Components floats
+func (sampler *AnimationSampler) Sample(time float32, out []float32) {
+	c := sampler.Components
+	if len(sampler.Times) == 0 || c == 0 {
+		return
+	}
+
+	key, t := sampler.keyframe(time)
+	if t == 0 || sampler.Interpolation == INTERPOLATION_STEP {
+		copy(out, sampler.value(key))
+		return
+	}
+
+	from := sampler.value(key)
+	to := sampler.value(key + 1)
+	if sampler.Interpolation != INTERPOLATION_CUBICSPLINE {
+		for i := 0; i < c; i++ {
+			out[i] = from[i] + (to[i]-from[i])*t
+		}
+		return
+	}
+
+	// Hermite spline from this keyframe's out-tangent to the next one's in-tangent, which are
+	// scaled by the time between them
+	dt := sampler.Times[key+1] - sampler.Times[key]
+	outTangent := sampler.Values[(key*3+2)*c : (key*3+3)*c]
+	inTangent := sampler.Values[(key+1)*3*c : ((key+1)*3+1)*c]
+	t2 := t * t
+	t3 := t2 * t
+	for i := 0; i < c; i++ {
+		out[i] = (2*t3-3*t2+1)*from[i] +
+			(t3-2*t2+t)*dt*outTangent[i] +
+			(-2*t3+3*t2)*to[i] +
+			(t3-t2)*dt*inTangent[i]
+	}
+}
+
+// SampleRotation returns the sampler's rotation at time. Linear rotations are interpolated along
+// the sphere, and the others are normalized after sampling.
+func (sampler *AnimationSampler) SampleRotation(time float32) mgl32.Quat {
+	if sampler.Components != 4 || len(sampler.Times) == 0 {
+		return mgl32.QuatIdent()
+	}
+
+	if sampler.Interpolation == INTERPOLATION_LINEAR {
+		if key, t := sampler.keyframe(time); t > 0 {
+			return mgl32.QuatSlerp(samplerQuat(sampler.value(key)), samplerQuat(sampler.value(key+1)), t)
+		}
+	}
+
+	var value [4]float32
+	sampler.Sample(time, value[:])
+	return samplerQuat(value[:]).Normalize()
+}
+
+// samplerQuat converts a rotation from the x, y, z, w order it's stored in
+func samplerQuat(v []float32) mgl32.Quat {
+	return mgl32.Quat{W: v[3], V: mgl32.Vec3{v[0], v[1], v[2]}}
+}
+
+// Pose is a translation, rotation and scale for each node of a mesh
+type Pose struct {
+	Translations []mgl32.Vec3
+	Rotations    []mgl32.Quat
+	Scales       []mgl32.Vec3
+}
+
+// NewPose returns the rest pose of mesh's nodes
+func NewPose(mesh *MeshData) *Pose {
+	pose := &Pose{
+		Translations: make([]mgl32.Vec3, len(mesh.Nodes)),
+		Rotations:    make([]mgl32.Quat, len(mesh.Nodes)),
+		Scales:       make([]mgl32.Vec3, len(mesh.Nodes)),
+	}
+	pose.Reset(mesh)
+	return pose
+}
+
+// Reset returns every node to its rest pose
+func (pose *Pose) Reset(mesh *MeshData) {
+	for i := range mesh.Nodes {
+		node := &mesh.Nodes[i]
+		pose.Translations[i] = node.Translation
+		pose.Rotations[i] = node.Rotation
+		pose.Scales[i] = node.Scale
+	}
+}
+
+// Copy replaces the pose with other, which must be for the same mesh
+func (pose *Pose) Copy(other *Pose) {
+	copy(pose.Translations, other.Translations)
+	copy(pose.Rotations, other.Rotations)
+	copy(pose.Scales, other.Scales)
+}
+
+// Blend moves the pose towards other by t, from 0 for none of it to 1 for all of it
+func (pose *Pose) Blend(other *Pose, t float32) {
+	for i := range pose.Translations {
+		pose.Translations[i] = pose.Translations[i].Add(other.Translations[i].Sub(pose.Translations[i]).Mul(t))
+		pose.Rotations[i] = mgl32.QuatSlerp(pose.Rotations[i], other.Rotations[i], t)
+		pose.Scales[i] = pose.Scales[i].Add(other.Scales[i].Sub(pose.Scales[i]).Mul(t))
+	}
+}
+
+// LocalTransform returns a node's transform relative to its parent
+func (pose *Pose) LocalTransform(index int) mgl32.Mat4 {
+	t := pose.Translations[index]
+	s := pose.Scales[index]
+	return mgl32.Translate3D(t[0], t[1], t[2]).
+		Mul4(pose.Rotations[index].Mat4()).
+		Mul4(mgl32.Scale3D(s[0], s[1], s[2]))
+}
+
+// WorldTransforms returns the transform of every node relative to the scene root
+func (pose *Pose) WorldTransforms(mesh *MeshData) []mgl32.Mat4 {
+	world := make([]mgl32.Mat4, len(mesh.Nodes))
+	done := make([]bool, len(mesh.Nodes))
+
+	// Parents may come after their children, so each node resolves its parent first
+	var resolve func(index int) mgl32.Mat4
+	resolve = func(index int) mgl32.Mat4 {
+		if done[index] {
+			return world[index]
+		}
+		done[index] = true
+		world[index] = pose.LocalTransform(index)
+		if parent := mesh.Nodes[index].Parent; parent >= 0 && parent < len(mesh.Nodes) {
+			world[index] = resolve(parent).Mul4(world[index])
+		}
+		return world[index]
+	}
+	for i := range mesh.Nodes {
+		resolve(i)
+	}
+	return world
+}
+
+// Apply sets the nodes the animation moves to where they are at time. Morph target weights
+// aren't supported, and are ignored.
+func (anim *MeshAnimation) Apply(pose *Pose, time float32) {
+	var value [3]float32
+	for _, channel := range anim.Channels {
+		if channel.Node < 0 || channel.Node >= len(pose.Translations) ||
+			channel.Sampler < 0 || channel.Sampler >= len(anim.Samplers) {
+			continue
+		}
+		sampler := &anim.Samplers[channel.Sampler]
+
+		switch channel.Path {
+		case ANIMATION_TRANSLATION:
+			if sampler.Components == 3 {
+				sampler.Sample(time, value[:])
+				pose.Translations[channel.Node] = value
+			}
+		case ANIMATION_ROTATION:
+			if sampler.Components == 4 {
+				pose.Rotations[channel.Node] = sampler.SampleRotation(time)
+			}
+		case ANIMATION_SCALE:
+			if sampler.Components == 3 {
+				sampler.Sample(time, value[:])
+				pose.Scales[channel.Node] = value
+			}
+		}
+	}
+}
+
+// AnimationTrack is an animation being played by an Animator
+type AnimationTrack struct {
+	Animation *MeshAnimation
+	Time      float32
+	Speed     float32
+	Loop      bool
+
+	// Weight is how much the track counts towards the pose, relative to the other tracks
+	Weight float32
+
+	// fadeRate changes Weight each second, until it's 1 or the track is removed at 0
+	fadeRate float32
+	pose     *Pose
+}
+
+// Duration returns the length of the track's animation
+func (track *AnimationTrack) Duration() float32 {
+	return track.Animation.Duration()
+}
+
+// Finished returns true when a track that doesn't loop has reached its end
+func (track *AnimationTrack) Finished() bool {
+	return !track.Loop && track.Time >= track.Duration()
+}
+
+// Animator plays and blends a mesh's animations into a Pose, which Apply gives to a Model
+type Animator struct {
+	Mesh   *MeshData
+	Pose   *Pose
+	Tracks []*AnimationTrack
+}
+
+func NewAnimator(mesh *MeshData) *Animator {
+	return &Animator{
+		Mesh: mesh,
+		Pose: NewPose(mesh),
+	}
+}
+
+// Animation returns the mesh's animation with name, or nil
+func (animator *Animator) Animation(name string) *MeshAnimation {
+	for i := range animator.Mesh.Animations {
+		if animator.Mesh.Animations[i].Name == name {
+			return &animator.Mesh.Animations[i]
+		}
+	}
+	return nil
+}
+
+// Play starts an animation and fades out the others over fade seconds, replacing them at once
+// if fade is 0
+func (animator *Animator) Play(name string, loop bool, fade float32) (*AnimationTrack, error) {
+	anim := animator.Animation(name)
+	if anim == nil {
+		return nil, fmt.Errorf("Unknown animation '%v'", name)
+	}
+
+	track := animator.newTrack(anim, loop)
+	if fade <= 0 {
+		animator.Tracks = nil
+		track.Weight = 1
+	} else {
+		for _, other := range animator.Tracks {
+			other.fadeRate = -1 / fade
+		}
+		track.fadeRate = 1 / fade
+	}
+	animator.Tracks = append(animator.Tracks, track)
+	return track, nil
+}
+
+// Blend starts an animation alongside the others, with weight relative to theirs
+func (animator *Animator) Blend(name string, loop bool, weight float32) (*AnimationTrack, error) {
+	anim := animator.Animation(name)
+	if anim == nil {
+		return nil, fmt.Errorf("Unknown animation '%v'", name)
+	}
+
+	track := animator.newTrack(anim, loop)
+	track.Weight = weight
+	animator.Tracks = append(animator.Tracks, track)
+	return track, nil
+}
+
+// Stop removes every track, leaving the pose as it is
+func (animator *Animator) Stop() {
+	animator.Tracks = nil
+}
+
+func (animator *Animator) newTrack(anim *MeshAnimation, loop bool) *AnimationTrack {
+	return &AnimationTrack{
+		Animation: anim,
+		Speed:     1,
+		Loop:      loop,
+		pose:      NewPose(animator.Mesh),
+	}
+}
+
+// Update advances the tracks by dt seconds and blends them into the pose. Nodes that no track
+// animates are left at rest.
+func (animator *Animator) Update(dt float32) {
+	tracks := animator.Tracks[:0]
+	for _, track := range animator.Tracks {
+		track.Time += dt * track.Speed
+		duration := track.Duration()
+		if track.Loop && duration > 0 {
+			track.Time = float32(math.Mod(float64(track.Time), float64(duration)))
+			if track.Time < 0 {
+				track.Time += duration
+			}
+		} else {
+			track.Time = mgl32.Clamp(track.Time, 0, duration)
+		}
+
+		if track.fadeRate != 0 {
+			track.Weight += track.fadeRate * dt
+			if track.Weight >= 1 {
+				track.Weight = 1
+				track.fadeRate = 0
+			}
+			// Tracks fading in start at 0, only those fading out are done there
+			if track.fadeRate < 0 && track.Weight <= 0 {
+				continue
+			}
+		}
+		tracks = append(tracks, track)
+	}
+	animator.Tracks = tracks
+
+	// Each track is blended in by its share of the weight so far, which gives every track its
+	// share of the total
+	animator.Pose.Reset(animator.Mesh)
+	total := float32(0)
+	for _, track := range animator.Tracks {
+		if track.Weight <= 0 {
+			continue
+		}
+		track.pose.Reset(animator.Mesh)
+		track.Animation.Apply(track.pose, track.Time)
+
+		total += track.Weight
+		if total == track.Weight {
+			animator.Pose.Copy(track.pose)
+		} else {
+			animator.Pose.Blend(track.pose, track.Weight/total)
+		}
+	}
+}
+
+// Apply poses model, which must have been uploaded from the animator's mesh
+func (animator *Animator) Apply(model *Model) {
+	model.ApplyPose(animator.Pose)
+}
@@ -0,0 +1,90 @@
+package dusk
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// testSkinnedMesh returns a quad bound to a chain of two joints, with an animation that bends the
+// second joint 90 degrees about X at 1 second and back at 2
+func testSkinnedMesh() *MeshData {
+	rest := func(name string, parent int, translation mgl32.Vec3, skin int) MeshNode {
+		return MeshNode{Name: name, Parent: parent, Translation: translation, Rotation: mgl32.QuatIdent(), Scale: mgl32.Vec3{1, 1, 1}, Skin: skin}
+	}
+
+	mesh := NewMeshData()
+	mesh.Positions = []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 2, 0}, {0, 2, 0}}
+	mesh.Indices = []uint32{0, 1, 2, 0, 2, 3}
+	mesh.Joints = [][4]uint16{{0}, {0}, {1}, {1}}
+	mesh.Weights = []mgl32.Vec4{{1}, {1}, {1}, {1}}
+	mesh.Submeshes = []Submesh{{Name: "Quad", Count: 6, Node: 1}}
+	mesh.Nodes = []MeshNode{
+		rest("Root", -1, mgl32.Vec3{}, -1),
+		rest("Quad", 0, mgl32.Vec3{}, 0),
+		rest("Bone", 0, mgl32.Vec3{}, -1),
+		rest("Bone.001", 2, mgl32.Vec3{0, 1, 0}, -1),
+	}
+	mesh.Nodes[0].Children = []int{1, 2}
+	mesh.Nodes[2].Children = []int{3}
+	mesh.Skins = []MeshSkin{{
+		Joints:              []int{2, 3},
+		InverseBindMatrices: []mgl32.Mat4{mgl32.Ident4(), mgl32.Translate3D(0, -1, 0)},
+		Skeleton:            2,
+	}}
+	mesh.Animations = []MeshAnimation{{
+		Name:     "Bend",
+		Channels: []AnimationChannel{{Node: 3, Path: ANIMATION_ROTATION, Sampler: 0}},
+		Samplers: []AnimationSampler{{
+			Times:         []float32{0, 1, 2},
+			Values:        []float32{0, 0, 0, 1, 0.7071068, 0, 0, 0.7071068, 0, 0, 0, 1},
+			Components:    4,
+			Interpolation: INTERPOLATION_LINEAR,
+		}},
+	}}
+	return mesh
+}
+
+func TestAnimatorFade(t *testing.T) {
+	animator := NewAnimator(testSkinnedMesh())
+
+	first, err := animator.Play("Bend", true, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A track fading in starts at no weight, and is kept until it has faded in
+	animator.Update(0)
+	if len(animator.Tracks) != 1 || first.Weight != 0 {
+		t.Fatalf("Got %v tracks with weight %v after starting a fade, expected 1 with 0", len(animator.Tracks), first.Weight)
+	}
+	animator.Update(0.25)
+	if len(animator.Tracks) != 1 || mgl32.Abs(first.Weight-0.5) > 1e-6 {
+		t.Fatalf("Got %v tracks with weight %v halfway through the fade, expected 1 with 0.5", len(animator.Tracks), first.Weight)
+	}
+	animator.Update(0.5)
+	if first.Weight != 1 {
+		t.Fatalf("Got weight %v after the fade, expected 1", first.Weight)
+	}
+
+	// At 1 second the bone is bent 90 degrees about X
+	bent := mgl32.QuatRotate(mgl32.DegToRad(90), mgl32.Vec3{1, 0, 0})
+	animator.Update(0.25)
+	if got := animator.Pose.Rotations[3]; !got.ApproxEqualThreshold(bent, 1e-5) {
+		t.Errorf("Got rotation %v, expected %v", got, bent)
+	}
+
+	// Playing another track fades the first one out and removes it
+	second, err := animator.Play("Bend", true, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	animator.Update(0.25)
+	if len(animator.Tracks) != 2 {
+		t.Fatalf("Got %v tracks during the crossfade, expected 2", len(animator.Tracks))
+	}
+	animator.Update(0.25)
+	if len(animator.Tracks) != 1 || animator.Tracks[0] != second || second.Weight != 1 {
+		t.Errorf("Got %v tracks after the crossfade, expected only the second at weight 1", len(animator.Tracks))
+	}
+}
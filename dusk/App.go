@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
+	gl43 "github.com/go-gl/gl/v4.3-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/veandco/go-sdl2/sdl"
 )
@@ -22,11 +23,28 @@ type App struct {
 
 	AssetFunction func(string) ([]byte, error)
 
-	updateCtx  UpdateContext
-	renderCtx  RenderContext
-	sdlWindow  *sdl.Window
-	sdlContext sdl.GLContext
-	running    bool
+	// Used by NewTexture and the model loaders
+	TextureOptions TextureOptions
+
+	// Used by the model loaders when a file has no normals, see MeshData.GenerateNormalsWithCrease
+	CreaseAngle float32
+
+	// When set, NewTexture streams textures in the background instead of loading them immediately
+	TextureStreamer *TextureStreamer
+
+	// When set, the scene is rendered offscreen and passed through the enabled effects
+	PostProcess *PostProcess
+
+	updateCtx      UpdateContext
+	renderCtx      RenderContext
+	sdlWindow      *sdl.Window
+	sdlContext     sdl.GLContext
+	glMajorVersion int32
+	glMinorVersion int32
+	glExtensions   map[string]bool
+	hasCompute     bool
+	maxAnisotropy  float32
+	running        bool
 }
 
 func AssetFromFile(filename string) ([]byte, error) {
@@ -34,17 +52,28 @@ func AssetFromFile(filename string) ([]byte, error) {
 }
 
 func NewApp() (App, error) {
+	return newApp(sdl.WINDOW_SHOWN)
+}
+
+// NewOffscreenApp creates an App with a hidden window, for tools that need a GL context but no display
+func NewOffscreenApp() (App, error) {
+	return newApp(sdl.WINDOW_HIDDEN)
+}
+
+func newApp(windowFlags uint32) (App, error) {
 	var err error
 
 	app := App{
-		WindowTitle:   "Dusk",
-		WindowWidth:   640,
-		WindowHeight:  480,
-		TargetFps:     60,
-		EvtUpdate:     NewEvent(),
-		EvtRender:     NewEvent(),
-		EvtResize:     NewEvent(),
-		AssetFunction: AssetFromFile,
+		WindowTitle:    "Dusk",
+		WindowWidth:    640,
+		WindowHeight:   480,
+		TargetFps:      60,
+		EvtUpdate:      NewEvent(),
+		EvtRender:      NewEvent(),
+		EvtResize:      NewEvent(),
+		AssetFunction:  AssetFromFile,
+		TextureOptions: DefaultTextureOptions(),
+		glExtensions:   map[string]bool{},
 		updateCtx: UpdateContext{
 			Frame: 0,
 		},
@@ -65,7 +94,7 @@ func NewApp() (App, error) {
 		app.WindowTitle,
 		sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED,
 		app.WindowWidth, app.WindowHeight,
-		sdl.WINDOW_OPENGL|sdl.WINDOW_SHOWN,
+		sdl.WINDOW_OPENGL|windowFlags,
 	)
 
 	if err != nil {
@@ -90,6 +119,32 @@ func NewApp() (App, error) {
 	LogInfo("OpenGL Vendor %v", gl.GoStr(gl.GetString(gl.VENDOR)))
 	LogInfo("OpenGL Renderer %v", gl.GoStr(gl.GetString(gl.RENDERER)))
 
+	gl.GetIntegerv(gl.MAJOR_VERSION, &app.glMajorVersion)
+	gl.GetIntegerv(gl.MINOR_VERSION, &app.glMinorVersion)
+
+	// Compute shaders need the 4.3 entry points, which are only loaded if the context supports them
+	if app.glMajorVersion > 4 || (app.glMajorVersion == 4 && app.glMinorVersion >= 3) {
+		err = gl43.Init()
+		if err != nil {
+			LogWarn("Failed to initialize OpenGL 4.3 functions, %v", err)
+			err = nil
+		} else {
+			app.hasCompute = true
+		}
+	}
+	LogInfo("Compute Shaders %v", app.hasCompute)
+
+	var extensions int32
+	gl.GetIntegerv(gl.NUM_EXTENSIONS, &extensions)
+	for i := int32(0); i < extensions; i++ {
+		app.glExtensions[gl.GoStr(gl.GetStringi(gl.EXTENSIONS, uint32(i)))] = true
+	}
+
+	if app.HasExtension("GL_EXT_texture_filter_anisotropic") || app.HasExtension("GL_ARB_texture_filter_anisotropic") {
+		gl.GetFloatv(MAX_TEXTURE_MAX_ANISOTROPY, &app.maxAnisotropy)
+	}
+	LogInfo("Anisotropic Filtering %vx", app.maxAnisotropy)
+
 	var samples int32
 	gl.GetIntegerv(gl.SAMPLES, &samples)
 	LogInfo("Anti-Aliasing %vx", samples)
@@ -102,6 +157,8 @@ func NewApp() (App, error) {
 
 	gl.Enable(gl.MULTISAMPLE)
 
+	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LEQUAL)
 
@@ -113,6 +170,23 @@ func NewApp() (App, error) {
 	return app, err
 }
 
+func (app *App) HasExtension(name string) bool {
+	return app.glExtensions[name]
+}
+
+func (app *App) SupportsCompute() bool {
+	return app.hasCompute
+}
+
+// MemoryBarrier orders memory writes made by compute shaders, barriers is a combination of the *_BARRIER flags
+func (app *App) MemoryBarrier(barriers uint32) {
+	if !app.hasCompute {
+		LogWarn("MemoryBarrier requires OpenGL 4.3")
+		return
+	}
+	gl43.MemoryBarrier(barriers)
+}
+
 func (app App) Cleanup() {
 	app.sdlWindow.Destroy()
 	sdl.GL_DeleteContext(app.sdlContext)
@@ -166,10 +240,22 @@ func (app App) Start() error {
 
 		frameElap += elapsedTime
 		if frameDelay <= frameElap {
+			post := app.PostProcess
+			if post != nil && post.HasEnabled() {
+				post.time = float32(app.updateCtx.TotalTime / 1000.0)
+				post.Begin()
+			} else {
+				post = nil
+			}
+
 			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
 			app.EvtRender.Call(&app.renderCtx)
 
+			if post != nil {
+				post.End()
+			}
+
 			sdl.GL_SwapWindow(app.sdlWindow)
 
 			frameElap = 0.0
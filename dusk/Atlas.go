@@ -0,0 +1,371 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// AtlasRegion is the area of one image within an atlas, UV has v = 0 at the top of the image
+// to match how textures are uploaded
+type AtlasRegion struct {
+	X       int
+	Y       int
+	Width   int
+	Height  int
+	Rotated bool
+	UV      mgl32.Vec4 // u0, v0, u1, v1
+}
+
+type Atlas struct {
+	Texture *Texture
+	Width   int
+	Height  int
+	Regions map[string]AtlasRegion
+}
+
+func (atlas *Atlas) Cleanup() {
+	if atlas.Texture != nil {
+		atlas.Texture.Cleanup()
+	}
+}
+
+func (atlas *Atlas) Region(name string) (AtlasRegion, bool) {
+	region, ok := atlas.Regions[name]
+	return region, ok
+}
+
+// AtlasBuilder packs images into a single image using the MaxRects algorithm
+type AtlasBuilder struct {
+	MaxSize int
+	Padding int
+
+	names  []string
+	images map[string]image.Image
+}
+
+func NewAtlasBuilder(maxSize, padding int) *AtlasBuilder {
+	return &AtlasBuilder{
+		MaxSize: maxSize,
+		Padding: padding,
+		images:  map[string]image.Image{},
+	}
+}
+
+func (builder *AtlasBuilder) Add(name string, img image.Image) {
+	if _, ok := builder.images[name]; !ok {
+		builder.names = append(builder.names, name)
+	}
+	builder.images[name] = img
+}
+
+// AddFile decodes an image through the App's AssetFunction, it is named by its filename
+func (builder *AtlasBuilder) AddFile(app *App, filename string) error {
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("Failed to decode '%v': %v", filename, err)
+	}
+
+	builder.Add(filename, img)
+	return nil
+}
+
+// Pack places every image, growing the atlas in powers of two up to MaxSize
+func (builder *AtlasBuilder) Pack() (*image.RGBA, map[string]AtlasRegion, error) {
+	if len(builder.names) == 0 {
+		return nil, nil, fmt.Errorf("Atlas has no images")
+	}
+
+	// Larger images first gives a much tighter packing
+	names := append([]string{}, builder.names...)
+	sort.SliceStable(names, func(i, j int) bool {
+		a := builder.images[names[i]].Bounds().Size()
+		b := builder.images[names[j]].Bounds().Size()
+		return maxInt(a.X, a.Y) > maxInt(b.X, b.Y)
+	})
+
+	area := 0
+	sizes := make([]image.Point, len(names))
+	for i, name := range names {
+		sizes[i] = builder.images[name].Bounds().Size().Add(image.Pt(builder.Padding, builder.Padding))
+		area += sizes[i].X * sizes[i].Y
+	}
+
+	width, height := 64, 64
+	for width*height < area {
+		if width <= height {
+			width *= 2
+		} else {
+			height *= 2
+		}
+	}
+
+	for width <= builder.MaxSize && height <= builder.MaxSize {
+		rects, ok := packMaxRects(width, height, sizes)
+		if ok {
+			return builder.compose(width, height, names, rects)
+		}
+
+		if width <= height {
+			width *= 2
+		} else {
+			height *= 2
+		}
+	}
+
+	return nil, nil, fmt.Errorf("Images do not fit in a %vx%v atlas", builder.MaxSize, builder.MaxSize)
+}
+
+func (builder *AtlasBuilder) compose(width, height int, names []string, rects []image.Rectangle) (*image.RGBA, map[string]AtlasRegion, error) {
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	regions := map[string]AtlasRegion{}
+
+	for i, name := range names {
+		img := builder.images[name]
+		size := img.Bounds().Size()
+		rect := image.Rectangle{Min: rects[i].Min, Max: rects[i].Min.Add(size)}
+
+		draw.Draw(rgba, rect, img, img.Bounds().Min, draw.Src)
+		regions[name] = newAtlasRegion(rect, false, width, height)
+	}
+
+	return rgba, regions, nil
+}
+
+// Build packs the images and uploads them as a Texture
+func (builder *AtlasBuilder) Build(app *App) (*Atlas, error) {
+	rgba, regions, err := builder.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := app.TextureOptions
+	opts.SetWrap(gl.CLAMP_TO_EDGE)
+
+	tex, err := NewTextureFromImage(app, rgba, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Atlas{
+		Texture: tex,
+		Width:   rgba.Rect.Dx(),
+		Height:  rgba.Rect.Dy(),
+		Regions: regions,
+	}, nil
+}
+
+func newAtlasRegion(rect image.Rectangle, rotated bool, width, height int) AtlasRegion {
+	w, h := float32(width), float32(height)
+	return AtlasRegion{
+		X:       rect.Min.X,
+		Y:       rect.Min.Y,
+		Width:   rect.Dx(),
+		Height:  rect.Dy(),
+		Rotated: rotated,
+		UV: mgl32.Vec4{
+			float32(rect.Min.X) / w,
+			float32(rect.Min.Y) / h,
+			float32(rect.Max.X) / w,
+			float32(rect.Max.Y) / h,
+		},
+	}
+}
+
+// packMaxRects places each size using the best short side fit heuristic, returning false if they don't fit
+func packMaxRects(width, height int, sizes []image.Point) ([]image.Rectangle, bool) {
+	free := []image.Rectangle{image.Rect(0, 0, width, height)}
+	placed := make([]image.Rectangle, len(sizes))
+
+	for i, size := range sizes {
+		best := -1
+		bestShort, bestLong := width+height, width+height
+
+		for f, rect := range free {
+			if rect.Dx() < size.X || rect.Dy() < size.Y {
+				continue
+			}
+			leftX, leftY := rect.Dx()-size.X, rect.Dy()-size.Y
+			short, long := leftX, leftY
+			if short > long {
+				short, long = long, short
+			}
+			if short < bestShort || (short == bestShort && long < bestLong) {
+				best, bestShort, bestLong = f, short, long
+			}
+		}
+
+		if best < 0 {
+			return nil, false
+		}
+
+		node := image.Rectangle{Min: free[best].Min, Max: free[best].Min.Add(size)}
+		placed[i] = node
+
+		// Split every free rectangle that overlaps the new node
+		next := []image.Rectangle{}
+		for _, rect := range free {
+			if !rect.Overlaps(node) {
+				next = append(next, rect)
+				continue
+			}
+			if node.Min.X > rect.Min.X {
+				next = append(next, image.Rect(rect.Min.X, rect.Min.Y, node.Min.X, rect.Max.Y))
+			}
+			if node.Max.X < rect.Max.X {
+				next = append(next, image.Rect(node.Max.X, rect.Min.Y, rect.Max.X, rect.Max.Y))
+			}
+			if node.Min.Y > rect.Min.Y {
+				next = append(next, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, node.Min.Y))
+			}
+			if node.Max.Y < rect.Max.Y {
+				next = append(next, image.Rect(rect.Min.X, node.Max.Y, rect.Max.X, rect.Max.Y))
+			}
+		}
+
+		// Remove free rectangles contained in another
+		free = free[:0]
+		for a := range next {
+			contained := false
+			for b := range next {
+				if a != b && next[a].In(next[b]) && (next[a] != next[b] || a > b) {
+					contained = true
+					break
+				}
+			}
+			if !contained {
+				free = append(free, next[a])
+			}
+		}
+	}
+
+	return placed, true
+}
+
+// The manifest follows the TexturePacker "JSON (Hash)" format, with an added uv for each frame
+type atlasManifest struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		Image string `json:"image"`
+		Size  struct {
+			W int `json:"w"`
+			H int `json:"h"`
+		} `json:"size"`
+	} `json:"meta"`
+}
+
+type atlasManifestFrame struct {
+	Filename string `json:"filename,omitempty"`
+	Frame    struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"frame"`
+	Rotated bool       `json:"rotated"`
+	UV      [4]float32 `json:"uv"`
+}
+
+// WriteAtlasManifest writes the regions as JSON, imageName is the file the atlas image is saved as
+func WriteAtlasManifest(w io.Writer, imageName string, width, height int, regions map[string]AtlasRegion) error {
+	frames := map[string]atlasManifestFrame{}
+	for name, region := range regions {
+		frame := atlasManifestFrame{
+			Rotated: region.Rotated,
+			UV:      region.UV,
+		}
+		frame.Frame.X = region.X
+		frame.Frame.Y = region.Y
+		frame.Frame.W = region.Width
+		frame.Frame.H = region.Height
+		frames[name] = frame
+	}
+
+	framesData, err := json.Marshal(frames)
+	if err != nil {
+		return err
+	}
+
+	manifest := atlasManifest{
+		Frames: framesData,
+	}
+	manifest.Meta.Image = imageName
+	manifest.Meta.Size.W = width
+	manifest.Meta.Size.H = height
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// NewAtlasFromManifest loads a sprite sheet described by TexturePacker JSON, in either the hash or
+// array layout, which includes the manifests written by WriteAtlasManifest
+func NewAtlasFromManifest(app *App, filename string) (*Atlas, error) {
+	LogLoad("Atlas '%v'", filename)
+
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest atlasManifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("Malformed atlas manifest '%v': %v", filename, err)
+	}
+
+	frames := map[string]atlasManifestFrame{}
+	if err = json.Unmarshal(manifest.Frames, &frames); err != nil {
+		list := []atlasManifestFrame{}
+		if err = json.Unmarshal(manifest.Frames, &list); err != nil {
+			return nil, fmt.Errorf("Malformed atlas frames in '%v': %v", filename, err)
+		}
+		for _, frame := range list {
+			frames[frame.Filename] = frame
+		}
+	}
+
+	opts := app.TextureOptions
+	opts.SetWrap(gl.CLAMP_TO_EDGE)
+
+	tex, err := NewTextureWithOptions(app, path.Join(path.Dir(filename), manifest.Meta.Image), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := tex.Size()
+	atlas := &Atlas{
+		Texture: tex,
+		Width:   width,
+		Height:  height,
+		Regions: map[string]AtlasRegion{},
+	}
+
+	for name, frame := range frames {
+		// Rotated frames are stored turned 90 degrees clockwise, so width and height are swapped
+		w, h := frame.Frame.W, frame.Frame.H
+		if frame.Rotated {
+			w, h = h, w
+		}
+		rect := image.Rect(frame.Frame.X, frame.Frame.Y, frame.Frame.X+w, frame.Frame.Y+h)
+		atlas.Regions[name] = newAtlasRegion(rect, frame.Rotated, width, height)
+	}
+
+	return atlas, nil
+}
@@ -0,0 +1,326 @@
+package dusk
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// AABB is an axis-aligned bounding box. An empty box has Min greater than Max.
+type AABB struct {
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+// NewAABB returns an empty box, which any point extends
+func NewAABB() AABB {
+	inf := float32(math.Inf(1))
+	return AABB{
+		Min: mgl32.Vec3{inf, inf, inf},
+		Max: mgl32.Vec3{-inf, -inf, -inf},
+	}
+}
+
+func (box AABB) IsEmpty() bool {
+	return box.Min[0] > box.Max[0] || box.Min[1] > box.Max[1] || box.Min[2] > box.Max[2]
+}
+
+func (box AABB) Center() mgl32.Vec3 {
+	return box.Min.Add(box.Max).Mul(0.5)
+}
+
+func (box AABB) Size() mgl32.Vec3 {
+	return box.Max.Sub(box.Min)
+}
+
+// Extend returns the box grown to include point
+func (box AABB) Extend(point mgl32.Vec3) AABB {
+	for i := 0; i < 3; i++ {
+		box.Min[i] = float32(math.Min(float64(box.Min[i]), float64(point[i])))
+		box.Max[i] = float32(math.Max(float64(box.Max[i]), float64(point[i])))
+	}
+	return box
+}
+
+// Union returns the box containing both boxes
+func (box AABB) Union(other AABB) AABB {
+	if other.IsEmpty() {
+		return box
+	}
+	return box.Extend(other.Min).Extend(other.Max)
+}
+
+func (box AABB) Contains(point mgl32.Vec3) bool {
+	return point[0] >= box.Min[0] && point[0] <= box.Max[0] &&
+		point[1] >= box.Min[1] && point[1] <= box.Max[1] &&
+		point[2] >= box.Min[2] && point[2] <= box.Max[2]
+}
+
+func (box AABB) Intersects(other AABB) bool {
+	return box.Min[0] <= other.Max[0] && box.Max[0] >= other.Min[0] &&
+		box.Min[1] <= other.Max[1] && box.Max[1] >= other.Min[1] &&
+		box.Min[2] <= other.Max[2] && box.Max[2] >= other.Min[2]
+}
+
+// Corners returns the eight corners of the box
+func (box AABB) Corners() [8]mgl32.Vec3 {
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		corners[i] = box.Min
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<uint(axis)) != 0 {
+				corners[i][axis] = box.Max[axis]
+			}
+		}
+	}
+	return corners
+}
+
+// Transform returns the axis-aligned box containing this one after transform
+func (box AABB) Transform(transform mgl32.Mat4) AABB {
+	if box.IsEmpty() {
+		return box
+	}
+
+	center := mgl32.TransformCoordinate(box.Center(), transform)
+	half := box.Size().Mul(0.5)
+
+	var extent mgl32.Vec3
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			extent[row] += mgl32.Abs(transform.At(row, col)) * half[col]
+		}
+	}
+	return AABB{Min: center.Sub(extent), Max: center.Add(extent)}
+}
+
+// BoundingSphere is a sphere containing a set of points. An empty sphere has a negative Radius.
+type BoundingSphere struct {
+	Center mgl32.Vec3
+	Radius float32
+}
+
+func (sphere BoundingSphere) IsEmpty() bool {
+	return sphere.Radius < 0
+}
+
+func (sphere BoundingSphere) Contains(point mgl32.Vec3) bool {
+	return point.Sub(sphere.Center).Len() <= sphere.Radius
+}
+
+func (sphere BoundingSphere) Intersects(other BoundingSphere) bool {
+	return other.Center.Sub(sphere.Center).Len() <= sphere.Radius+other.Radius
+}
+
+// Transform returns the sphere after transform, scaled by its largest axis
+func (sphere BoundingSphere) Transform(transform mgl32.Mat4) BoundingSphere {
+	if sphere.IsEmpty() {
+		return sphere
+	}
+
+	scale := float32(0)
+	for col := 0; col < 3; col++ {
+		if s := transform.Col(col).Vec3().Len(); s > scale {
+			scale = s
+		}
+	}
+	return BoundingSphere{
+		Center: mgl32.TransformCoordinate(sphere.Center, transform),
+		Radius: sphere.Radius * scale,
+	}
+}
+
+// boundsOfIndices returns the box and sphere around the vertices used by indices. The sphere is
+// centered on the box, which is close to the smallest sphere for most meshes.
+func boundsOfIndices(positions []mgl32.Vec3, indices []uint32) (AABB, BoundingSphere) {
+	box := NewAABB()
+	for _, index := range indices {
+		box = box.Extend(positions[index])
+	}
+	if box.IsEmpty() {
+		return box, BoundingSphere{Radius: -1}
+	}
+
+	center := box.Center()
+	radius := float32(0)
+	for _, index := range indices {
+		if d := positions[index].Sub(center).Len(); d > radius {
+			radius = d
+		}
+	}
+	return box, BoundingSphere{Center: center, Radius: radius}
+}
+
+// OBB is an oriented bounding box, such as an AABB after rotating it. Axes are unit length and
+// HalfSize is the distance from Center to each face along them.
+type OBB struct {
+	Center   mgl32.Vec3
+	Axes     [3]mgl32.Vec3
+	HalfSize mgl32.Vec3
+}
+
+// NewOBB returns box after transform, which should not have any shear
+func NewOBB(box AABB, transform mgl32.Mat4) OBB {
+	obb := OBB{
+		Center: mgl32.TransformCoordinate(box.Center(), transform),
+	}
+	half := box.Size().Mul(0.5)
+	for i := 0; i < 3; i++ {
+		axis := transform.Col(i).Vec3()
+		length := axis.Len()
+		obb.HalfSize[i] = half[i] * length
+		if length > 0 {
+			obb.Axes[i] = axis.Mul(1 / length)
+		}
+	}
+	return obb
+}
+
+// Corners returns the eight corners of the box
+func (obb OBB) Corners() [8]mgl32.Vec3 {
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		corners[i] = obb.Center
+		for axis := 0; axis < 3; axis++ {
+			offset := obb.Axes[axis].Mul(obb.HalfSize[axis])
+			if i&(1<<uint(axis)) != 0 {
+				corners[i] = corners[i].Add(offset)
+			} else {
+				corners[i] = corners[i].Sub(offset)
+			}
+		}
+	}
+	return corners
+}
+
+// AABB returns the axis-aligned box containing this one
+func (obb OBB) AABB() AABB {
+	var extent mgl32.Vec3
+	for i := 0; i < 3; i++ {
+		extent = extent.Add(absVec3(obb.Axes[i]).Mul(obb.HalfSize[i]))
+	}
+	return AABB{Min: obb.Center.Sub(extent), Max: obb.Center.Add(extent)}
+}
+
+func (obb OBB) Contains(point mgl32.Vec3) bool {
+	d := point.Sub(obb.Center)
+	for i := 0; i < 3; i++ {
+		if mgl32.Abs(d.Dot(obb.Axes[i])) > obb.HalfSize[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersects tests for overlap with the separating axis theorem
+func (obb OBB) Intersects(other OBB) bool {
+	axes := make([]mgl32.Vec3, 0, 15)
+	axes = append(axes, obb.Axes[:]...)
+	axes = append(axes, other.Axes[:]...)
+	for _, a := range obb.Axes {
+		for _, b := range other.Axes {
+			// Parallel edges are already covered by the face axes
+			if cross := a.Cross(b); cross.Len() > 1e-6 {
+				axes = append(axes, cross)
+			}
+		}
+	}
+
+	d := other.Center.Sub(obb.Center)
+	for _, axis := range axes {
+		if mgl32.Abs(d.Dot(axis)) > obb.projectedRadius(axis)+other.projectedRadius(axis) {
+			return false
+		}
+	}
+	return true
+}
+
+func (obb OBB) projectedRadius(axis mgl32.Vec3) float32 {
+	radius := float32(0)
+	for i := 0; i < 3; i++ {
+		radius += obb.HalfSize[i] * mgl32.Abs(obb.Axes[i].Dot(axis))
+	}
+	return radius
+}
+
+func absVec3(v mgl32.Vec3) mgl32.Vec3 {
+	return mgl32.Vec3{mgl32.Abs(v[0]), mgl32.Abs(v[1]), mgl32.Abs(v[2])}
+}
+
+// Frustum is the volume a projection can see, as six planes facing inwards. Each plane is stored
+// as its normal and distance, so a point is in front of it when Dot(plane, point.Vec4(1)) >= 0.
+type Frustum struct {
+	Planes [6]mgl32.Vec4
+}
+
+// NewFrustum returns the frustum of a combined projection and view matrix, in world space. With a
+// model matrix included as well, the frustum is in that model's space.
+func NewFrustum(viewProj mgl32.Mat4) Frustum {
+	var frustum Frustum
+	w := viewProj.Row(3)
+	for i := 0; i < 3; i++ {
+		row := viewProj.Row(i)
+		frustum.Planes[i*2] = w.Add(row)
+		frustum.Planes[i*2+1] = w.Sub(row)
+	}
+	for i, plane := range frustum.Planes {
+		if length := plane.Vec3().Len(); length > 0 {
+			frustum.Planes[i] = plane.Mul(1 / length)
+		}
+	}
+	return frustum
+}
+
+// Transform returns the frustum in the space that transform maps from, such as from world space
+// to a model's space with its model matrix
+func (frustum Frustum) Transform(transform mgl32.Mat4) Frustum {
+	var out Frustum
+	transposed := transform.Transpose()
+	for i, plane := range frustum.Planes {
+		out.Planes[i] = transposed.Mul4x1(plane)
+	}
+	return out
+}
+
+func (frustum Frustum) ContainsPoint(point mgl32.Vec3) bool {
+	for _, plane := range frustum.Planes {
+		if plane.Dot(point.Vec4(1)) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsSphere returns false if the sphere is entirely outside the frustum
+func (frustum Frustum) IntersectsSphere(sphere BoundingSphere) bool {
+	if sphere.IsEmpty() {
+		return false
+	}
+	for _, plane := range frustum.Planes {
+		if plane.Dot(sphere.Center.Vec4(1)) < -sphere.Radius*plane.Vec3().Len() {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsAABB returns false if the box is entirely outside the frustum. Boxes near the edges
+// may pass without being inside, which is conservative enough for culling.
+func (frustum Frustum) IntersectsAABB(box AABB) bool {
+	if box.IsEmpty() {
+		return false
+	}
+	for _, plane := range frustum.Planes {
+		// Test the corner furthest along the plane's normal
+		corner := box.Min
+		for i := 0; i < 3; i++ {
+			if plane[i] > 0 {
+				corner[i] = box.Max[i]
+			}
+		}
+		if plane.Dot(corner.Vec4(1)) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package dusk
 
 import (
+	"math"
+
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -83,3 +85,38 @@ func (camera *Camera) SetUp(up mgl32.Vec3) {
 	camera.up = up
 	camera.calculateView()
 }
+
+// Frustum returns the volume the camera can see, in world space
+func (camera *Camera) Frustum() Frustum {
+	return NewFrustum(camera.Proj.Mul4(camera.View))
+}
+
+// Frame moves the camera back along its direction until sphere fits in its view
+func (camera *Camera) Frame(sphere BoundingSphere) {
+	if sphere.IsEmpty() {
+		return
+	}
+
+	dir := camera.dir
+	if dir.Len() == 0 {
+		dir = mgl32.Vec3{0, 0, -1}
+	}
+
+	// The sphere has to fit in the narrower of the two fields of view
+	fovY := float64(mgl32.DegToRad(camera.fov))
+	fovX := 2 * math.Atan(math.Tan(fovY/2)*float64(camera.aspectWidth/camera.aspectHeight))
+	distance := float64(sphere.Radius) / math.Sin(math.Min(fovX, fovY)/2)
+
+	camera.pos = sphere.Center.Sub(dir.Normalize().Mul(float32(distance)))
+	camera.calculateView()
+}
+
+// ScreenSize returns the height of sphere on screen as a fraction of the screen's height, which is
+// more than 1 when it fills the screen
+func (camera *Camera) ScreenSize(sphere BoundingSphere) float32 {
+	distance := sphere.Center.Sub(camera.pos).Len()
+	if distance <= sphere.Radius {
+		return float32(math.Inf(1))
+	}
+	return sphere.Radius / (distance * float32(math.Tan(float64(mgl32.DegToRad(camera.fov))/2)))
+}
@@ -0,0 +1,233 @@
+package dusk
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Cubemap faces, in the order OpenGL expects them
+const (
+	CUBE_POSITIVE_X = 0
+	CUBE_NEGATIVE_X = 1
+	CUBE_POSITIVE_Y = 2
+	CUBE_NEGATIVE_Y = 3
+	CUBE_POSITIVE_Z = 4
+	CUBE_NEGATIVE_Z = 5
+)
+
+type Cubemap struct {
+	glId    uint32
+	size    int
+	options TextureOptions
+}
+
+func defaultCubemapOptions(app *App) TextureOptions {
+	opts := app.TextureOptions
+	opts.SetWrap(gl.CLAMP_TO_EDGE)
+	return opts
+}
+
+// NewCubemap loads a cubemap from six square images
+func NewCubemap(app *App, right, left, top, bottom, front, back string) (*Cubemap, error) {
+	filenames := [6]string{right, left, top, bottom, front, back}
+	faces := [6]*image.RGBA{}
+
+	for i, filename := range filenames {
+		LogLoad("Cubemap Face '%v'", filename)
+
+		rgba, err := loadImageRGBA(app, filename)
+		if err != nil {
+			return nil, err
+		}
+		faces[i] = rgba
+	}
+
+	return newCubemapFromFaces(app, faces, defaultCubemapOptions(app))
+}
+
+// NewCubemapFromImage loads a cubemap from a single image, either a horizontal cross (4:3),
+// a vertical cross (3:4), or an equirectangular panorama (2:1)
+func NewCubemapFromImage(app *App, filename string) (*Cubemap, error) {
+	LogLoad("Cubemap '%v'", filename)
+
+	rgba, err := loadImageRGBA(app, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	size := rgba.Rect.Size()
+	var faces [6]*image.RGBA
+
+	switch {
+	case size.X*3 == size.Y*4:
+		faces = cubemapFacesFromCross(rgba, size.X/4, [6]image.Point{
+			{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {3, 1},
+		})
+	case size.X*4 == size.Y*3:
+		faces = cubemapFacesFromCross(rgba, size.X/3, [6]image.Point{
+			{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {1, 3},
+		})
+		// The back face of a vertical cross is upside down
+		faces[CUBE_NEGATIVE_Z] = rotateImage180(faces[CUBE_NEGATIVE_Z])
+	case size.X == size.Y*2:
+		faces = cubemapFacesFromEquirectangular(rgba, size.X/4)
+	default:
+		return nil, fmt.Errorf("Unsupported cubemap layout %vx%v in '%v'", size.X, size.Y, filename)
+	}
+
+	return newCubemapFromFaces(app, faces, defaultCubemapOptions(app))
+}
+
+func newCubemapFromFaces(app *App, faces [6]*image.RGBA, opts TextureOptions) (*Cubemap, error) {
+	size := faces[0].Rect.Size().X
+	for i := range faces {
+		faceSize := faces[i].Rect.Size()
+		if faceSize.X != faceSize.Y || faceSize.X != size {
+			return nil, fmt.Errorf("Cubemap faces must be square and the same size")
+		}
+	}
+
+	var glId uint32
+	gl.GenTextures(1, &glId)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, glId)
+
+	for i := range faces {
+		gl.TexImage2D(
+			gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i), 0, opts.internalFormat(),
+			int32(size), int32(size),
+			0, gl.RGBA, gl.UNSIGNED_BYTE,
+			gl.Ptr(faces[i].Pix))
+	}
+
+	if opts.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+	}
+	opts.apply(app, gl.TEXTURE_CUBE_MAP)
+
+	return &Cubemap{
+		glId:    glId,
+		size:    size,
+		options: opts,
+	}, nil
+}
+
+func (cube *Cubemap) Cleanup() {
+	gl.DeleteTextures(1, &cube.glId)
+}
+
+func (cube *Cubemap) Size() int {
+	return cube.size
+}
+
+func (cube *Cubemap) Bind() {
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, cube.glId)
+}
+
+// cubemapFacesFromCross cuts the faces out of a cross, cells are in units of faces
+func cubemapFacesFromCross(src *image.RGBA, size int, cells [6]image.Point) [6]*image.RGBA {
+	faces := [6]*image.RGBA{}
+	for i, cell := range cells {
+		faces[i] = image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(faces[i], faces[i].Bounds(), src, cell.Mul(size), draw.Src)
+	}
+	return faces
+}
+
+func rotateImage180(src *image.RGBA) *image.RGBA {
+	size := src.Rect.Size()
+	dst := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			dst.SetRGBA(size.X-x-1, size.Y-y-1, src.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// cubemapFaceDirection returns the direction through a face at s, t in [-1, 1], following the
+// face orientations in the OpenGL specification
+func cubemapFaceDirection(face int, s, t float64) (float64, float64, float64) {
+	switch face {
+	case CUBE_POSITIVE_X:
+		return 1, -t, -s
+	case CUBE_NEGATIVE_X:
+		return -1, -t, s
+	case CUBE_POSITIVE_Y:
+		return s, 1, t
+	case CUBE_NEGATIVE_Y:
+		return s, -1, -t
+	case CUBE_POSITIVE_Z:
+		return s, -t, 1
+	default:
+		return -s, -t, -1
+	}
+}
+
+// cubemapFacesFromEquirectangular projects a panorama onto each face with bilinear sampling
+func cubemapFacesFromEquirectangular(src *image.RGBA, size int) [6]*image.RGBA {
+	srcSize := src.Rect.Size()
+	faces := [6]*image.RGBA{}
+
+	sample := func(u, v float64) [4]float64 {
+		x := u*float64(srcSize.X) - 0.5
+		y := v*float64(srcSize.Y) - 0.5
+		x0 := int(math.Floor(x))
+		y0 := int(math.Floor(y))
+		fx := x - float64(x0)
+		fy := y - float64(y0)
+
+		texel := func(x, y int) [4]float64 {
+			// Wrap horizontally around the sphere, clamp at the poles
+			x = ((x % srcSize.X) + srcSize.X) % srcSize.X
+			if y < 0 {
+				y = 0
+			} else if y >= srcSize.Y {
+				y = srcSize.Y - 1
+			}
+			c := src.RGBAAt(x, y)
+			return [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+		}
+
+		c00 := texel(x0, y0)
+		c10 := texel(x0+1, y0)
+		c01 := texel(x0, y0+1)
+		c11 := texel(x0+1, y0+1)
+
+		var c [4]float64
+		for i := range c {
+			top := c00[i]*(1-fx) + c10[i]*fx
+			bottom := c01[i]*(1-fx) + c11[i]*fx
+			c[i] = top*(1-fy) + bottom*fy
+		}
+		return c
+	}
+
+	for face := range faces {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				s := 2*(float64(x)+0.5)/float64(size) - 1
+				t := 2*(float64(y)+0.5)/float64(size) - 1
+				dx, dy, dz := cubemapFaceDirection(face, s, t)
+				length := math.Sqrt(dx*dx + dy*dy + dz*dz)
+
+				u := 0.5 + math.Atan2(dx, -dz)/(2*math.Pi)
+				v := math.Acos(dy/length) / math.Pi
+
+				c := sample(u, v)
+				i := dst.PixOffset(x, y)
+				for n := 0; n < 4; n++ {
+					dst.Pix[i+n] = uint8(math.Min(255, c[n]+0.5))
+				}
+			}
+		}
+		faces[face] = dst
+	}
+
+	return faces
+}
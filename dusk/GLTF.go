@@ -0,0 +1,1230 @@
+package dusk
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	GLB_MAGIC      = 0x46546C67 // "glTF"
+	GLB_CHUNK_JSON = 0x4E4F534A // "JSON"
+	GLB_CHUNK_BIN  = 0x004E4942 // "BIN\0"
+)
+
+// Largest accessor without a buffer view, which is filled with zeros instead of being bounded by
+// the data it's read from
+const GLTF_MAX_ZERO_ELEMENTS = 1 << 24
+
+// Accessor component types
+const (
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+)
+
+// Primitive modes, points and lines are not supported
+const (
+	gltfTriangles     = 4
+	gltfTriangleStrip = 5
+	gltfTriangleFan   = 6
+)
+
+// Extensions that may be required by a file, as the loader handles them
+var gltfSupportedExtensions = map[string]bool{
+	"KHR_mesh_quantization": true,
+}
+
+var gltfTypeComponents = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT2":   4,
+	"MAT3":   9,
+	"MAT4":   16,
+}
+
+type gltfTextureInfo struct {
+	Index    int      `json:"index"`
+	TexCoord int      `json:"texCoord"`
+	Scale    *float32 `json:"scale"`
+	Strength *float32 `json:"strength"`
+}
+
+type gltfDocument struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	ExtensionsRequired []string `json:"extensionsRequired"`
+	Scene              *int     `json:"scene"`
+	Scenes             []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes []struct {
+		Name        string    `json:"name"`
+		Children    []int     `json:"children"`
+		Matrix      []float32 `json:"matrix"`
+		Translation []float32 `json:"translation"`
+		Rotation    []float32 `json:"rotation"`
+		Scale       []float32 `json:"scale"`
+		Mesh        *int      `json:"mesh"`
+		Skin        *int      `json:"skin"`
+	} `json:"nodes"`
+	Meshes []struct {
+		Name       string `json:"name"`
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Material   *int           `json:"material"`
+			Mode       *int           `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Accessors []struct {
+		BufferView    *int   `json:"bufferView"`
+		ByteOffset    int    `json:"byteOffset"`
+		ComponentType int    `json:"componentType"`
+		Normalized    bool   `json:"normalized"`
+		Count         int    `json:"count"`
+		Type          string `json:"type"`
+		Sparse        *struct {
+			Count   int `json:"count"`
+			Indices struct {
+				BufferView    int `json:"bufferView"`
+				ByteOffset    int `json:"byteOffset"`
+				ComponentType int `json:"componentType"`
+			} `json:"indices"`
+			Values struct {
+				BufferView int `json:"bufferView"`
+				ByteOffset int `json:"byteOffset"`
+			} `json:"values"`
+		} `json:"sparse"`
+	} `json:"accessors"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		ByteStride int `json:"byteStride"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		URI        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+	Materials []struct {
+		Name                 string `json:"name"`
+		PbrMetallicRoughness *struct {
+			BaseColorFactor          []float32        `json:"baseColorFactor"`
+			BaseColorTexture         *gltfTextureInfo `json:"baseColorTexture"`
+			MetallicFactor           *float32         `json:"metallicFactor"`
+			RoughnessFactor          *float32         `json:"roughnessFactor"`
+			MetallicRoughnessTexture *gltfTextureInfo `json:"metallicRoughnessTexture"`
+		} `json:"pbrMetallicRoughness"`
+		NormalTexture    *gltfTextureInfo `json:"normalTexture"`
+		OcclusionTexture *gltfTextureInfo `json:"occlusionTexture"`
+		EmissiveTexture  *gltfTextureInfo `json:"emissiveTexture"`
+		EmissiveFactor   []float32        `json:"emissiveFactor"`
+		AlphaMode        string           `json:"alphaMode"`
+		AlphaCutoff      *float32         `json:"alphaCutoff"`
+		DoubleSided      bool             `json:"doubleSided"`
+	} `json:"materials"`
+	Textures []struct {
+		Sampler *int `json:"sampler"`
+		Source  *int `json:"source"`
+	} `json:"textures"`
+	Images []struct {
+		Name       string `json:"name"`
+		URI        string `json:"uri"`
+		MimeType   string `json:"mimeType"`
+		BufferView *int   `json:"bufferView"`
+	} `json:"images"`
+	Samplers []struct {
+		MagFilter int32 `json:"magFilter"`
+		MinFilter int32 `json:"minFilter"`
+		WrapS     int32 `json:"wrapS"`
+		WrapT     int32 `json:"wrapT"`
+	} `json:"samplers"`
+	Skins []struct {
+		Name                string `json:"name"`
+		InverseBindMatrices *int   `json:"inverseBindMatrices"`
+		Joints              []int  `json:"joints"`
+		Skeleton            *int   `json:"skeleton"`
+	} `json:"skins"`
+	Animations []struct {
+		Name     string `json:"name"`
+		Channels []struct {
+			Sampler int `json:"sampler"`
+			Target  struct {
+				Node *int   `json:"node"`
+				Path string `json:"path"`
+			} `json:"target"`
+		} `json:"channels"`
+		Samplers []struct {
+			Input         int    `json:"input"`
+			Output        int    `json:"output"`
+			Interpolation string `json:"interpolation"`
+		} `json:"samplers"`
+	} `json:"animations"`
+}
+
+type gltfLoader struct {
+	app      *App
+	filename string
+	dirname  string
+	doc      gltfDocument
+	buffers  [][]byte
+	mesh     *MeshData
+
+	materialNames []string
+	warnedUVs     bool
+}
+
+// LoadGLTF reads a glTF 2.0 file, either .gltf JSON with external or embedded buffers, or binary
+// .glb. Every node is kept in MeshData.Nodes with the same index, and each node with a mesh adds
+// submeshes named after it. Texture coordinates are flipped to have their origin at the bottom
+// left, as with OBJ files.
+func LoadGLTF(app *App, filename string) (*MeshData, error) {
+	LogLoad("Mesh '%v'", filename)
+
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := &gltfLoader{
+		app:      app,
+		filename: filename,
+		dirname:  path.Dir(filename),
+		mesh:     NewMeshData(),
+	}
+
+	var bin []byte
+	if len(data) >= 12 && binary.LittleEndian.Uint32(data) == GLB_MAGIC {
+		data, bin, err = parseGLB(data)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid GLB file '%v': %v", filename, err)
+		}
+	}
+
+	if err := json.Unmarshal(data, &loader.doc); err != nil {
+		return nil, fmt.Errorf("Invalid glTF file '%v': %v", filename, err)
+	}
+
+	if !strings.HasPrefix(loader.doc.Asset.Version, "2.") {
+		return nil, fmt.Errorf("Unsupported glTF version '%v' in '%v'", loader.doc.Asset.Version, filename)
+	}
+
+	for _, ext := range loader.doc.ExtensionsRequired {
+		if !gltfSupportedExtensions[ext] {
+			return nil, fmt.Errorf("Unsupported glTF extension '%v' required by '%v'", ext, filename)
+		}
+	}
+
+	if err := loader.loadBuffers(bin); err != nil {
+		return nil, err
+	}
+
+	steps := []func() error{
+		loader.loadMaterials,
+		loader.loadNodes,
+		loader.loadSkins,
+		loader.loadAnimations,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("Invalid glTF file '%v': %v", filename, err)
+		}
+	}
+
+	return loader.mesh, nil
+}
+
+// parseGLB splits a binary glTF file into its JSON and BIN chunks
+func parseGLB(data []byte) ([]byte, []byte, error) {
+	version := binary.LittleEndian.Uint32(data[4:])
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if version != 2 {
+		return nil, nil, fmt.Errorf("unsupported version %v", version)
+	}
+	if length > len(data) {
+		return nil, nil, fmt.Errorf("truncated file")
+	}
+
+	var jsonChunk, binChunk []byte
+	offset := 12
+	for offset+8 <= length {
+		chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		offset += 8
+		if chunkLength < 0 || offset+chunkLength > length {
+			return nil, nil, fmt.Errorf("truncated chunk")
+		}
+
+		chunk := data[offset : offset+chunkLength]
+		switch chunkType {
+		case GLB_CHUNK_JSON:
+			jsonChunk = chunk
+		case GLB_CHUNK_BIN:
+			if binChunk == nil {
+				binChunk = chunk
+			}
+		}
+
+		// Chunks are padded to 4 bytes
+		offset += (chunkLength + 3) &^ 3
+	}
+
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf("missing JSON chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+// loadURI reads a data URI, or a file relative to the glTF file
+func (loader *gltfLoader) loadURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.IndexByte(uri, ',')
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, fmt.Errorf("unsupported data URI")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+
+	filename, err := url.PathUnescape(uri)
+	if err != nil {
+		filename = uri
+	}
+	return loader.app.AssetFunction(path.Join(loader.dirname, filename))
+}
+
+func (loader *gltfLoader) loadBuffers(bin []byte) error {
+	for i, buffer := range loader.doc.Buffers {
+		var data []byte
+		if buffer.URI == "" {
+			// Only the first buffer of a GLB file may refer to the BIN chunk
+			if i != 0 || bin == nil {
+				return fmt.Errorf("Buffer %v of '%v' has no data", i, loader.filename)
+			}
+			data = bin
+		} else {
+			var err error
+			data, err = loader.loadURI(buffer.URI)
+			if err != nil {
+				return fmt.Errorf("Failed to load buffer %v of '%v': %v", i, loader.filename, err)
+			}
+		}
+
+		if len(data) < buffer.ByteLength {
+			return fmt.Errorf("Buffer %v of '%v' is %v bytes, expected %v", i, loader.filename, len(data), buffer.ByteLength)
+		}
+		loader.buffers = append(loader.buffers, data)
+	}
+	return nil
+}
+
+// bufferView returns the bytes of a buffer view and its stride, 0 if tightly packed
+func (loader *gltfLoader) bufferView(index int) ([]byte, int, error) {
+	if index < 0 || index >= len(loader.doc.BufferViews) {
+		return nil, 0, fmt.Errorf("buffer view %v out of range", index)
+	}
+	view := loader.doc.BufferViews[index]
+	if view.Buffer < 0 || view.Buffer >= len(loader.buffers) {
+		return nil, 0, fmt.Errorf("buffer %v out of range", view.Buffer)
+	}
+	buffer := loader.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteOffset+view.ByteLength > len(buffer) {
+		return nil, 0, fmt.Errorf("buffer view %v out of bounds", index)
+	}
+	return buffer[view.ByteOffset : view.ByteOffset+view.ByteLength], view.ByteStride, nil
+}
+
+func gltfComponentSize(componentType int) int {
+	switch componentType {
+	case gltfByte, gltfUnsignedByte:
+		return 1
+	case gltfShort, gltfUnsignedShort:
+		return 2
+	case gltfUnsignedInt, gltfFloat:
+		return 4
+	}
+	return 0
+}
+
+// readGLTFComponent reads one component as a float, normalizing integers if asked to
+func readGLTFComponent(data []byte, componentType int, normalized bool) float32 {
+	switch componentType {
+	case gltfByte:
+		v := float32(int8(data[0]))
+		if normalized {
+			return float32(math.Max(float64(v)/127, -1))
+		}
+		return v
+	case gltfUnsignedByte:
+		v := float32(data[0])
+		if normalized {
+			return v / 255
+		}
+		return v
+	case gltfShort:
+		v := float32(int16(binary.LittleEndian.Uint16(data)))
+		if normalized {
+			return float32(math.Max(float64(v)/32767, -1))
+		}
+		return v
+	case gltfUnsignedShort:
+		v := float32(binary.LittleEndian.Uint16(data))
+		if normalized {
+			return v / 65535
+		}
+		return v
+	case gltfUnsignedInt:
+		return float32(binary.LittleEndian.Uint32(data))
+	case gltfFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(data))
+	}
+	return 0
+}
+
+func readGLTFIndex(data []byte, componentType int) uint32 {
+	switch componentType {
+	case gltfUnsignedByte:
+		return uint32(data[0])
+	case gltfUnsignedShort:
+		return uint32(binary.LittleEndian.Uint16(data))
+	case gltfUnsignedInt:
+		return binary.LittleEndian.Uint32(data)
+	}
+	return 0
+}
+
+// checkGLTFElements returns an error if count elements don't fit in size bytes, which is checked
+// before anything is allocated for them
+func checkGLTFElements(size, offset, stride, elemSize, count int) error {
+	if stride == 0 {
+		stride = elemSize
+	}
+	if count < 0 || elemSize <= 0 || stride < 0 {
+		return fmt.Errorf("invalid count %v or stride %v", count, stride)
+	}
+	// Dividing instead of multiplying keeps huge counts from overflowing
+	if count > 0 && (offset < 0 || offset > size-elemSize || (count-1) > (size-offset-elemSize)/stride) {
+		return fmt.Errorf("accessor out of bounds")
+	}
+	return nil
+}
+
+// readElements calls fn with the bytes of each element of a strided view, after checking bounds
+func readGLTFElements(data []byte, offset, stride, elemSize, count int, fn func(i int, elem []byte)) error {
+	if err := checkGLTFElements(len(data), offset, stride, elemSize, count); err != nil {
+		return err
+	}
+	if stride == 0 {
+		stride = elemSize
+	}
+	for i := 0; i < count; i++ {
+		start := offset + stride*i
+		fn(i, data[start:start+elemSize])
+	}
+	return nil
+}
+
+// accessorFloats reads an accessor as floats, returning them and the number of components per
+// element. Sparse accessors are applied over their base values.
+func (loader *gltfLoader) accessorFloats(index int) ([]float32, int, error) {
+	if index < 0 || index >= len(loader.doc.Accessors) {
+		return nil, 0, fmt.Errorf("accessor %v out of range", index)
+	}
+	acc := loader.doc.Accessors[index]
+
+	comps := gltfTypeComponents[acc.Type]
+	compSize := gltfComponentSize(acc.ComponentType)
+	if comps == 0 || compSize == 0 {
+		return nil, 0, fmt.Errorf("accessor %v has unsupported type %v/%v", index, acc.Type, acc.ComponentType)
+	}
+	elemSize := comps * compSize
+
+	// The count is checked against the data before values are allocated for it. Accessors without
+	// a buffer view are zeros, unless sparse values replace them.
+	var data []byte
+	stride := 0
+	if acc.BufferView != nil {
+		var err error
+		if data, stride, err = loader.bufferView(*acc.BufferView); err != nil {
+			return nil, 0, err
+		}
+		if err := checkGLTFElements(len(data), acc.ByteOffset, stride, elemSize, acc.Count); err != nil {
+			return nil, 0, fmt.Errorf("accessor %v: %v", index, err)
+		}
+	} else if acc.Count < 0 || acc.Count > GLTF_MAX_ZERO_ELEMENTS {
+		return nil, 0, fmt.Errorf("accessor %v has invalid count %v", index, acc.Count)
+	}
+	values := make([]float32, acc.Count*comps)
+
+	readInto := func(data []byte, offset, stride, count int, dst func(i int) int) error {
+		return readGLTFElements(data, offset, stride, elemSize, count, func(i int, elem []byte) {
+			base := dst(i)
+			for c := 0; c < comps; c++ {
+				values[base+c] = readGLTFComponent(elem[c*compSize:], acc.ComponentType, acc.Normalized)
+			}
+		})
+	}
+
+	if acc.BufferView != nil {
+		err := readInto(data, acc.ByteOffset, stride, acc.Count, func(i int) int { return i * comps })
+		if err != nil {
+			return nil, 0, fmt.Errorf("accessor %v: %v", index, err)
+		}
+	}
+
+	if sparse := acc.Sparse; sparse != nil {
+		indexData, _, err := loader.bufferView(sparse.Indices.BufferView)
+		if err != nil {
+			return nil, 0, err
+		}
+		valueData, _, err := loader.bufferView(sparse.Values.BufferView)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		indexSize := gltfComponentSize(sparse.Indices.ComponentType)
+		if sparse.Count < 0 || sparse.Count > acc.Count || indexSize == 0 {
+			return nil, 0, fmt.Errorf("accessor %v has an invalid sparse count or index type", index)
+		}
+		if err := checkGLTFElements(len(indexData), sparse.Indices.ByteOffset, 0, indexSize, sparse.Count); err != nil {
+			return nil, 0, fmt.Errorf("accessor %v: %v", index, err)
+		}
+		targets := make([]int, sparse.Count)
+		err = readGLTFElements(indexData, sparse.Indices.ByteOffset, 0, indexSize, sparse.Count, func(i int, elem []byte) {
+			targets[i] = int(readGLTFIndex(elem, sparse.Indices.ComponentType))
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("accessor %v: %v", index, err)
+		}
+		for _, target := range targets {
+			if target >= acc.Count {
+				return nil, 0, fmt.Errorf("accessor %v: sparse index %v out of range", index, target)
+			}
+		}
+
+		err = readInto(valueData, sparse.Values.ByteOffset, 0, sparse.Count, func(i int) int { return targets[i] * comps })
+		if err != nil {
+			return nil, 0, fmt.Errorf("accessor %v: %v", index, err)
+		}
+	}
+
+	return values, comps, nil
+}
+
+// accessorIndices reads an accessor of unsigned integers, e.g. vertex indices or joints
+func (loader *gltfLoader) accessorIndices(index int) ([]uint32, error) {
+	if index < 0 || index >= len(loader.doc.Accessors) {
+		return nil, fmt.Errorf("accessor %v out of range", index)
+	}
+	acc := loader.doc.Accessors[index]
+
+	switch acc.ComponentType {
+	case gltfUnsignedByte, gltfUnsignedShort, gltfUnsignedInt:
+	default:
+		// Anything else goes through the float path, which also handles sparse accessors
+		values, _, err := loader.accessorFloats(index)
+		if err != nil {
+			return nil, err
+		}
+		indices := make([]uint32, len(values))
+		for i, v := range values {
+			indices[i] = uint32(v)
+		}
+		return indices, nil
+	}
+
+	if acc.Sparse != nil || acc.BufferView == nil {
+		values, _, err := loader.accessorFloats(index)
+		if err != nil {
+			return nil, err
+		}
+		indices := make([]uint32, len(values))
+		for i, v := range values {
+			indices[i] = uint32(v)
+		}
+		return indices, nil
+	}
+
+	comps := gltfTypeComponents[acc.Type]
+	compSize := gltfComponentSize(acc.ComponentType)
+	if comps == 0 {
+		return nil, fmt.Errorf("accessor %v has unsupported type %v", index, acc.Type)
+	}
+
+	data, stride, err := loader.bufferView(*acc.BufferView)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkGLTFElements(len(data), acc.ByteOffset, stride, comps*compSize, acc.Count); err != nil {
+		return nil, fmt.Errorf("accessor %v: %v", index, err)
+	}
+	indices := make([]uint32, acc.Count*comps)
+	err = readGLTFElements(data, acc.ByteOffset, stride, comps*compSize, acc.Count, func(i int, elem []byte) {
+		for c := 0; c < comps; c++ {
+			indices[i*comps+c] = readGLTFIndex(elem[c*compSize:], acc.ComponentType)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("accessor %v: %v", index, err)
+	}
+	return indices, nil
+}
+
+// textureMap resolves a texture reference to an image file or embedded image data
+func (loader *gltfLoader) textureMap(info *gltfTextureInfo) (TextureMap, error) {
+	texMap := TextureMap{}
+	if info == nil {
+		return texMap, nil
+	}
+
+	doc := &loader.doc
+	if info.Index < 0 || info.Index >= len(doc.Textures) {
+		return texMap, fmt.Errorf("texture %v out of range", info.Index)
+	}
+	if info.TexCoord != 0 && !loader.warnedUVs {
+		LogWarn("Only the first set of texture coordinates is supported in '%v'", loader.filename)
+		loader.warnedUVs = true
+	}
+
+	texture := doc.Textures[info.Index]
+	if texture.Source == nil || *texture.Source < 0 || *texture.Source >= len(doc.Images) {
+		return texMap, fmt.Errorf("texture %v has no valid image", info.Index)
+	}
+	source := *texture.Source
+	image := doc.Images[source]
+
+	// The extension of the name selects the decoder for embedded images
+	ext := ".png"
+	switch image.MimeType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/ktx2":
+		ext = ".ktx2"
+	}
+
+	switch {
+	case image.BufferView != nil:
+		data, _, err := loader.bufferView(*image.BufferView)
+		if err != nil {
+			return texMap, err
+		}
+		texMap.Filename = fmt.Sprintf("%v#image%v%v", loader.filename, source, ext)
+		texMap.Data = data
+	case strings.HasPrefix(image.URI, "data:"):
+		data, err := loader.loadURI(image.URI)
+		if err != nil {
+			return texMap, fmt.Errorf("image %v: %v", source, err)
+		}
+		if strings.HasPrefix(image.URI, "data:image/jpeg") {
+			ext = ".jpg"
+		}
+		texMap.Filename = fmt.Sprintf("%v#image%v%v", loader.filename, source, ext)
+		texMap.Data = data
+	default:
+		filename, err := url.PathUnescape(image.URI)
+		if err != nil {
+			filename = image.URI
+		}
+		texMap.Filename = path.Join(loader.dirname, filename)
+	}
+
+	if texture.Sampler != nil && *texture.Sampler >= 0 && *texture.Sampler < len(doc.Samplers) {
+		sampler := doc.Samplers[*texture.Sampler]
+		opts := loader.app.TextureOptions
+		if sampler.MinFilter != 0 {
+			opts.MinFilter = sampler.MinFilter
+		}
+		if sampler.MagFilter != 0 {
+			opts.MagFilter = sampler.MagFilter
+		}
+		if sampler.WrapS != 0 {
+			opts.WrapS = sampler.WrapS
+		}
+		if sampler.WrapT != 0 {
+			opts.WrapT = sampler.WrapT
+		}
+		texMap.Options = &opts
+	}
+
+	return texMap, nil
+}
+
+// loadMaterials converts metallic-roughness materials into MaterialDefs, with Phong parameters
+// approximated from them for shaders that don't support PBR
+func (loader *gltfLoader) loadMaterials() error {
+	names := map[string]bool{}
+
+	for i, gmat := range loader.doc.Materials {
+		def := NewMaterialDef()
+
+		baseColor := mgl32.Vec4{1, 1, 1, 1}
+		def.Metallic = 1
+		def.Roughness = 1
+
+		var baseColorTexture, metallicRoughnessTexture *gltfTextureInfo
+		if pbr := gmat.PbrMetallicRoughness; pbr != nil {
+			if len(pbr.BaseColorFactor) == 4 {
+				copy(baseColor[:], pbr.BaseColorFactor)
+			}
+			if pbr.MetallicFactor != nil {
+				def.Metallic = *pbr.MetallicFactor
+			}
+			if pbr.RoughnessFactor != nil {
+				def.Roughness = *pbr.RoughnessFactor
+			}
+			baseColorTexture = pbr.BaseColorTexture
+			metallicRoughnessTexture = pbr.MetallicRoughnessTexture
+		}
+
+		var err error
+		if def.DiffuseMap, err = loader.textureMap(baseColorTexture); err != nil {
+			return err
+		}
+		if def.MetallicRoughnessMap, err = loader.textureMap(metallicRoughnessTexture); err != nil {
+			return err
+		}
+		if def.NormalMap, err = loader.textureMap(gmat.NormalTexture); err != nil {
+			return err
+		}
+		if gmat.NormalTexture != nil && gmat.NormalTexture.Scale != nil {
+			def.NormalMap.BumpMultiplier = *gmat.NormalTexture.Scale
+		}
+		if def.OcclusionMap, err = loader.textureMap(gmat.OcclusionTexture); err != nil {
+			return err
+		}
+		if def.EmissiveMap, err = loader.textureMap(gmat.EmissiveTexture); err != nil {
+			return err
+		}
+
+		def.Diffuse = baseColor.Vec3()
+		def.Ambient = baseColor.Vec3().Mul(0.1)
+		if len(gmat.EmissiveFactor) == 3 {
+			copy(def.Emissive[:], gmat.EmissiveFactor)
+		}
+
+		// Dielectrics reflect about 4%, metals reflect their base color
+		dielectric := mgl32.Vec3{0.04, 0.04, 0.04}
+		def.Specular = dielectric.Mul(1 - def.Metallic).Add(def.Diffuse.Mul(def.Metallic))
+		def.Shininess = gltfShininess(def.Roughness)
+
+		switch gmat.AlphaMode {
+		case "BLEND":
+			def.Dissolve = baseColor[3]
+			def.AlphaMap = def.DiffuseMap
+			def.AlphaMap.Channel = "m"
+		case "MASK":
+			def.AlphaCutoff = 0.5
+			if gmat.AlphaCutoff != nil {
+				def.AlphaCutoff = *gmat.AlphaCutoff
+			}
+			def.AlphaMap = def.DiffuseMap
+			def.AlphaMap.Channel = "m"
+		}
+		def.DoubleSided = gmat.DoubleSided
+
+		name := gmat.Name
+		if name == "" {
+			name = fmt.Sprintf("material_%v", i)
+		} else if names[name] {
+			name = fmt.Sprintf("%v_%v", name, i)
+		}
+		names[name] = true
+
+		loader.materialNames = append(loader.materialNames, name)
+		loader.mesh.Materials[name] = def
+	}
+
+	return nil
+}
+
+// gltfShininess approximates the Phong exponent with the same highlight as roughness
+func gltfShininess(roughness float32) float32 {
+	r4 := roughness * roughness * roughness * roughness
+	return mgl32.Clamp(2/float32(math.Max(float64(r4), 1e-4))-2, 1, 1024)
+}
+
+func (loader *gltfLoader) loadNodes() error {
+	doc := &loader.doc
+	mesh := loader.mesh
+	names := map[string]bool{}
+
+	mesh.Nodes = make([]MeshNode, len(doc.Nodes))
+	for i := range mesh.Nodes {
+		mesh.Nodes[i].Parent = -1
+	}
+
+	for i, gnode := range doc.Nodes {
+		node := &mesh.Nodes[i]
+		node.Name = gnode.Name
+		if node.Name == "" {
+			node.Name = fmt.Sprintf("node_%v", i)
+		} else if names[node.Name] {
+			node.Name = fmt.Sprintf("%v_%v", node.Name, i)
+		}
+		names[node.Name] = true
+
+		node.Children = gnode.Children
+		for _, child := range gnode.Children {
+			if child < 0 || child >= len(mesh.Nodes) || child == i {
+				return fmt.Errorf("node %v has invalid child %v", i, child)
+			}
+			mesh.Nodes[child].Parent = i
+		}
+
+		node.Translation = mgl32.Vec3{0, 0, 0}
+		node.Rotation = mgl32.QuatIdent()
+		node.Scale = mgl32.Vec3{1, 1, 1}
+		if len(gnode.Matrix) == 16 {
+			var matrix mgl32.Mat4
+			copy(matrix[:], gnode.Matrix)
+			node.Translation, node.Rotation, node.Scale = decomposeTransform(matrix)
+		}
+		if len(gnode.Translation) == 3 {
+			copy(node.Translation[:], gnode.Translation)
+		}
+		if len(gnode.Rotation) == 4 {
+			node.Rotation = mgl32.Quat{W: gnode.Rotation[3], V: mgl32.Vec3{gnode.Rotation[0], gnode.Rotation[1], gnode.Rotation[2]}}
+		}
+		if len(gnode.Scale) == 3 {
+			copy(node.Scale[:], gnode.Scale)
+		}
+
+		node.Skin = -1
+		if gnode.Skin != nil {
+			node.Skin = *gnode.Skin
+		}
+	}
+
+	// Reject cycles, which would hang anything walking up the hierarchy
+	for i := range mesh.Nodes {
+		depth := 0
+		for p := mesh.Nodes[i].Parent; p >= 0; p = mesh.Nodes[p].Parent {
+			depth++
+			if depth > len(mesh.Nodes) {
+				return fmt.Errorf("node %v is part of a cycle", i)
+			}
+		}
+	}
+
+	for i, gnode := range doc.Nodes {
+		if gnode.Mesh == nil {
+			continue
+		}
+		if err := loader.loadMesh(*gnode.Mesh, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gltfPrimitive holds the vertices of one primitive before they are merged into the mesh
+type gltfPrimitive struct {
+	Positions []mgl32.Vec3
+	Normals   []mgl32.Vec3
+	TexCoords []mgl32.Vec2
+	Tangents  []mgl32.Vec4
+	Colors    []mgl32.Vec4
+	Joints    [][4]uint16
+	Weights   []mgl32.Vec4
+	Indices   []uint32
+}
+
+// vectors reads an attribute as vectors of up to four components, missing ones are 0 except w.
+// A negative count accepts any number of elements.
+func (loader *gltfLoader) vectors(attributes map[string]int, name string, count, minComps int) ([]mgl32.Vec4, error) {
+	accessor, ok := attributes[name]
+	if !ok {
+		return nil, nil
+	}
+
+	values, comps, err := loader.accessorFloats(accessor)
+	if err != nil {
+		return nil, err
+	}
+	if comps < minComps || comps > 4 {
+		return nil, fmt.Errorf("attribute %v has %v components", name, comps)
+	}
+	if count < 0 {
+		count = len(values) / comps
+	} else if len(values)/comps != count {
+		return nil, fmt.Errorf("attribute %v has %v elements, expected %v", name, len(values)/comps, count)
+	}
+
+	vectors := make([]mgl32.Vec4, count)
+	for i := range vectors {
+		vectors[i][3] = 1
+		copy(vectors[i][:], values[i*comps:i*comps+comps])
+	}
+	return vectors, nil
+}
+
+// readPrimitive reads a primitive's vertices and converts its indices to a triangle list
+func (loader *gltfLoader) readPrimitive(attributes map[string]int, indices *int, mode int) (*gltfPrimitive, error) {
+	prim := &gltfPrimitive{}
+
+	// The position accessor sets the vertex count for the other attributes
+	positions, err := loader.vectors(attributes, "POSITION", -1, 3)
+	if err != nil {
+		return nil, err
+	}
+	count := len(positions)
+	prim.Positions = make([]mgl32.Vec3, count)
+	for i, v := range positions {
+		prim.Positions[i] = v.Vec3()
+	}
+
+	normals, err := loader.vectors(attributes, "NORMAL", count, 3)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range normals {
+		prim.Normals = append(prim.Normals, v.Vec3())
+	}
+
+	txcds, err := loader.vectors(attributes, "TEXCOORD_0", count, 2)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range txcds {
+		prim.TexCoords = append(prim.TexCoords, mgl32.Vec2{v[0], 1 - v[1]})
+	}
+
+	// Tangents already have the bitangent pointing up the image, as the flipped coordinates do
+	if prim.Tangents, err = loader.vectors(attributes, "TANGENT", count, 4); err != nil {
+		return nil, err
+	}
+
+	if prim.Colors, err = loader.vectors(attributes, "COLOR_0", count, 3); err != nil {
+		return nil, err
+	}
+
+	joints, err := loader.vectors(attributes, "JOINTS_0", count, 4)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range joints {
+		prim.Joints = append(prim.Joints, [4]uint16{uint16(v[0]), uint16(v[1]), uint16(v[2]), uint16(v[3])})
+	}
+
+	if prim.Weights, err = loader.vectors(attributes, "WEIGHTS_0", count, 4); err != nil {
+		return nil, err
+	}
+
+	var source []uint32
+	if indices != nil {
+		source, err = loader.accessorIndices(*indices)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		source = make([]uint32, count)
+		for i := range source {
+			source[i] = uint32(i)
+		}
+	}
+
+	emit := func(a, b, c uint32) {
+		if a < uint32(count) && b < uint32(count) && c < uint32(count) {
+			prim.Indices = append(prim.Indices, a, b, c)
+		}
+	}
+	switch mode {
+	case gltfTriangles:
+		for i := 0; i+2 < len(source); i += 3 {
+			emit(source[i], source[i+1], source[i+2])
+		}
+	case gltfTriangleStrip:
+		for i := 0; i+2 < len(source); i++ {
+			// Every other triangle of a strip is flipped to keep the winding
+			if i%2 == 0 {
+				emit(source[i], source[i+1], source[i+2])
+			} else {
+				emit(source[i+1], source[i], source[i+2])
+			}
+		}
+	case gltfTriangleFan:
+		for i := 1; i+1 < len(source); i++ {
+			emit(source[0], source[i], source[i+1])
+		}
+	}
+
+	// The spec requires flat normals when there are none, and MikkTSpace tangents
+	if prim.Normals == nil || (prim.Tangents == nil && prim.TexCoords != nil) {
+		generated := &MeshData{
+			Positions: prim.Positions,
+			Normals:   prim.Normals,
+			TexCoords: prim.TexCoords,
+			Tangents:  prim.Tangents,
+			Colors:    prim.Colors,
+			Joints:    prim.Joints,
+			Weights:   prim.Weights,
+			Indices:   prim.Indices,
+		}
+		if prim.Normals == nil {
+			generated.SmoothingGroups = make([]uint32, len(prim.Indices)/3)
+			generated.GenerateNormals()
+		}
+		if prim.Tangents == nil {
+			generated.GenerateTangents()
+		}
+		*prim = gltfPrimitive{
+			Positions: generated.Positions,
+			Normals:   generated.Normals,
+			TexCoords: generated.TexCoords,
+			Tangents:  generated.Tangents,
+			Colors:    generated.Colors,
+			Joints:    generated.Joints,
+			Weights:   generated.Weights,
+			Indices:   generated.Indices,
+		}
+	}
+
+	return prim, nil
+}
+
+// loadMesh appends the primitives of a mesh as submeshes placed by a node
+func (loader *gltfLoader) loadMesh(index, node int) error {
+	doc := &loader.doc
+	mesh := loader.mesh
+
+	if index < 0 || index >= len(doc.Meshes) {
+		return fmt.Errorf("mesh %v out of range", index)
+	}
+
+	for p, gprim := range doc.Meshes[index].Primitives {
+		mode := gltfTriangles
+		if gprim.Mode != nil {
+			mode = *gprim.Mode
+		}
+		if mode != gltfTriangles && mode != gltfTriangleStrip && mode != gltfTriangleFan {
+			LogWarn("Skipping mesh %v primitive %v of '%v', only triangles are supported", index, p, loader.filename)
+			continue
+		}
+		if _, ok := gprim.Attributes["POSITION"]; !ok {
+			continue
+		}
+
+		prim, err := loader.readPrimitive(gprim.Attributes, gprim.Indices, mode)
+		if err != nil {
+			return fmt.Errorf("mesh %v primitive %v: %v", index, p, err)
+		}
+
+		base := mesh.VertexCount()
+		count := len(prim.Positions)
+
+		// Attributes only some primitives have are padded with defaults, zero tangents make the
+		// shader ignore normal maps
+		mesh.Positions = append(mesh.Positions, prim.Positions...)
+		mesh.Normals = append(mesh.Normals, prim.Normals...)
+
+		if prim.TexCoords != nil || len(mesh.TexCoords) > 0 {
+			mesh.TexCoords = append(mesh.TexCoords, make([]mgl32.Vec2, base-len(mesh.TexCoords))...)
+			if prim.TexCoords == nil {
+				prim.TexCoords = make([]mgl32.Vec2, count)
+			}
+			mesh.TexCoords = append(mesh.TexCoords, prim.TexCoords...)
+		}
+
+		if prim.Tangents != nil || len(mesh.Tangents) > 0 {
+			mesh.Tangents = append(mesh.Tangents, make([]mgl32.Vec4, base-len(mesh.Tangents))...)
+			if prim.Tangents == nil {
+				prim.Tangents = make([]mgl32.Vec4, count)
+			}
+			mesh.Tangents = append(mesh.Tangents, prim.Tangents...)
+		}
+
+		if prim.Colors != nil || len(mesh.Colors) > 0 {
+			for len(mesh.Colors) < base {
+				mesh.Colors = append(mesh.Colors, mgl32.Vec4{1, 1, 1, 1})
+			}
+			for len(prim.Colors) < count {
+				prim.Colors = append(prim.Colors, mgl32.Vec4{1, 1, 1, 1})
+			}
+			mesh.Colors = append(mesh.Colors, prim.Colors...)
+		}
+
+		if prim.Joints != nil || len(mesh.Joints) > 0 {
+			mesh.Joints = append(mesh.Joints, make([][4]uint16, base-len(mesh.Joints))...)
+			if prim.Joints == nil {
+				prim.Joints = make([][4]uint16, count)
+			}
+			mesh.Joints = append(mesh.Joints, prim.Joints...)
+		}
+
+		if prim.Weights != nil || len(mesh.Weights) > 0 {
+			mesh.Weights = append(mesh.Weights, make([]mgl32.Vec4, base-len(mesh.Weights))...)
+			if prim.Weights == nil {
+				prim.Weights = make([]mgl32.Vec4, count)
+			}
+			mesh.Weights = append(mesh.Weights, prim.Weights...)
+		}
+
+		start := len(mesh.Indices)
+		for _, i := range prim.Indices {
+			mesh.Indices = append(mesh.Indices, uint32(base)+i)
+		}
+
+		material := ""
+		if gprim.Material != nil {
+			if *gprim.Material < 0 || *gprim.Material >= len(loader.materialNames) {
+				return fmt.Errorf("material %v out of range", *gprim.Material)
+			}
+			material = loader.materialNames[*gprim.Material]
+		}
+
+		mesh.Submeshes = append(mesh.Submeshes, Submesh{
+			Name:     mesh.Nodes[node].Name,
+			Start:    start,
+			Count:    len(mesh.Indices) - start,
+			Material: material,
+			Node:     node,
+		})
+	}
+
+	return nil
+}
+
+func (loader *gltfLoader) loadSkins() error {
+	for i, gskin := range loader.doc.Skins {
+		skin := MeshSkin{
+			Name:     gskin.Name,
+			Joints:   gskin.Joints,
+			Skeleton: -1,
+		}
+		if gskin.Skeleton != nil {
+			skin.Skeleton = *gskin.Skeleton
+		}
+		for _, joint := range skin.Joints {
+			if joint < 0 || joint >= len(loader.mesh.Nodes) {
+				return fmt.Errorf("skin %v has invalid joint %v", i, joint)
+			}
+		}
+
+		skin.InverseBindMatrices = make([]mgl32.Mat4, len(skin.Joints))
+		for j := range skin.InverseBindMatrices {
+			skin.InverseBindMatrices[j] = mgl32.Ident4()
+		}
+		if gskin.InverseBindMatrices != nil {
+			values, comps, err := loader.accessorFloats(*gskin.InverseBindMatrices)
+			if err != nil {
+				return err
+			}
+			if comps != 16 || len(values)/16 < len(skin.Joints) {
+				return fmt.Errorf("skin %v has invalid inverse bind matrices", i)
+			}
+			for j := range skin.InverseBindMatrices {
+				copy(skin.InverseBindMatrices[j][:], values[j*16:])
+			}
+		}
+
+		loader.mesh.Skins = append(loader.mesh.Skins, skin)
+	}
+
+	for i := range loader.mesh.Nodes {
+		if skin := loader.mesh.Nodes[i].Skin; skin >= len(loader.mesh.Skins) {
+			return fmt.Errorf("node %v has invalid skin %v", i, skin)
+		}
+	}
+
+	return nil
+}
+
+func (loader *gltfLoader) loadAnimations() error {
+	for a, ganim := range loader.doc.Animations {
+		anim := MeshAnimation{
+			Name: ganim.Name,
+		}
+		if anim.Name == "" {
+			anim.Name = fmt.Sprintf("animation_%v", a)
+		}
+
+		for s, gsampler := range ganim.Samplers {
+			times, _, err := loader.accessorFloats(gsampler.Input)
+			if err != nil {
+				return err
+			}
+			values, _, err := loader.accessorFloats(gsampler.Output)
+			if err != nil {
+				return err
+			}
+
+			interpolation := gsampler.Interpolation
+			if interpolation == "" {
+				interpolation = INTERPOLATION_LINEAR
+			}
+
+			perKey := 1
+			if interpolation == INTERPOLATION_CUBICSPLINE {
+				perKey = 3
+			}
+			if len(times) == 0 || len(values)%(len(times)*perKey) != 0 {
+				return fmt.Errorf("animation %v sampler %v has mismatched keyframes", a, s)
+			}
+
+			anim.Samplers = append(anim.Samplers, AnimationSampler{
+				Times:         times,
+				Values:        values,
+				Components:    len(values) / (len(times) * perKey),
+				Interpolation: interpolation,
+			})
+		}
+
+		for _, gchannel := range ganim.Channels {
+			// Channels without a node target extensions, which aren't supported
+			if gchannel.Target.Node == nil {
+				continue
+			}
+			node := *gchannel.Target.Node
+			if node < 0 || node >= len(loader.mesh.Nodes) || gchannel.Sampler < 0 || gchannel.Sampler >= len(anim.Samplers) {
+				return fmt.Errorf("animation %v has an invalid channel", a)
+			}
+			anim.Channels = append(anim.Channels, AnimationChannel{
+				Node:    node,
+				Path:    gchannel.Target.Path,
+				Sampler: gchannel.Sampler,
+			})
+		}
+
+		loader.mesh.Animations = append(loader.mesh.Animations, anim)
+	}
+
+	return nil
+}
+
+// decomposeTransform splits an affine matrix without shear into translation, rotation and scale
+func decomposeTransform(matrix mgl32.Mat4) (mgl32.Vec3, mgl32.Quat, mgl32.Vec3) {
+	translation := matrix.Col(3).Vec3()
+
+	scale := mgl32.Vec3{
+		matrix.Col(0).Vec3().Len(),
+		matrix.Col(1).Vec3().Len(),
+		matrix.Col(2).Vec3().Len(),
+	}
+	if matrix.Mat3().Det() < 0 {
+		scale[0] = -scale[0]
+	}
+
+	rotation := mgl32.Ident3()
+	for c := 0; c < 3; c++ {
+		if scale[c] != 0 {
+			rotation.SetCol(c, matrix.Col(c).Vec3().Mul(1/scale[c]))
+		}
+	}
+
+	return translation, mgl32.Mat4ToQuat(rotation.Mat4()).Normalize(), scale
+}
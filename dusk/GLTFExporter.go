@@ -0,0 +1,672 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Types for writing glTF files, only the properties the exporter sets are included
+type gltfOutTextureInfo struct {
+	Index int      `json:"index"`
+	Scale *float32 `json:"scale,omitempty"`
+}
+
+type gltfOutPBR struct {
+	BaseColorFactor  [4]float32          `json:"baseColorFactor"`
+	BaseColorTexture *gltfOutTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float32             `json:"metallicFactor"`
+	RoughnessFactor  float32             `json:"roughnessFactor"`
+	MetallicTexture  *gltfOutTextureInfo `json:"metallicRoughnessTexture,omitempty"`
+}
+
+type gltfOutMaterial struct {
+	Name             string              `json:"name,omitempty"`
+	PBR              gltfOutPBR          `json:"pbrMetallicRoughness"`
+	NormalTexture    *gltfOutTextureInfo `json:"normalTexture,omitempty"`
+	OcclusionTexture *gltfOutTextureInfo `json:"occlusionTexture,omitempty"`
+	EmissiveTexture  *gltfOutTextureInfo `json:"emissiveTexture,omitempty"`
+	EmissiveFactor   *mgl32.Vec3         `json:"emissiveFactor,omitempty"`
+	AlphaMode        string              `json:"alphaMode,omitempty"`
+	AlphaCutoff      *float32            `json:"alphaCutoff,omitempty"`
+	DoubleSided      bool                `json:"doubleSided,omitempty"`
+}
+
+type gltfOutPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+}
+
+type gltfOutMesh struct {
+	Name       string             `json:"name,omitempty"`
+	Primitives []gltfOutPrimitive `json:"primitives"`
+}
+
+type gltfOutNode struct {
+	Name        string      `json:"name,omitempty"`
+	Children    []int       `json:"children,omitempty"`
+	Translation *mgl32.Vec3 `json:"translation,omitempty"`
+	Rotation    *[4]float32 `json:"rotation,omitempty"`
+	Scale       *mgl32.Vec3 `json:"scale,omitempty"`
+	Mesh        *int        `json:"mesh,omitempty"`
+	Skin        *int        `json:"skin,omitempty"`
+}
+
+type gltfOutAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfOutBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfOutImage struct {
+	Name       string `json:"name,omitempty"`
+	URI        string `json:"uri,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+	BufferView *int   `json:"bufferView,omitempty"`
+}
+
+type gltfOutSampler struct {
+	MagFilter int32 `json:"magFilter,omitempty"`
+	MinFilter int32 `json:"minFilter,omitempty"`
+	WrapS     int32 `json:"wrapS"`
+	WrapT     int32 `json:"wrapT"`
+}
+
+type gltfOutTexture struct {
+	Sampler *int `json:"sampler,omitempty"`
+	Source  int  `json:"source"`
+}
+
+type gltfOutSkin struct {
+	Name                string `json:"name,omitempty"`
+	InverseBindMatrices *int   `json:"inverseBindMatrices,omitempty"`
+	Joints              []int  `json:"joints"`
+	Skeleton            *int   `json:"skeleton,omitempty"`
+}
+
+type gltfOutChannel struct {
+	Sampler int `json:"sampler"`
+	Target  struct {
+		Node int    `json:"node"`
+		Path string `json:"path"`
+	} `json:"target"`
+}
+
+type gltfOutAnimSampler struct {
+	Input         int    `json:"input"`
+	Output        int    `json:"output"`
+	Interpolation string `json:"interpolation,omitempty"`
+}
+
+type gltfOutAnimation struct {
+	Name     string               `json:"name,omitempty"`
+	Channels []gltfOutChannel     `json:"channels"`
+	Samplers []gltfOutAnimSampler `json:"samplers"`
+}
+
+type gltfOutBuffer struct {
+	URI        string `json:"uri,omitempty"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type gltfOutDocument struct {
+	Asset struct {
+		Version   string `json:"version"`
+		Generator string `json:"generator"`
+	} `json:"asset"`
+	Scene       int                 `json:"scene"`
+	Scenes      []map[string][]int  `json:"scenes"`
+	Nodes       []gltfOutNode       `json:"nodes"`
+	Meshes      []gltfOutMesh       `json:"meshes,omitempty"`
+	Materials   []gltfOutMaterial   `json:"materials,omitempty"`
+	Textures    []gltfOutTexture    `json:"textures,omitempty"`
+	Images      []gltfOutImage      `json:"images,omitempty"`
+	Samplers    []gltfOutSampler    `json:"samplers,omitempty"`
+	Skins       []gltfOutSkin       `json:"skins,omitempty"`
+	Animations  []gltfOutAnimation  `json:"animations,omitempty"`
+	Accessors   []gltfOutAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfOutBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfOutBuffer     `json:"buffers,omitempty"`
+}
+
+// gltfExporter builds a document and its binary buffer from MeshData
+type gltfExporter struct {
+	mesh    *MeshData
+	dirname string
+	doc     gltfOutDocument
+	bin     bytes.Buffer
+
+	materialIndices map[string]int
+	imageIndices    map[string]int
+	textureIndices  map[string]int
+}
+
+// SaveGLTF writes mesh to a .gltf file with an embedded buffer, or to a binary .glb file
+func SaveGLTF(filename string, mesh *MeshData) error {
+	LogInfo("Saving mesh '%v'", filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dirname := filepath.ToSlash(filepath.Dir(filename))
+	if strings.ToLower(filepath.Ext(filename)) == ".glb" {
+		return WriteGLB(file, mesh, dirname)
+	}
+	return WriteGLTF(file, mesh, dirname)
+}
+
+// WriteGLTF writes mesh as glTF 2.0 JSON with the buffer embedded as a data URI. Texture paths
+// are written relative to dirname, and embedded textures are stored in the buffer.
+func WriteGLTF(w io.Writer, mesh *MeshData, dirname string) error {
+	exporter := newGLTFExporter(mesh, dirname)
+	if exporter.bin.Len() > 0 {
+		exporter.doc.Buffers = []gltfOutBuffer{{
+			URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(exporter.bin.Bytes()),
+			ByteLength: exporter.bin.Len(),
+		}}
+	}
+
+	data, err := json.MarshalIndent(&exporter.doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteGLB writes mesh as a binary glTF file, see WriteGLTF
+func WriteGLB(w io.Writer, mesh *MeshData, dirname string) error {
+	exporter := newGLTFExporter(mesh, dirname)
+	if exporter.bin.Len() > 0 {
+		exporter.doc.Buffers = []gltfOutBuffer{{ByteLength: exporter.bin.Len()}}
+	}
+
+	data, err := json.Marshal(&exporter.doc)
+	if err != nil {
+		return err
+	}
+
+	// Chunks are padded to four bytes, JSON with spaces and binary data with zeros
+	for len(data)%4 != 0 {
+		data = append(data, ' ')
+	}
+	bin := exporter.bin.Bytes()
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+
+	length := 12 + 8 + len(data)
+	if len(bin) > 0 {
+		length += 8 + len(bin)
+	}
+
+	out := &bytes.Buffer{}
+	binary.Write(out, binary.LittleEndian, []uint32{GLB_MAGIC, 2, uint32(length)})
+	binary.Write(out, binary.LittleEndian, []uint32{uint32(len(data)), GLB_CHUNK_JSON})
+	out.Write(data)
+	if len(bin) > 0 {
+		binary.Write(out, binary.LittleEndian, []uint32{uint32(len(bin)), GLB_CHUNK_BIN})
+		out.Write(bin)
+	}
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func newGLTFExporter(mesh *MeshData, dirname string) *gltfExporter {
+	exporter := &gltfExporter{
+		mesh:            mesh,
+		dirname:         dirname,
+		materialIndices: map[string]int{},
+		imageIndices:    map[string]int{},
+		textureIndices:  map[string]int{},
+	}
+	exporter.doc.Asset.Version = "2.0"
+	exporter.doc.Asset.Generator = "Dusk"
+
+	exporter.exportMaterials()
+	exporter.exportNodes()
+	exporter.exportSkins()
+	exporter.exportAnimations()
+	return exporter
+}
+
+// addView appends data to the buffer, aligned to four bytes, and returns its buffer view
+func (exporter *gltfExporter) addView(data []byte, target int) int {
+	for exporter.bin.Len()%4 != 0 {
+		exporter.bin.WriteByte(0)
+	}
+	exporter.doc.BufferViews = append(exporter.doc.BufferViews, gltfOutBufferView{
+		ByteOffset: exporter.bin.Len(),
+		ByteLength: len(data),
+		Target:     target,
+	})
+	exporter.bin.Write(data)
+	return len(exporter.doc.BufferViews) - 1
+}
+
+// addFloats adds an accessor for float values with the given number of components
+func (exporter *gltfExporter) addFloats(values []float32, typ string, target int, bounds bool) int {
+	comps := gltfTypeComponents[typ]
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+
+	accessor := gltfOutAccessor{
+		BufferView:    exporter.addView(data, target),
+		ComponentType: gltfFloat,
+		Count:         len(values) / comps,
+		Type:          typ,
+	}
+
+	// Positions and animation times need their range
+	if bounds && len(values) > 0 {
+		accessor.Min = append([]float32{}, values[:comps]...)
+		accessor.Max = append([]float32{}, values[:comps]...)
+		for i, v := range values {
+			c := i % comps
+			accessor.Min[c] = mgl32.Clamp(accessor.Min[c], -math.MaxFloat32, v)
+			accessor.Max[c] = mgl32.Clamp(accessor.Max[c], v, math.MaxFloat32)
+		}
+	}
+
+	exporter.doc.Accessors = append(exporter.doc.Accessors, accessor)
+	return len(exporter.doc.Accessors) - 1
+}
+
+// addIndices adds an accessor for unsigned short or int values
+func (exporter *gltfExporter) addIndices(values []uint32, typ string, target int, short bool) int {
+	var data []byte
+	componentType := gltfUnsignedInt
+	if short {
+		componentType = gltfUnsignedShort
+		data = make([]byte, len(values)*2)
+		for i, v := range values {
+			binary.LittleEndian.PutUint16(data[i*2:], uint16(v))
+		}
+	} else {
+		data = make([]byte, len(values)*4)
+		for i, v := range values {
+			binary.LittleEndian.PutUint32(data[i*4:], v)
+		}
+	}
+
+	exporter.doc.Accessors = append(exporter.doc.Accessors, gltfOutAccessor{
+		BufferView:    exporter.addView(data, target),
+		ComponentType: componentType,
+		Count:         len(values) / gltfTypeComponents[typ],
+		Type:          typ,
+	})
+	return len(exporter.doc.Accessors) - 1
+}
+
+// texture returns the glTF texture for a map, or nil if the map is unused
+func (exporter *gltfExporter) texture(texMap *TextureMap) *gltfOutTextureInfo {
+	if texMap.Filename == "" && texMap.Data == nil {
+		return nil
+	}
+
+	opts := texMap.Options
+	if opts == nil && (texMap.Clamp || texMap.Mirror) {
+		defaults := DefaultTextureOptions()
+		opts = &defaults
+	}
+	var sampler *gltfOutSampler
+	if opts != nil {
+		sampler = &gltfOutSampler{
+			MagFilter: opts.MagFilter,
+			MinFilter: opts.MinFilter,
+			WrapS:     opts.WrapS,
+			WrapT:     opts.WrapT,
+		}
+		if texMap.Clamp {
+			sampler.WrapS, sampler.WrapT = gl.CLAMP_TO_EDGE, gl.CLAMP_TO_EDGE
+		} else if texMap.Mirror {
+			sampler.WrapS, sampler.WrapT = gl.MIRRORED_REPEAT, gl.MIRRORED_REPEAT
+		}
+	}
+
+	// Images are shared by filename, textures by image and sampler
+	image, ok := exporter.imageIndices[texMap.Filename]
+	if !ok {
+		out := gltfOutImage{Name: path.Base(texMap.Filename)}
+		if texMap.Data != nil {
+			view := exporter.addView(texMap.Data, 0)
+			out.BufferView = &view
+			out.MimeType = "image/png"
+			if ext := strings.ToLower(path.Ext(texMap.Filename)); ext == ".jpg" || ext == ".jpeg" {
+				out.MimeType = "image/jpeg"
+			}
+		} else {
+			out.URI = (&url.URL{Path: exportPath(exporter.dirname, texMap.Filename)}).String()
+		}
+		exporter.doc.Images = append(exporter.doc.Images, out)
+		image = len(exporter.doc.Images) - 1
+		exporter.imageIndices[texMap.Filename] = image
+	}
+
+	key := fmt.Sprint(image)
+	if sampler != nil {
+		key += fmt.Sprint(*sampler)
+	}
+	index, ok := exporter.textureIndices[key]
+	if !ok {
+		out := gltfOutTexture{Source: image}
+		if sampler != nil {
+			exporter.doc.Samplers = append(exporter.doc.Samplers, *sampler)
+			samplerIndex := len(exporter.doc.Samplers) - 1
+			out.Sampler = &samplerIndex
+		}
+		exporter.doc.Textures = append(exporter.doc.Textures, out)
+		index = len(exporter.doc.Textures) - 1
+		exporter.textureIndices[key] = index
+	}
+
+	return &gltfOutTextureInfo{Index: index}
+}
+
+// gltfHasMetallicRoughness returns whether a material has metallic-roughness parameters, e.g. from
+// a glTF file. Materials loaded from glTF with default factors are recognized by their Phong
+// exponent, which loadMaterials derives from roughness.
+func gltfHasMetallicRoughness(def *MaterialDef) bool {
+	return def.Metallic != 0 || def.Roughness != 1 ||
+		def.MetallicRoughnessMap.Filename != "" || def.MetallicRoughnessMap.Data != nil ||
+		def.Shininess == gltfShininess(def.Roughness)
+}
+
+// exportMaterials maps each MaterialDef back onto metallic-roughness, the inverse of loadMaterials
+func (exporter *gltfExporter) exportMaterials() {
+	names := make([]string, 0, len(exporter.mesh.Materials))
+	for name := range exporter.mesh.Materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def := exporter.mesh.Materials[name]
+
+		// Roughness is only recovered from the Phong exponent of materials without PBR data
+		roughness := def.Roughness
+		if !gltfHasMetallicRoughness(def) {
+			roughness = float32(math.Pow(2/(float64(def.Shininess)+2), 0.25))
+		}
+
+		out := gltfOutMaterial{
+			Name: name,
+			PBR: gltfOutPBR{
+				BaseColorFactor:  [4]float32{def.Diffuse[0], def.Diffuse[1], def.Diffuse[2], def.Dissolve},
+				BaseColorTexture: exporter.texture(&def.DiffuseMap),
+				MetallicFactor:   def.Metallic,
+				RoughnessFactor:  mgl32.Clamp(roughness, 0, 1),
+				MetallicTexture:  exporter.texture(&def.MetallicRoughnessMap),
+			},
+			NormalTexture:    exporter.texture(&def.NormalMap),
+			OcclusionTexture: exporter.texture(&def.OcclusionMap),
+			EmissiveTexture:  exporter.texture(&def.EmissiveMap),
+			DoubleSided:      def.DoubleSided,
+		}
+
+		if out.NormalTexture != nil && def.NormalMap.BumpMultiplier != 0 {
+			scale := def.NormalMap.BumpMultiplier
+			out.NormalTexture.Scale = &scale
+		}
+
+		emissive := def.Emissive
+		if out.EmissiveTexture != nil && emissive == (mgl32.Vec3{}) {
+			emissive = mgl32.Vec3{1, 1, 1}
+		}
+		if emissive != (mgl32.Vec3{}) {
+			out.EmissiveFactor = &emissive
+		}
+
+		if def.AlphaCutoff > 0 {
+			cutoff := def.AlphaCutoff
+			out.AlphaMode = "MASK"
+			out.AlphaCutoff = &cutoff
+		} else if def.Dissolve < 1 || def.AlphaMap.Filename != "" {
+			out.AlphaMode = "BLEND"
+		}
+
+		exporter.materialIndices[name] = len(exporter.doc.Materials)
+		exporter.doc.Materials = append(exporter.doc.Materials, out)
+	}
+}
+
+// exportNodes writes the mesh's nodes, or one node for each part when there are none, with the
+// submeshes placed by each node as the primitives of its mesh
+func (exporter *gltfExporter) exportNodes() {
+	mesh := exporter.mesh
+
+	roots := []int{}
+	if len(mesh.Nodes) > 0 {
+		for i, node := range mesh.Nodes {
+			out := gltfOutNode{
+				Name:     node.Name,
+				Children: node.Children,
+			}
+			if node.Translation != (mgl32.Vec3{}) {
+				translation := node.Translation
+				out.Translation = &translation
+			}
+			if node.Rotation != mgl32.QuatIdent() {
+				rotation := [4]float32{node.Rotation.V[0], node.Rotation.V[1], node.Rotation.V[2], node.Rotation.W}
+				out.Rotation = &rotation
+			}
+			if node.Scale != (mgl32.Vec3{1, 1, 1}) {
+				scale := node.Scale
+				out.Scale = &scale
+			}
+			if node.Skin >= 0 {
+				skin := node.Skin
+				out.Skin = &skin
+			}
+			exporter.doc.Nodes = append(exporter.doc.Nodes, out)
+			if node.Parent < 0 {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	// Group submeshes by node, or by name without nodes
+	nodeSubmeshes := map[int][]Submesh{}
+	order := []int{}
+	partNodes := map[string]int{}
+	for _, sub := range mesh.Submeshes {
+		node := sub.Node
+		if len(mesh.Nodes) == 0 {
+			var ok bool
+			if node, ok = partNodes[sub.Name]; !ok {
+				node = len(exporter.doc.Nodes)
+				partNodes[sub.Name] = node
+				exporter.doc.Nodes = append(exporter.doc.Nodes, gltfOutNode{Name: sub.Name})
+				roots = append(roots, node)
+			}
+		} else if node < 0 || node >= len(mesh.Nodes) {
+			continue
+		}
+		if _, ok := nodeSubmeshes[node]; !ok {
+			order = append(order, node)
+		}
+		nodeSubmeshes[node] = append(nodeSubmeshes[node], sub)
+	}
+
+	for _, node := range order {
+		out := gltfOutMesh{Name: exporter.doc.Nodes[node].Name}
+		for _, sub := range nodeSubmeshes[node] {
+			if sub.Count == 0 {
+				continue
+			}
+			out.Primitives = append(out.Primitives, exporter.exportPrimitive(sub))
+		}
+		if len(out.Primitives) == 0 {
+			continue
+		}
+		meshIndex := len(exporter.doc.Meshes)
+		exporter.doc.Meshes = append(exporter.doc.Meshes, out)
+		exporter.doc.Nodes[node].Mesh = &meshIndex
+	}
+
+	exporter.doc.Scenes = []map[string][]int{{"nodes": roots}}
+}
+
+// exportPrimitive writes the vertices used by a submesh, so each primitive only has its own
+func (exporter *gltfExporter) exportPrimitive(sub Submesh) gltfOutPrimitive {
+	mesh := exporter.mesh
+	format := mesh.VertexFormat()
+
+	remap := map[uint32]uint32{}
+	vertices := []uint32{}
+	indices := make([]uint32, sub.Count)
+	for i, index := range mesh.Indices[sub.Start : sub.Start+sub.Count] {
+		local, ok := remap[index]
+		if !ok {
+			local = uint32(len(vertices))
+			remap[index] = local
+			vertices = append(vertices, index)
+		}
+		indices[i] = local
+	}
+
+	positions := make([]float32, 0, len(vertices)*3)
+	for _, v := range vertices {
+		positions = append(positions, mesh.Positions[v][:]...)
+	}
+
+	prim := gltfOutPrimitive{Attributes: map[string]int{}}
+	prim.Attributes["POSITION"] = exporter.addFloats(positions, "VEC3", gl.ARRAY_BUFFER, true)
+
+	if format&VERTEX_NORMALS != 0 {
+		normals := make([]float32, 0, len(vertices)*3)
+		for _, v := range vertices {
+			normals = append(normals, mesh.Normals[v][:]...)
+		}
+		prim.Attributes["NORMAL"] = exporter.addFloats(normals, "VEC3", gl.ARRAY_BUFFER, false)
+	}
+	if format&VERTEX_TEXCOORDS != 0 {
+		// Flip texture coordinates back to a top left origin
+		txcds := make([]float32, 0, len(vertices)*2)
+		for _, v := range vertices {
+			txcds = append(txcds, mesh.TexCoords[v][0], 1-mesh.TexCoords[v][1])
+		}
+		prim.Attributes["TEXCOORD_0"] = exporter.addFloats(txcds, "VEC2", gl.ARRAY_BUFFER, false)
+	}
+	if format&VERTEX_TANGENTS != 0 {
+		tangents := make([]float32, 0, len(vertices)*4)
+		for _, v := range vertices {
+			tangents = append(tangents, mesh.Tangents[v][:]...)
+		}
+		prim.Attributes["TANGENT"] = exporter.addFloats(tangents, "VEC4", gl.ARRAY_BUFFER, false)
+	}
+	if format&VERTEX_COLORS != 0 {
+		colors := make([]float32, 0, len(vertices)*4)
+		for _, v := range vertices {
+			colors = append(colors, mesh.Colors[v][:]...)
+		}
+		prim.Attributes["COLOR_0"] = exporter.addFloats(colors, "VEC4", gl.ARRAY_BUFFER, false)
+	}
+	if len(mesh.Joints) == mesh.VertexCount() && len(mesh.Weights) == mesh.VertexCount() {
+		joints := make([]uint32, 0, len(vertices)*4)
+		weights := make([]float32, 0, len(vertices)*4)
+		for _, v := range vertices {
+			for _, joint := range mesh.Joints[v] {
+				joints = append(joints, uint32(joint))
+			}
+			weights = append(weights, mesh.Weights[v][:]...)
+		}
+		prim.Attributes["JOINTS_0"] = exporter.addIndices(joints, "VEC4", gl.ARRAY_BUFFER, true)
+		prim.Attributes["WEIGHTS_0"] = exporter.addFloats(weights, "VEC4", gl.ARRAY_BUFFER, false)
+	}
+
+	prim.Indices = exporter.addIndices(indices, "SCALAR", gl.ELEMENT_ARRAY_BUFFER, len(vertices) <= math.MaxUint16)
+
+	if material, ok := exporter.materialIndices[sub.Material]; ok {
+		prim.Material = &material
+	}
+
+	return prim
+}
+
+func (exporter *gltfExporter) exportSkins() {
+	for _, skin := range exporter.mesh.Skins {
+		out := gltfOutSkin{
+			Name:   skin.Name,
+			Joints: skin.Joints,
+		}
+		if len(skin.InverseBindMatrices) > 0 {
+			matrices := make([]float32, 0, len(skin.InverseBindMatrices)*16)
+			for _, matrix := range skin.InverseBindMatrices {
+				matrices = append(matrices, matrix[:]...)
+			}
+			accessor := exporter.addFloats(matrices, "MAT4", 0, false)
+			out.InverseBindMatrices = &accessor
+		}
+		if skin.Skeleton >= 0 {
+			skeleton := skin.Skeleton
+			out.Skeleton = &skeleton
+		}
+		exporter.doc.Skins = append(exporter.doc.Skins, out)
+	}
+}
+
+func (exporter *gltfExporter) exportAnimations() {
+	types := map[int]string{1: "SCALAR", 3: "VEC3", 4: "VEC4"}
+
+	for _, anim := range exporter.mesh.Animations {
+		// Morph target weights are written as scalars, whatever the number of targets
+		weights := map[int]bool{}
+		for _, channel := range anim.Channels {
+			if channel.Path == ANIMATION_WEIGHTS {
+				weights[channel.Sampler] = true
+			}
+		}
+
+		out := gltfOutAnimation{Name: anim.Name}
+		for s, sampler := range anim.Samplers {
+			typ, ok := types[sampler.Components]
+			if !ok || weights[s] {
+				typ = "SCALAR"
+			}
+			out.Samplers = append(out.Samplers, gltfOutAnimSampler{
+				Input:         exporter.addFloats(sampler.Times, "SCALAR", 0, true),
+				Output:        exporter.addFloats(sampler.Values, typ, 0, false),
+				Interpolation: sampler.Interpolation,
+			})
+		}
+		for _, channel := range anim.Channels {
+			var outChannel gltfOutChannel
+			outChannel.Sampler = channel.Sampler
+			outChannel.Target.Node = channel.Node
+			outChannel.Target.Path = channel.Path
+			out.Channels = append(out.Channels, outChannel)
+		}
+		exporter.doc.Animations = append(exporter.doc.Animations, out)
+	}
+}
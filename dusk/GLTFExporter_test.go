@@ -0,0 +1,80 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+)
+
+func TestWriteGLTFMaterialRoundTrip(t *testing.T) {
+	quietLogs(t)
+
+	// A triangle with one metallic-roughness material per test
+	const document = `{
+		"asset": {"version": "2.0"},
+		"buffers": [{"byteLength": 36, "uri": "data:application/octet-stream;base64,%v"}],
+		"bufferViews": [{"buffer": 0, "byteLength": 36}],
+		"accessors": [{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 3}],
+		"images": [{"uri": "metallicRoughness.png"}],
+		"textures": [{"source": 0}],
+		"materials": [
+			{"name": "Smooth", "pbrMetallicRoughness": {"metallicFactor": 0, "roughnessFactor": 0.05}},
+			{"name": "Rough", "pbrMetallicRoughness": {"metallicFactor": 0}},
+			{"name": "Metal", "pbrMetallicRoughness": {"metallicFactor": 1, "roughnessFactor": 0.3}},
+			{"name": "Textured", "pbrMetallicRoughness": {"metallicRoughnessTexture": {"index": 0}}}
+		],
+		"meshes": [{"primitives": [{"attributes": {"POSITION": 0}, "material": 0}]}],
+		"nodes": [{"mesh": 0}]
+	}`
+	buffer := base64.StdEncoding.EncodeToString(make([]byte, 36))
+	app := newMemoryApp(map[string][]byte{
+		"triangle.gltf": []byte(fmt.Sprintf(document, buffer)),
+	})
+	mesh, err := LoadGLTF(app, "triangle.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Materials from OBJ files only have a Phong exponent, which roughness is derived from
+	phong := NewMaterialDef()
+	phong.Shininess = 30
+	mesh.Materials["Phong"] = phong
+
+	var out bytes.Buffer
+	if err := WriteGLTF(&out, mesh, ""); err != nil {
+		t.Fatal(err)
+	}
+	app = newMemoryApp(map[string][]byte{"exported.gltf": out.Bytes()})
+	exported, err := LoadGLTF(app, "exported.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name      string
+		Metallic  float32
+		Roughness float32
+	}{
+		{"Smooth", 0, 0.05},
+		{"Rough", 0, 1},
+		{"Metal", 1, 0.3},
+		{"Textured", 1, 1},
+		{"Phong", 0, 0.5},
+	}
+	for _, test := range tests {
+		def := exported.Materials[test.Name]
+		if def == nil {
+			t.Errorf("Missing material '%v'", test.Name)
+			continue
+		}
+		if def.Metallic != test.Metallic || abs32(def.Roughness-test.Roughness) > 1e-6 {
+			t.Errorf("%v: got metallic %v roughness %v, expected %v %v",
+				test.Name, def.Metallic, def.Roughness, test.Metallic, test.Roughness)
+		}
+	}
+	if exported.Materials["Textured"].MetallicRoughnessMap.Filename != "metallicRoughness.png" {
+		t.Errorf("Got metallic-roughness map '%v', expected 'metallicRoughness.png'",
+			exported.Materials["Textured"].MetallicRoughnessMap.Filename)
+	}
+}
@@ -0,0 +1,391 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Sample models from the Khronos glTF-Sample-Models repository, in its directory layout
+const gltfSamples = "testdata"
+
+// readSampleModel reads every file of one version of a sample model, e.g. "Box/glTF", and skips
+// the test if the model isn't in testdata
+func readSampleModel(tb testing.TB, dir string) map[string][]byte {
+	infos, err := ioutil.ReadDir(filepath.Join(gltfSamples, filepath.FromSlash(dir)))
+	if err != nil {
+		tb.Skipf("Missing sample model: %v", err)
+	}
+	filenames := []string{}
+	for _, info := range infos {
+		if !info.IsDir() {
+			filenames = append(filenames, path.Join(dir, info.Name()))
+		}
+	}
+	return readTestAssets(tb, gltfSamples, filenames...)
+}
+
+// loadSampleModel loads filename from a sample model directory read by readSampleModel
+func loadSampleModel(t *testing.T, filename string) *MeshData {
+	quietLogs(t)
+
+	app := newMemoryApp(readSampleModel(t, path.Dir(filename)))
+	mesh, err := LoadGLTF(app, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGLTFMesh(t, mesh)
+	return mesh
+}
+
+// checkGLTFMesh checks that everything in a loaded mesh refers to something that exists
+func checkGLTFMesh(t *testing.T, mesh *MeshData) {
+	t.Helper()
+
+	count := mesh.VertexCount()
+	attributes := map[string]int{
+		"normals":             len(mesh.Normals),
+		"texture coordinates": len(mesh.TexCoords),
+		"tangents":            len(mesh.Tangents),
+		"colors":              len(mesh.Colors),
+		"joints":              len(mesh.Joints),
+		"weights":             len(mesh.Weights),
+	}
+	for name, n := range attributes {
+		if n != 0 && n != count {
+			t.Errorf("Got %v %v for %v vertices", n, name, count)
+		}
+	}
+	for _, index := range mesh.Indices {
+		if int(index) >= count {
+			t.Errorf("Got index %v for %v vertices", index, count)
+			break
+		}
+	}
+	for _, sub := range mesh.Submeshes {
+		if sub.Start < 0 || sub.Start+sub.Count > len(mesh.Indices) || sub.Node < 0 || sub.Node >= len(mesh.Nodes) {
+			t.Errorf("Got submesh %+v out of range", sub)
+		}
+		if _, ok := mesh.Materials[sub.Material]; sub.Material != "" && !ok {
+			t.Errorf("Got submesh '%v' with unknown material '%v'", sub.Name, sub.Material)
+		}
+	}
+
+	for i, node := range mesh.Nodes {
+		if node.Parent < -1 || node.Parent >= len(mesh.Nodes) || node.Skin < -1 || node.Skin >= len(mesh.Skins) {
+			t.Errorf("Got node %v with parent %v and skin %v out of range", i, node.Parent, node.Skin)
+		}
+	}
+	for i, skin := range mesh.Skins {
+		if len(skin.InverseBindMatrices) != len(skin.Joints) {
+			t.Errorf("Got %v inverse bind matrices for %v joints in skin %v", len(skin.InverseBindMatrices), len(skin.Joints), i)
+		}
+		for _, joint := range skin.Joints {
+			if joint < 0 || joint >= len(mesh.Nodes) {
+				t.Errorf("Got joint %v out of range in skin %v", joint, i)
+			}
+		}
+	}
+
+	// Skinned vertices have weights that add up to 1
+	for i, weights := range mesh.Weights {
+		if sum := weights[0] + weights[1] + weights[2] + weights[3]; mgl32.Abs(sum-1) > 1e-2 {
+			t.Errorf("Got weights %v adding up to %v for vertex %v", weights, sum, i)
+			break
+		}
+	}
+
+	for _, anim := range mesh.Animations {
+		for s, sampler := range anim.Samplers {
+			perKey := 1
+			if sampler.Interpolation == INTERPOLATION_CUBICSPLINE {
+				perKey = 3
+			}
+			if len(sampler.Values) != len(sampler.Times)*sampler.Components*perKey {
+				t.Errorf("Got %v values for %v keyframes of %v components in animation '%v' sampler %v",
+					len(sampler.Values), len(sampler.Times), sampler.Components, anim.Name, s)
+			}
+		}
+		for _, channel := range anim.Channels {
+			if channel.Node < 0 || channel.Node >= len(mesh.Nodes) || channel.Sampler < 0 || channel.Sampler >= len(anim.Samplers) {
+				t.Errorf("Got channel %+v out of range in animation '%v'", channel, anim.Name)
+			}
+		}
+	}
+}
+
+// samplerOptions are the texture options of the sampler used by the Box samples
+var samplerOptions = TextureOptions{
+	MagFilter: gl.LINEAR,
+	MinFilter: gl.NEAREST_MIPMAP_LINEAR,
+	WrapS:     gl.REPEAT,
+	WrapT:     gl.REPEAT,
+}
+
+// zUp is the matrix of the Box samples' root node, which turns Z up into Y up
+var zUp = mgl32.HomogRotate3DX(mgl32.DegToRad(-90))
+
+func approxEqualMat4(a, b mgl32.Mat4) bool {
+	return a.ApproxFuncEqual(b, func(x, y float32) bool { return mgl32.Abs(x-y) < 1e-5 })
+}
+
+func TestLoadGLTFBox(t *testing.T) {
+	mesh := loadSampleModel(t, "Box/glTF/Box.gltf")
+
+	if mesh.VertexCount() != 24 || len(mesh.Normals) != 24 || len(mesh.Indices) != 36 {
+		t.Errorf("Got %v vertices, %v normals and %v indices, expected 24, 24 and 36",
+			mesh.VertexCount(), len(mesh.Normals), len(mesh.Indices))
+	}
+	expectSubmesh := Submesh{Name: "node_1", Start: 0, Count: 36, Material: "Red", Node: 1}
+	if len(mesh.Submeshes) != 1 || mesh.Submeshes[0] != expectSubmesh {
+		t.Errorf("Got submeshes %+v, expected %+v", mesh.Submeshes, expectSubmesh)
+	}
+
+	mat := mesh.Materials["Red"]
+	if mat == nil {
+		t.Fatalf("Missing material 'Red', got %v", mesh.Materials)
+	}
+	if !mat.Diffuse.ApproxEqual(mgl32.Vec3{0.8, 0, 0}) || mat.Metallic != 0 || mat.Roughness != 1 {
+		t.Errorf("Got diffuse %v metallic %v roughness %v, expected [0.8 0 0] 0 1", mat.Diffuse, mat.Metallic, mat.Roughness)
+	}
+
+	if len(mesh.Nodes) != 2 || mesh.Nodes[0].Parent != -1 || mesh.Nodes[1].Parent != 0 {
+		t.Fatalf("Got nodes %+v, expected a root with one child", mesh.Nodes)
+	}
+	if got := mesh.Nodes[0].LocalTransform(); !approxEqualMat4(got, zUp) {
+		t.Errorf("Got root transform %v, expected %v", got, zUp)
+	}
+}
+
+func TestLoadGLTFBoxTextured(t *testing.T) {
+	tests := []struct {
+		Filename string
+		Image    string
+		Embedded bool
+	}{
+		{"BoxTextured/glTF/BoxTextured.gltf", "BoxTextured/glTF/CesiumLogoFlat.png", false},
+		{"BoxTextured/glTF-Binary/BoxTextured.glb", "BoxTextured/glTF-Binary/BoxTextured.glb#image0.png", true},
+	}
+
+	for _, test := range tests {
+		t.Run(path.Base(test.Filename), func(t *testing.T) {
+			mesh := loadSampleModel(t, test.Filename)
+
+			if mesh.VertexCount() != 24 || len(mesh.TexCoords) != 24 || len(mesh.Indices) != 36 || len(mesh.Submeshes) != 1 {
+				t.Errorf("Got %v vertices, %v texture coordinates, %v indices and %v submeshes, expected 24, 24, 36 and 1",
+					mesh.VertexCount(), len(mesh.TexCoords), len(mesh.Indices), len(mesh.Submeshes))
+			}
+			if len(mesh.Nodes) != 2 || mesh.Nodes[1].Parent != 0 || !approxEqualMat4(mesh.Nodes[0].LocalTransform(), zUp) {
+				t.Errorf("Got nodes %+v, expected a Z up root with one child", mesh.Nodes)
+			}
+
+			mat := mesh.Materials["Texture"]
+			if mat == nil {
+				t.Fatalf("Missing material 'Texture', got %v", mesh.Materials)
+			}
+			if mat.Metallic != 0 || mat.Roughness != 1 {
+				t.Errorf("Got metallic %v roughness %v, expected 0 1", mat.Metallic, mat.Roughness)
+			}
+
+			diffuseMap := mat.DiffuseMap
+			if diffuseMap.Filename != test.Image {
+				t.Errorf("Got image '%v', expected '%v'", diffuseMap.Filename, test.Image)
+			}
+			if embedded := bytes.HasPrefix(diffuseMap.Data, []byte("\x89PNG")); embedded != test.Embedded {
+				t.Errorf("Got %v bytes of image data, expected an embedded PNG: %v", len(diffuseMap.Data), test.Embedded)
+			}
+			if diffuseMap.Options == nil || *diffuseMap.Options != samplerOptions {
+				t.Errorf("Got texture options %+v, expected %+v", diffuseMap.Options, samplerOptions)
+			}
+		})
+	}
+}
+
+func TestLoadGLTFRiggedSimple(t *testing.T) {
+	mesh := loadSampleModel(t, "RiggedSimple/glTF/RiggedSimple.gltf")
+
+	if len(mesh.Skins) != 1 || len(mesh.Skins[0].Joints) != 2 {
+		t.Fatalf("Got skins %+v, expected one with 2 joints", mesh.Skins)
+	}
+	if len(mesh.Joints) != mesh.VertexCount() || len(mesh.Weights) != mesh.VertexCount() {
+		t.Errorf("Got %v joints and %v weights for %v vertices", len(mesh.Joints), len(mesh.Weights), mesh.VertexCount())
+	}
+	for i, joints := range mesh.Joints {
+		for c, joint := range joints {
+			if mesh.Weights[i][c] > 0 && int(joint) >= len(mesh.Skins[0].Joints) {
+				t.Fatalf("Got joint %v of vertex %v out of range", joint, i)
+			}
+		}
+	}
+
+	skinned := 0
+	for _, sub := range mesh.Submeshes {
+		if mesh.Nodes[sub.Node].Skin == 0 {
+			skinned++
+		}
+	}
+	if skinned == 0 {
+		t.Errorf("Got no submeshes placed by the skinned node")
+	}
+
+	// The joints are a chain, with the second bone under the first
+	joints := mesh.Skins[0].Joints
+	if mesh.Nodes[joints[1]].Parent != joints[0] {
+		t.Errorf("Got parent %v of joint %v, expected %v", mesh.Nodes[joints[1]].Parent, joints[1], joints[0])
+	}
+	for j, matrix := range mesh.Skins[0].InverseBindMatrices {
+		if matrix.Det() == 0 {
+			t.Errorf("Got singular inverse bind matrix for joint %v", j)
+		}
+	}
+
+	if len(mesh.Animations) == 0 || len(mesh.Animations[0].Samplers) == 0 {
+		t.Errorf("Got no animation of the joints")
+	}
+}
+
+func TestLoadGLTFAnimatedCube(t *testing.T) {
+	mesh := loadSampleModel(t, "AnimatedCube/glTF/AnimatedCube.gltf")
+
+	if len(mesh.Materials) != 1 || len(mesh.Submeshes) != 1 || mesh.VertexCount() == 0 {
+		t.Fatalf("Got %v materials, %v submeshes and %v vertices, expected one textured cube",
+			len(mesh.Materials), len(mesh.Submeshes), mesh.VertexCount())
+	}
+	mat := mesh.Materials[mesh.Submeshes[0].Material]
+	if !strings.HasSuffix(mat.DiffuseMap.Filename, "_BaseColor.png") ||
+		!strings.HasSuffix(mat.MetallicRoughnessMap.Filename, "_MetallicRoughness.png") {
+		t.Errorf("Got maps '%v' and '%v', expected the base color and metallic-roughness images",
+			mat.DiffuseMap.Filename, mat.MetallicRoughnessMap.Filename)
+	}
+
+	if len(mesh.Animations) != 1 || len(mesh.Animations[0].Channels) != 1 || len(mesh.Animations[0].Samplers) != 1 {
+		t.Fatalf("Got animations %+v, expected one with a single channel", mesh.Animations)
+	}
+	anim := mesh.Animations[0]
+	sampler := anim.Samplers[0]
+	if anim.Channels[0].Path != ANIMATION_ROTATION || sampler.Components != 4 || sampler.Interpolation != INTERPOLATION_LINEAR {
+		t.Errorf("Got %v channel with %v components and '%v', expected a LINEAR rotation",
+			anim.Channels[0].Path, sampler.Components, sampler.Interpolation)
+	}
+}
+
+func TestLoadGLTFAnimatedMorphCube(t *testing.T) {
+	mesh := loadSampleModel(t, "AnimatedMorphCube/glTF-Binary/AnimatedMorphCube.glb")
+
+	if mesh.VertexCount() != 24 || len(mesh.Normals) != 24 || len(mesh.Tangents) != 24 || len(mesh.Indices) != 36 {
+		t.Errorf("Got %v vertices, %v normals, %v tangents and %v indices, expected 24, 24, 24 and 36",
+			mesh.VertexCount(), len(mesh.Normals), len(mesh.Tangents), len(mesh.Indices))
+	}
+	expectSubmesh := Submesh{Name: "AnimatedMorphCube", Start: 0, Count: 36, Material: "Material", Node: 0}
+	if len(mesh.Submeshes) != 1 || mesh.Submeshes[0] != expectSubmesh {
+		t.Errorf("Got submeshes %+v, expected %+v", mesh.Submeshes, expectSubmesh)
+	}
+
+	mat := mesh.Materials["Material"]
+	if mat == nil {
+		t.Fatalf("Missing material 'Material', got %v", mesh.Materials)
+	}
+	if !mat.Diffuse.ApproxEqual(mgl32.Vec3{0.6038274, 0.6038274, 0.6038274}) || mat.Metallic != 0 || mat.Roughness != 0.5 {
+		t.Errorf("Got diffuse %v metallic %v roughness %v, expected [0.6038274 0.6038274 0.6038274] 0 0.5",
+			mat.Diffuse, mat.Metallic, mat.Roughness)
+	}
+
+	if len(mesh.Nodes) != 1 {
+		t.Fatalf("Got %v nodes, expected 1", len(mesh.Nodes))
+	}
+	node := mesh.Nodes[0]
+	expectRotation := mgl32.Quat{W: 0, V: mgl32.Vec3{0, 0.7071067, -0.7071068}}
+	if node.Parent != -1 || node.Scale != (mgl32.Vec3{100, 100, 100}) || node.Rotation != expectRotation {
+		t.Errorf("Got node %+v, expected a root scaled by 100 with rotation %v", node, expectRotation)
+	}
+
+	// Morph target weights are kept, though Apply ignores them
+	if len(mesh.Animations) != 1 {
+		t.Fatalf("Got %v animations, expected 1", len(mesh.Animations))
+	}
+	anim := mesh.Animations[0]
+	if anim.Name != "Square" || len(anim.Channels) != 1 || len(anim.Samplers) != 1 {
+		t.Fatalf("Got animation '%v' with %v channels and %v samplers, expected 'Square' with 1 and 1",
+			anim.Name, len(anim.Channels), len(anim.Samplers))
+	}
+	if anim.Channels[0] != (AnimationChannel{Node: 0, Path: "weights", Sampler: 0}) {
+		t.Errorf("Got channel %+v, expected the weights of node 0", anim.Channels[0])
+	}
+	sampler := anim.Samplers[0]
+	if len(sampler.Times) != 127 || len(sampler.Values) != 254 || sampler.Components != 2 || sampler.Interpolation != INTERPOLATION_LINEAR {
+		t.Errorf("Got %v keyframes, %v values, %v components and '%v', expected 127, 254, 2 and LINEAR",
+			len(sampler.Times), len(sampler.Values), sampler.Components, sampler.Interpolation)
+	}
+	if duration := anim.Duration(); mgl32.Abs(duration-4.19999743) > 1e-6 {
+		t.Errorf("Got duration %v, expected 4.19999743", duration)
+	}
+}
+
+func TestLoadGLTFAccessorCounts(t *testing.T) {
+	quietLogs(t)
+
+	// A triangle of float positions and unsigned short indices, with the accessors from each test
+	const document = `{
+		"asset": {"version": "2.0"},
+		"buffers": [{"byteLength": 44, "uri": "data:application/octet-stream;base64,%v"}],
+		"bufferViews": [
+			{"buffer": 0, "byteOffset": 0, "byteLength": 36},
+			{"buffer": 0, "byteOffset": 36, "byteLength": 6},
+			{"buffer": 0, "byteOffset": 36, "byteLength": 8}
+		],
+		"accessors": [%v, %v],
+		"meshes": [{"primitives": [{"attributes": {"POSITION": 0}, "indices": 1}]}],
+		"nodes": [{"mesh": 0}]
+	}`
+	buffer := base64.StdEncoding.EncodeToString(append(make([]byte, 36), 0, 0, 1, 0, 2, 0, 0, 0))
+	indices := `{"bufferView": 1, "componentType": 5123, "type": "SCALAR", "count": 3}`
+
+	tests := []struct {
+		Name      string
+		Positions string
+		Indices   string
+		Error     bool
+	}{
+		{"Valid", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 3}`, indices, false},
+		{"Negative count", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": -1}`, indices, true},
+		{"Count past view", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 4}`, indices, true},
+		{"Huge count", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 4611686018427387904}`, indices, true},
+		{"Huge count without view", `{"componentType": 5126, "type": "VEC3", "count": 4611686018427387904}`, indices, true},
+		{"Negative sparse count", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 3, "sparse": {
+			"count": -1, "indices": {"bufferView": 2, "componentType": 5123}, "values": {"bufferView": 0}}}`, indices, true},
+		{"Huge sparse count", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 3, "sparse": {
+			"count": 4611686018427387904, "indices": {"bufferView": 2, "componentType": 5123}, "values": {"bufferView": 0}}}`, indices, true},
+		{"Negative index count", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 3}`,
+			`{"bufferView": 1, "componentType": 5123, "type": "SCALAR", "count": -1}`, true},
+		{"Huge index count", `{"bufferView": 0, "componentType": 5126, "type": "VEC3", "count": 3}`,
+			`{"bufferView": 1, "componentType": 5123, "type": "SCALAR", "count": 4611686018427387904}`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			data := fmt.Sprintf(document, buffer, test.Positions, test.Indices)
+			app := newMemoryApp(map[string][]byte{"triangle.gltf": []byte(data)})
+			mesh, err := LoadGLTF(app, "triangle.gltf")
+			if test.Error {
+				if err == nil {
+					t.Errorf("Loaded an invalid accessor")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mesh.VertexCount() != 3 || len(mesh.Indices) != 3 {
+				t.Errorf("Got %v vertices and %v indices, expected 3 and 3", mesh.VertexCount(), len(mesh.Indices))
+			}
+		})
+	}
+}
@@ -6,90 +6,219 @@ import (
 )
 
 const (
-	AMBIENT_TEXID  = 0
-	DIFFUSE_TEXID  = 1
-	SPECULAR_TEXID = 2
-	BUMP_TEXID     = 3
+	AMBIENT_TEXID   = 0
+	DIFFUSE_TEXID   = 1
+	SPECULAR_TEXID  = 2
+	BUMP_TEXID      = 3
+	ALPHA_TEXID     = 4
+	EMISSIVE_TEXID  = 5
+	NORMAL_TEXID    = 6
+	SHININESS_TEXID = 7
+	METALLIC_TEXID  = 8
+	OCCLUSION_TEXID = 9
+
+	MATERIAL_MAP_COUNT = 10
 )
 
 const (
-	AMBIENT_MAP_FLAG  = 1
-	DIFFUSE_MAP_FLAG  = 2
-	SPECULAR_MAP_FLAG = 4
-	BUMP_MAP_FLAG     = 8
+	AMBIENT_MAP_FLAG   = 1
+	DIFFUSE_MAP_FLAG   = 2
+	SPECULAR_MAP_FLAG  = 4
+	BUMP_MAP_FLAG      = 8
+	ALPHA_MAP_FLAG     = 16
+	EMISSIVE_MAP_FLAG  = 32
+	NORMAL_MAP_FLAG    = 64
+	SHININESS_MAP_FLAG = 128
+	METALLIC_MAP_FLAG  = 256
+	OCCLUSION_MAP_FLAG = 512
 )
 
+// Sampler uniform for each texture unit
+var materialMapUniforms = [MATERIAL_MAP_COUNT]string{
+	AMBIENT_TEXID:   "_AmbientMap",
+	DIFFUSE_TEXID:   "_DiffuseMap",
+	SPECULAR_TEXID:  "_SpecularMap",
+	BUMP_TEXID:      "_BumpMap",
+	ALPHA_TEXID:     "_AlphaMap",
+	EMISSIVE_TEXID:  "_EmissiveMap",
+	NORMAL_TEXID:    "_NormalMap",
+	SHININESS_TEXID: "_ShininessMap",
+	METALLIC_TEXID:  "_MetallicRoughnessMap",
+	OCCLUSION_TEXID: "_OcclusionMap",
+}
+
+// TextureMap is a texture filename and the options given for it in a material file
+type TextureMap struct {
+	Filename string
+	Clamp    bool
+	Mirror   bool
+
+	// Data holds an image embedded in the model file, Filename is then only used for its extension
+	Data []byte
+
+	// Options overrides app.TextureOptions when set, e.g. by a glTF sampler
+	Options *TextureOptions
+
+	// Scale and Offset are applied to texture coordinates, a zero Scale is treated as 1
+	Scale  mgl32.Vec3
+	Offset mgl32.Vec3
+
+	// BumpMultiplier scales bump and normal maps, zero is treated as 1
+	BumpMultiplier float32
+
+	// Channel is the -imfchan option: r, g, b, m (matte), l (luminance) or z (depth)
+	Channel string
+
+	// Type is the -type option of reflection maps, e.g. sphere or cube_top
+	Type string
+}
+
+// textureOptions returns app.TextureOptions with the map's options applied
+func (texMap TextureMap) textureOptions(app *App) TextureOptions {
+	opts := app.TextureOptions
+	if texMap.Options != nil {
+		opts = *texMap.Options
+	}
+	if texMap.Clamp {
+		opts.SetWrap(gl.CLAMP_TO_EDGE)
+	} else if texMap.Mirror {
+		opts.SetWrap(gl.MIRRORED_REPEAT)
+	}
+	return opts
+}
+
+// load creates the map's texture from its file or embedded data
+func (texMap TextureMap) load(app *App) (*Texture, error) {
+	if texMap.Data != nil {
+		return NewTextureFromMemory(app, texMap.Filename, texMap.Data, texMap.textureOptions(app))
+	}
+	return NewTextureWithOptions(app, texMap.Filename, texMap.textureOptions(app))
+}
+
+// transform returns the texture coordinate scale in xy and offset in zw
+func (texMap TextureMap) transform() mgl32.Vec4 {
+	scale := texMap.Scale
+	for i := range scale {
+		if scale[i] == 0 {
+			scale[i] = 1
+		}
+	}
+	return mgl32.Vec4{scale[0], scale[1], texMap.Offset[0], texMap.Offset[1]}
+}
+
+// channelMask returns the weights used to read a single channel from the map, defaulting to red
+func (texMap TextureMap) channelMask() mgl32.Vec4 {
+	switch texMap.Channel {
+	case "g":
+		return mgl32.Vec4{0, 1, 0, 0}
+	case "b":
+		return mgl32.Vec4{0, 0, 1, 0}
+	case "m":
+		return mgl32.Vec4{0, 0, 0, 1}
+	case "l":
+		return mgl32.Vec4{0.2126, 0.7152, 0.0722, 0}
+	}
+	return mgl32.Vec4{1, 0, 0, 0}
+}
+
 type Material struct {
-	ambient     mgl32.Vec3
-	diffuse     mgl32.Vec3
-	specular    mgl32.Vec3
-	shininess   float32
-	dissolve    float32
-	ambientMap  *Texture
-	diffuseMap  *Texture
-	specularMap *Texture
-	bumpMap     *Texture
-	mapFlags    uint32
+	ambient        mgl32.Vec3
+	diffuse        mgl32.Vec3
+	specular       mgl32.Vec3
+	emissive       mgl32.Vec3
+	shininess      float32
+	dissolve       float32
+	ior            float32
+	illum          int32
+	bumpMultiplier float32
+	metallic       float32
+	roughness      float32
+	alphaCutoff    float32
+	alphaChannel   mgl32.Vec4
+	maps           [MATERIAL_MAP_COUNT]*Texture
+	mapTransforms  [MATERIAL_MAP_COUNT]mgl32.Vec4
+	mapFlags       uint32
 }
 
 func NewMaterial(
 	app *App,
 	ambient, diffuse, specular mgl32.Vec3,
 	shininess, dissolve float32,
-	ambientMap, diffuseMap, specularMap, bumpMap string,
+	ambientMap, diffuseMap, specularMap, bumpMap TextureMap,
 ) (*Material, error) {
-	var err error
-	var ambientTex *Texture
-	var diffuseTex *Texture
-	var specularTex *Texture
-	var bumpTex *Texture
+	def := NewMaterialDef()
+	def.Ambient = ambient
+	def.Diffuse = diffuse
+	def.Specular = specular
+	def.Shininess = shininess
+	def.Dissolve = dissolve
+	def.AmbientMap = ambientMap
+	def.DiffuseMap = diffuseMap
+	def.SpecularMap = specularMap
+	def.BumpMap = bumpMap
+	return NewMaterialFromDef(app, def)
+}
 
-	var flags uint32
+// NewMaterialFromDef loads the textures of def. Displacement and reflection maps are not used by
+// Material, they are kept in the MaterialDef for renderers that support them.
+func NewMaterialFromDef(app *App, def *MaterialDef) (*Material, error) {
+	mat := &Material{
+		ambient:        def.Ambient,
+		diffuse:        def.Diffuse,
+		specular:       def.Specular,
+		emissive:       def.Emissive,
+		shininess:      def.Shininess,
+		dissolve:       def.Dissolve,
+		ior:            def.IOR,
+		illum:          int32(def.Illum),
+		bumpMultiplier: 1,
+		metallic:       def.Metallic,
+		roughness:      def.Roughness,
+		alphaCutoff:    def.AlphaCutoff,
+		alphaChannel:   def.AlphaMap.channelMask(),
+	}
 
-	if ambientMap != "" {
-		ambientTex, err = NewTexture(app, ambientMap)
-		if err != nil {
-			return nil, err
-		}
-		flags |= AMBIENT_MAP_FLAG
+	maps := [MATERIAL_MAP_COUNT]TextureMap{
+		AMBIENT_TEXID:   def.AmbientMap,
+		DIFFUSE_TEXID:   def.DiffuseMap,
+		SPECULAR_TEXID:  def.SpecularMap,
+		BUMP_TEXID:      def.BumpMap,
+		ALPHA_TEXID:     def.AlphaMap,
+		EMISSIVE_TEXID:  def.EmissiveMap,
+		NORMAL_TEXID:    def.NormalMap,
+		SHININESS_TEXID: def.ShininessMap,
+		METALLIC_TEXID:  def.MetallicRoughnessMap,
+		OCCLUSION_TEXID: def.OcclusionMap,
 	}
 
-	if diffuseMap != "" {
-		diffuseTex, err = NewTexture(app, diffuseMap)
-		if err != nil {
-			return nil, err
+	for id, texMap := range maps {
+		mat.mapTransforms[id] = texMap.transform()
+		if texMap.Filename == "" && texMap.Data == nil {
+			continue
 		}
-		flags |= DIFFUSE_MAP_FLAG
-	}
 
-	if specularMap != "" {
-		specularTex, err = NewTexture(app, specularMap)
+		tex, err := texMap.load(app)
 		if err != nil {
 			return nil, err
 		}
-		flags |= SPECULAR_MAP_FLAG
+		mat.maps[id] = tex
+		mat.mapFlags |= 1 << uint(id)
 	}
 
-	if bumpMap != "" {
-		bumpTex, err = NewTexture(app, bumpMap)
-		if err != nil {
-			return nil, err
-		}
-		flags |= BUMP_MAP_FLAG
+	// Normal maps take precedence over bump maps, as the shader only uses one
+	if mat.maps[NORMAL_TEXID] != nil && def.NormalMap.BumpMultiplier != 0 {
+		mat.bumpMultiplier = def.NormalMap.BumpMultiplier
+	} else if mat.maps[NORMAL_TEXID] == nil && def.BumpMap.BumpMultiplier != 0 {
+		mat.bumpMultiplier = def.BumpMap.BumpMultiplier
 	}
 
-	return &Material{
-		ambient:     ambient,
-		diffuse:     diffuse,
-		specular:    specular,
-		shininess:   shininess,
-		dissolve:    dissolve,
-		ambientMap:  ambientTex,
-		diffuseMap:  diffuseTex,
-		specularMap: specularTex,
-		bumpMap:     bumpTex,
-		mapFlags:    flags,
-	}, nil
+	return mat, nil
+}
+
+// IsTransparent returns whether the material needs blending, because of dissolve or an alpha map
+// that isn't used with an alpha cutoff
+func (mat *Material) IsTransparent() bool {
+	return mat.dissolve < 1 || (mat.maps[ALPHA_TEXID] != nil && mat.alphaCutoff == 0)
 }
 
 func (mat *Material) Bind(shader *Shader) {
@@ -97,30 +226,24 @@ func (mat *Material) Bind(shader *Shader) {
 	gl.Uniform3fv(shader.GetUniformLocation("_Ambient"), 1, &mat.ambient[0])
 	gl.Uniform3fv(shader.GetUniformLocation("_Diffuse"), 1, &mat.diffuse[0])
 	gl.Uniform3fv(shader.GetUniformLocation("_Specular"), 1, &mat.specular[0])
+	gl.Uniform3fv(shader.GetUniformLocation("_Emissive"), 1, &mat.emissive[0])
 	gl.Uniform1f(shader.GetUniformLocation("_Shininess"), mat.shininess)
 	gl.Uniform1f(shader.GetUniformLocation("_Dissolve"), mat.dissolve)
-
-	if mat.ambientMap != nil {
-		gl.Uniform1i(shader.GetUniformLocation("_AmbientMap"), AMBIENT_TEXID)
-		gl.ActiveTexture(gl.TEXTURE0 + AMBIENT_TEXID)
-		mat.ambientMap.Bind()
-	}
-
-	if mat.diffuseMap != nil {
-		gl.Uniform1i(shader.GetUniformLocation("_DiffuseMap"), DIFFUSE_TEXID)
-		gl.ActiveTexture(gl.TEXTURE0 + DIFFUSE_TEXID)
-		mat.diffuseMap.Bind()
-	}
-
-	if mat.specularMap != nil {
-		gl.Uniform1i(shader.GetUniformLocation("_SpecularMap"), SPECULAR_TEXID)
-		gl.ActiveTexture(gl.TEXTURE0 + SPECULAR_TEXID)
-		mat.specularMap.Bind()
-	}
-
-	if mat.bumpMap != nil {
-		gl.Uniform1i(shader.GetUniformLocation("_BumpMap"), BUMP_TEXID)
-		gl.ActiveTexture(gl.TEXTURE0 + BUMP_TEXID)
-		mat.bumpMap.Bind()
+	gl.Uniform1f(shader.GetUniformLocation("_IOR"), mat.ior)
+	gl.Uniform1i(shader.GetUniformLocation("_Illum"), mat.illum)
+	gl.Uniform1f(shader.GetUniformLocation("_BumpMultiplier"), mat.bumpMultiplier)
+	gl.Uniform1f(shader.GetUniformLocation("_Metallic"), mat.metallic)
+	gl.Uniform1f(shader.GetUniformLocation("_Roughness"), mat.roughness)
+	gl.Uniform1f(shader.GetUniformLocation("_AlphaCutoff"), mat.alphaCutoff)
+	gl.Uniform4fv(shader.GetUniformLocation("_AlphaChannel"), 1, &mat.alphaChannel[0])
+	gl.Uniform4fv(shader.GetUniformLocation("_MapTransforms"), MATERIAL_MAP_COUNT, &mat.mapTransforms[0][0])
+
+	for id, tex := range mat.maps {
+		if tex == nil {
+			continue
+		}
+		gl.Uniform1i(shader.GetUniformLocation(materialMapUniforms[id]), int32(id))
+		gl.ActiveTexture(gl.TEXTURE0 + uint32(id))
+		tex.Bind()
 	}
 }
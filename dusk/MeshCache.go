@@ -0,0 +1,502 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// A mesh cache is MeshData stored as it's uploaded, so loading it is a single read. All values
+// are little-endian. The header is followed by the interleaved vertices, the indices, and then
+// the submeshes, materials, nodes, skins, animations and any other per-vertex data.
+const (
+	MESH_CACHE_MAGIC   = "DMSH"
+	MESH_CACHE_VERSION = 2
+	MESH_CACHE_EXT     = ".dmesh"
+)
+
+type meshCacheHeader struct {
+	Magic       [4]byte
+	Version     uint32
+	Format      uint32
+	VertexCount uint32
+	IndexCount  uint32
+}
+
+// WriteMeshCache writes mesh in the mesh cache format
+func WriteMeshCache(w io.Writer, mesh *MeshData) error {
+	format := mesh.VertexFormat()
+
+	enc := &meshCacheWriter{}
+	header := meshCacheHeader{
+		Version:     MESH_CACHE_VERSION,
+		Format:      format,
+		VertexCount: uint32(mesh.VertexCount()),
+		IndexCount:  uint32(len(mesh.Indices)),
+	}
+	copy(header.Magic[:], MESH_CACHE_MAGIC)
+	binary.Write(&enc.buf, binary.LittleEndian, &header)
+
+	enc.Floats(mesh.Interleave(format))
+	for _, index := range mesh.Indices {
+		enc.Uint(index)
+	}
+
+	enc.Uint(uint32(len(mesh.Submeshes)))
+	for _, sub := range mesh.Submeshes {
+		enc.String(sub.Name)
+		enc.Int(sub.Start)
+		enc.Int(sub.Count)
+		enc.String(sub.Material)
+		enc.Int(sub.Node)
+	}
+
+	// Materials are sorted so the same mesh always gives the same file
+	names := make([]string, 0, len(mesh.Materials))
+	for name := range mesh.Materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	enc.Uint(uint32(len(names)))
+	for _, name := range names {
+		def := mesh.Materials[name]
+		enc.String(name)
+		enc.Floats(def.Ambient[:])
+		enc.Floats(def.Diffuse[:])
+		enc.Floats(def.Specular[:])
+		enc.Floats(def.Emissive[:])
+		enc.Floats(def.TransmissionFilter[:])
+		enc.Floats([]float32{def.Shininess, def.Dissolve, def.IOR})
+		enc.Int(def.Illum)
+		enc.Floats([]float32{def.Metallic, def.Roughness, def.AlphaCutoff})
+		enc.Bool(def.DoubleSided)
+		for _, texMap := range def.TextureMaps() {
+			enc.TextureMap(texMap)
+		}
+	}
+
+	enc.Uint(uint32(len(mesh.Nodes)))
+	for _, node := range mesh.Nodes {
+		enc.String(node.Name)
+		enc.Int(node.Parent)
+		enc.Floats(node.Translation[:])
+		enc.Floats([]float32{node.Rotation.W, node.Rotation.V[0], node.Rotation.V[1], node.Rotation.V[2]})
+		enc.Floats(node.Scale[:])
+		enc.Int(node.Skin)
+	}
+
+	enc.Uint(uint32(len(mesh.Skins)))
+	for _, skin := range mesh.Skins {
+		enc.String(skin.Name)
+		enc.Ints(skin.Joints)
+		enc.Uint(uint32(len(skin.InverseBindMatrices)))
+		for _, matrix := range skin.InverseBindMatrices {
+			enc.Floats(matrix[:])
+		}
+		enc.Int(skin.Skeleton)
+	}
+
+	enc.Uint(uint32(len(mesh.Animations)))
+	for _, anim := range mesh.Animations {
+		enc.String(anim.Name)
+		enc.Uint(uint32(len(anim.Channels)))
+		for _, channel := range anim.Channels {
+			enc.Int(channel.Node)
+			enc.String(channel.Path)
+			enc.Int(channel.Sampler)
+		}
+		enc.Uint(uint32(len(anim.Samplers)))
+		for _, sampler := range anim.Samplers {
+			enc.Uint(uint32(len(sampler.Times)))
+			enc.Floats(sampler.Times)
+			enc.Uint(uint32(len(sampler.Values)))
+			enc.Floats(sampler.Values)
+			enc.Int(sampler.Components)
+			enc.String(sampler.Interpolation)
+		}
+	}
+
+	// Smoothing groups aren't uploaded so they're stored separately, with a count of zero if missing
+	enc.Uint(uint32(len(mesh.SmoothingGroups)))
+	for _, group := range mesh.SmoothingGroups {
+		enc.Uint(group)
+	}
+
+	_, err := w.Write(enc.buf.Bytes())
+	return err
+}
+
+// LoadMeshCache reads a file written by WriteMeshCache
+func LoadMeshCache(app *App, filename string) (*MeshData, error) {
+	mesh, _, _, err := readMeshCache(app, filename)
+	return mesh, err
+}
+
+// readMeshCache returns the mesh along with its interleaved vertices, ready for upload
+func readMeshCache(app *App, filename string) (*MeshData, uint32, []float32, error) {
+	LogLoad("Mesh '%v'", filename)
+
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	var header meshCacheHeader
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil ||
+		string(header.Magic[:]) != MESH_CACHE_MAGIC {
+		return nil, 0, nil, fmt.Errorf("Invalid mesh cache '%v'", filename)
+	}
+	if header.Version != MESH_CACHE_VERSION {
+		return nil, 0, nil, fmt.Errorf("Unsupported mesh cache version %v in '%v'", header.Version, filename)
+	}
+
+	dec := &meshCacheReader{data: data, pos: binary.Size(header)}
+	mesh := NewMeshData()
+
+	vertices := dec.Floats(int(header.VertexCount) * vertexFloats(header.Format))
+	mesh.Indices = dec.Uints(int(header.IndexCount))
+
+	mesh.Submeshes = make([]Submesh, dec.Count())
+	for i := range mesh.Submeshes {
+		sub := &mesh.Submeshes[i]
+		sub.Name = dec.String()
+		sub.Start = dec.Int()
+		sub.Count = dec.Int()
+		sub.Material = dec.String()
+		sub.Node = dec.Int()
+	}
+
+	materialCount := dec.Count()
+	for i := 0; i < materialCount; i++ {
+		name := dec.String()
+		def := NewMaterialDef()
+		copy(def.Ambient[:], dec.Floats(3))
+		copy(def.Diffuse[:], dec.Floats(3))
+		copy(def.Specular[:], dec.Floats(3))
+		copy(def.Emissive[:], dec.Floats(3))
+		copy(def.TransmissionFilter[:], dec.Floats(3))
+		def.Shininess, def.Dissolve, def.IOR = dec.Float(), dec.Float(), dec.Float()
+		def.Illum = dec.Int()
+		def.Metallic, def.Roughness, def.AlphaCutoff = dec.Float(), dec.Float(), dec.Float()
+		def.DoubleSided = dec.Bool()
+		for _, texMap := range def.TextureMaps() {
+			*texMap = dec.TextureMap()
+		}
+		mesh.Materials[name] = def
+	}
+
+	if count := dec.Count(); count > 0 {
+		mesh.Nodes = make([]MeshNode, count)
+	}
+	for i := range mesh.Nodes {
+		node := &mesh.Nodes[i]
+		node.Name = dec.String()
+		node.Parent = dec.Int()
+		copy(node.Translation[:], dec.Floats(3))
+		node.Rotation.W = dec.Float()
+		copy(node.Rotation.V[:], dec.Floats(3))
+		copy(node.Scale[:], dec.Floats(3))
+		node.Skin = dec.Int()
+	}
+	for i := range mesh.Nodes {
+		if parent := mesh.Nodes[i].Parent; parent >= 0 && parent < len(mesh.Nodes) {
+			mesh.Nodes[parent].Children = append(mesh.Nodes[parent].Children, i)
+		}
+	}
+
+	if count := dec.Count(); count > 0 {
+		mesh.Skins = make([]MeshSkin, count)
+	}
+	for i := range mesh.Skins {
+		skin := &mesh.Skins[i]
+		skin.Name = dec.String()
+		skin.Joints = dec.Ints()
+		skin.InverseBindMatrices = make([]mgl32.Mat4, dec.Count())
+		for m := range skin.InverseBindMatrices {
+			copy(skin.InverseBindMatrices[m][:], dec.Floats(16))
+		}
+		skin.Skeleton = dec.Int()
+	}
+
+	if count := dec.Count(); count > 0 {
+		mesh.Animations = make([]MeshAnimation, count)
+	}
+	for i := range mesh.Animations {
+		anim := &mesh.Animations[i]
+		anim.Name = dec.String()
+		anim.Channels = make([]AnimationChannel, dec.Count())
+		for c := range anim.Channels {
+			channel := &anim.Channels[c]
+			channel.Node = dec.Int()
+			channel.Path = dec.String()
+			channel.Sampler = dec.Int()
+		}
+		anim.Samplers = make([]AnimationSampler, dec.Count())
+		for s := range anim.Samplers {
+			sampler := &anim.Samplers[s]
+			sampler.Times = dec.Floats(dec.Count())
+			sampler.Values = dec.Floats(dec.Count())
+			sampler.Components = dec.Int()
+			sampler.Interpolation = dec.String()
+		}
+	}
+
+	if count := dec.Count(); count > 0 {
+		mesh.SmoothingGroups = dec.Uints(count)
+	}
+
+	if dec.err != nil {
+		return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': %v", filename, dec.err)
+	}
+	for _, index := range mesh.Indices {
+		if index >= header.VertexCount {
+			return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': index out of range", filename)
+		}
+	}
+	for _, sub := range mesh.Submeshes {
+		if sub.Start < 0 || sub.Count < 0 || sub.Start+sub.Count > len(mesh.Indices) {
+			return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': submesh '%v' out of range", filename, sub.Name)
+		}
+	}
+	for _, node := range mesh.Nodes {
+		if node.Skin >= len(mesh.Skins) {
+			return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': node '%v' has an invalid skin", filename, node.Name)
+		}
+		if node.Parent < -1 || node.Parent >= len(mesh.Nodes) {
+			return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': node '%v' has an invalid parent", filename, node.Name)
+		}
+	}
+
+	// Reject cycles, which would hang anything walking up the hierarchy
+	for i := range mesh.Nodes {
+		depth := 0
+		for p := mesh.Nodes[i].Parent; p >= 0; p = mesh.Nodes[p].Parent {
+			depth++
+			if depth > len(mesh.Nodes) {
+				return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': node '%v' is part of a cycle", filename, mesh.Nodes[i].Name)
+			}
+		}
+	}
+	for i, skin := range mesh.Skins {
+		if len(skin.InverseBindMatrices) != len(skin.Joints) {
+			return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': skin %v has mismatched inverse bind matrices", filename, i)
+		}
+		for _, joint := range skin.Joints {
+			if joint < 0 || joint >= len(mesh.Nodes) {
+				return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': skin %v has invalid joint %v", filename, i, joint)
+			}
+		}
+	}
+	for _, anim := range mesh.Animations {
+		for s, sampler := range anim.Samplers {
+			// Cubic splines store an in-tangent, value and out-tangent for each keyframe
+			perKey := 1
+			if sampler.Interpolation == INTERPOLATION_CUBICSPLINE {
+				perKey = 3
+			}
+			if sampler.Components < 0 || len(sampler.Values) != len(sampler.Times)*sampler.Components*perKey {
+				return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': animation '%v' sampler %v has mismatched keyframes", filename, anim.Name, s)
+			}
+		}
+		for _, channel := range anim.Channels {
+			if channel.Node < 0 || channel.Node >= len(mesh.Nodes) || channel.Sampler < 0 || channel.Sampler >= len(anim.Samplers) {
+				return nil, 0, nil, fmt.Errorf("Malformed mesh cache '%v': animation '%v' has an invalid channel", filename, anim.Name)
+			}
+		}
+	}
+
+	mesh.deinterleave(header.Format, vertices)
+
+	return mesh, header.Format, vertices, nil
+}
+
+type meshCacheWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *meshCacheWriter) Uint(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *meshCacheWriter) Int(v int) {
+	w.Uint(uint32(int32(v)))
+}
+
+func (w *meshCacheWriter) Ints(v []int) {
+	w.Uint(uint32(len(v)))
+	for _, i := range v {
+		w.Int(i)
+	}
+}
+
+func (w *meshCacheWriter) Bool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *meshCacheWriter) Floats(v []float32) {
+	for _, f := range v {
+		w.Uint(math.Float32bits(f))
+	}
+}
+
+func (w *meshCacheWriter) Bytes(v []byte) {
+	w.Uint(uint32(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *meshCacheWriter) String(v string) {
+	w.Bytes([]byte(v))
+}
+
+func (w *meshCacheWriter) TextureMap(texMap *TextureMap) {
+	w.String(texMap.Filename)
+	w.Bool(texMap.Clamp)
+	w.Bool(texMap.Mirror)
+	w.Bytes(texMap.Data)
+	w.Bool(texMap.Options != nil)
+	if opts := texMap.Options; opts != nil {
+		w.Int(int(opts.MinFilter))
+		w.Int(int(opts.MagFilter))
+		w.Int(int(opts.WrapS))
+		w.Int(int(opts.WrapT))
+		w.Int(int(opts.WrapR))
+		w.Floats([]float32{opts.Anisotropy})
+		w.Bool(opts.Mipmaps)
+		w.Bool(opts.SRGB)
+	}
+	w.Floats(texMap.Scale[:])
+	w.Floats(texMap.Offset[:])
+	w.Floats([]float32{texMap.BumpMultiplier})
+	w.String(texMap.Channel)
+	w.String(texMap.Type)
+}
+
+// meshCacheReader reads the values written by meshCacheWriter. After the first error it returns
+// zero values, so err only needs to be checked at the end.
+type meshCacheReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *meshCacheReader) next(size int) []byte {
+	if r.err == nil && (size < 0 || r.pos+size > len(r.data)) {
+		r.err = fmt.Errorf("unexpected end of file")
+	}
+	if r.err != nil {
+		return nil
+	}
+	b := r.data[r.pos : r.pos+size]
+	r.pos += size
+	return b
+}
+
+func (r *meshCacheReader) Uint() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *meshCacheReader) Int() int {
+	return int(int32(r.Uint()))
+}
+
+// Count reads a number of elements, checking that it fits in the rest of the file
+func (r *meshCacheReader) Count() int {
+	count := int(r.Uint())
+	if r.err == nil && count > len(r.data)-r.pos {
+		r.err = fmt.Errorf("count %v out of range", count)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return count
+}
+
+func (r *meshCacheReader) Ints() []int {
+	v := make([]int, r.Count())
+	for i := range v {
+		v[i] = r.Int()
+	}
+	return v
+}
+
+func (r *meshCacheReader) Uints(count int) []uint32 {
+	b := r.next(count * 4)
+	v := make([]uint32, len(b)/4)
+	for i := range v {
+		v[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return v
+}
+
+func (r *meshCacheReader) Bool() bool {
+	b := r.next(1)
+	return b != nil && b[0] != 0
+}
+
+func (r *meshCacheReader) Float() float32 {
+	return math.Float32frombits(r.Uint())
+}
+
+func (r *meshCacheReader) Floats(count int) []float32 {
+	b := r.next(count * 4)
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return v
+}
+
+func (r *meshCacheReader) Bytes() []byte {
+	b := r.next(r.Count())
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (r *meshCacheReader) String() string {
+	return string(r.next(r.Count()))
+}
+
+func (r *meshCacheReader) TextureMap() TextureMap {
+	texMap := TextureMap{
+		Filename: r.String(),
+		Clamp:    r.Bool(),
+		Mirror:   r.Bool(),
+		Data:     r.Bytes(),
+	}
+	if r.Bool() {
+		texMap.Options = &TextureOptions{
+			MinFilter:  int32(r.Int()),
+			MagFilter:  int32(r.Int()),
+			WrapS:      int32(r.Int()),
+			WrapT:      int32(r.Int()),
+			WrapR:      int32(r.Int()),
+			Anisotropy: r.Float(),
+			Mipmaps:    r.Bool(),
+			SRGB:       r.Bool(),
+		}
+	}
+	copy(texMap.Scale[:], r.Floats(3))
+	copy(texMap.Offset[:], r.Floats(3))
+	texMap.BumpMultiplier = r.Float()
+	texMap.Channel = r.String()
+	texMap.Type = r.String()
+	return texMap
+}
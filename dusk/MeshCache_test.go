@@ -0,0 +1,79 @@
+package dusk
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLoadMeshCache(t *testing.T) {
+	quietLogs(t)
+
+	tests := []struct {
+		Name   string
+		Modify func(mesh *MeshData)
+		Error  bool
+	}{
+		{"Valid", func(mesh *MeshData) {}, false},
+		{"Sampler missing values", func(mesh *MeshData) {
+			sampler := &mesh.Animations[0].Samplers[0]
+			sampler.Values = sampler.Values[:len(sampler.Values)-1]
+		}, true},
+		{"Sampler with extra components", func(mesh *MeshData) {
+			mesh.Animations[0].Samplers[0].Components = 5
+		}, true},
+		{"Linear sampler with spline values", func(mesh *MeshData) {
+			mesh.Animations[0].Samplers[0].Interpolation = INTERPOLATION_CUBICSPLINE
+		}, true},
+		{"Channel node out of range", func(mesh *MeshData) {
+			mesh.Animations[0].Channels[0].Node = len(mesh.Nodes)
+		}, true},
+		{"Channel sampler out of range", func(mesh *MeshData) {
+			mesh.Animations[0].Channels[0].Sampler = 1
+		}, true},
+		{"Negative channel sampler", func(mesh *MeshData) {
+			mesh.Animations[0].Channels[0].Sampler = -1
+		}, true},
+		{"Joint out of range", func(mesh *MeshData) {
+			mesh.Skins[0].Joints[1] = len(mesh.Nodes)
+		}, true},
+		{"Missing inverse bind matrix", func(mesh *MeshData) {
+			mesh.Skins[0].InverseBindMatrices = mesh.Skins[0].InverseBindMatrices[:1]
+		}, true},
+		{"Node skin out of range", func(mesh *MeshData) {
+			mesh.Nodes[1].Skin = 1
+		}, true},
+		{"Parent out of range", func(mesh *MeshData) {
+			mesh.Nodes[2].Parent = len(mesh.Nodes)
+		}, true},
+		{"Parent cycle", func(mesh *MeshData) {
+			mesh.Nodes[0].Parent = 1
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mesh := testSkinnedMesh()
+			test.Modify(mesh)
+
+			var cache bytes.Buffer
+			if err := WriteMeshCache(&cache, mesh); err != nil {
+				t.Fatal(err)
+			}
+			app := newMemoryApp(map[string][]byte{"skinned.cache": cache.Bytes()})
+			loaded, err := LoadMeshCache(app, "skinned.cache")
+			if test.Error {
+				if err == nil {
+					t.Errorf("Loaded an invalid mesh cache")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(loaded.Skins) != 1 || len(loaded.Animations) != 1 || loaded.VertexCount() != mesh.VertexCount() {
+				t.Errorf("Got %v skins, %v animations and %v vertices, expected 1, 1 and %v",
+					len(loaded.Skins), len(loaded.Animations), loaded.VertexCount(), mesh.VertexCount())
+			}
+		})
+	}
+}
@@ -0,0 +1,574 @@
+package dusk
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// MaterialDef describes a material as loaded from a file, without any GPU resources
+type MaterialDef struct {
+	Ambient            mgl32.Vec3
+	Diffuse            mgl32.Vec3
+	Specular           mgl32.Vec3
+	Emissive           mgl32.Vec3
+	TransmissionFilter mgl32.Vec3
+	Shininess          float32
+	Dissolve           float32
+	IOR                float32
+
+	// Illum is the MTL illumination model, 0 is unlit, 1 is diffuse only, 2 and up add specular
+	Illum int
+
+	AmbientMap      TextureMap
+	DiffuseMap      TextureMap
+	SpecularMap     TextureMap
+	ShininessMap    TextureMap
+	EmissiveMap     TextureMap
+	AlphaMap        TextureMap
+	BumpMap         TextureMap
+	NormalMap       TextureMap
+	DisplacementMap TextureMap
+	DecalMap        TextureMap
+	ReflectionMap   TextureMap
+
+	// Metallic-roughness parameters from glTF, roughness is read from the green channel of
+	// MetallicRoughnessMap and metallic from the blue channel
+	Metallic             float32
+	Roughness            float32
+	MetallicRoughnessMap TextureMap
+	OcclusionMap         TextureMap
+
+	// AlphaCutoff discards fragments with a lower alpha instead of blending them, 0 disables it
+	AlphaCutoff float32
+	DoubleSided bool
+}
+
+// TextureMaps returns pointers to all of the material's maps, in a fixed order
+func (def *MaterialDef) TextureMaps() []*TextureMap {
+	return []*TextureMap{
+		&def.AmbientMap,
+		&def.DiffuseMap,
+		&def.SpecularMap,
+		&def.ShininessMap,
+		&def.EmissiveMap,
+		&def.AlphaMap,
+		&def.BumpMap,
+		&def.NormalMap,
+		&def.DisplacementMap,
+		&def.DecalMap,
+		&def.ReflectionMap,
+		&def.MetallicRoughnessMap,
+		&def.OcclusionMap,
+	}
+}
+
+// NewMaterialDef returns an opaque grey material without specular highlights
+func NewMaterialDef() *MaterialDef {
+	return &MaterialDef{
+		Ambient:            mgl32.Vec3{0.2, 0.2, 0.2},
+		Diffuse:            mgl32.Vec3{0.8, 0.8, 0.8},
+		TransmissionFilter: mgl32.Vec3{1, 1, 1},
+		Dissolve:           1,
+		IOR:                1,
+		Illum:              2,
+		Roughness:          1,
+	}
+}
+
+// Submesh is a range of triangles in MeshData.Indices drawn with one material
+type Submesh struct {
+	Name     string
+	Start    int
+	Count    int
+	Material string
+
+	// Node is the index into MeshData.Nodes that places the submesh, ignored if there are no nodes
+	Node int
+}
+
+// MeshData is an indexed triangle mesh in CPU memory. Every attribute other than Positions is
+// optional, and is either empty or has one element per position.
+type MeshData struct {
+	Positions []mgl32.Vec3
+	Normals   []mgl32.Vec3
+	TexCoords []mgl32.Vec2
+	Tangents  []mgl32.Vec4
+	Colors    []mgl32.Vec4
+	Indices   []uint32
+	Submeshes []Submesh
+	Materials map[string]*MaterialDef
+
+	// SmoothingGroups has one entry per triangle, or is empty if the whole mesh is smooth.
+	// Triangles in group 0 are flat shaded.
+	SmoothingGroups []uint32
+
+	// Joints and Weights bind each vertex to up to four joints of a skin
+	Joints  [][4]uint16
+	Weights []mgl32.Vec4
+
+	Nodes      []MeshNode
+	Skins      []MeshSkin
+	Animations []MeshAnimation
+}
+
+func NewMeshData() *MeshData {
+	return &MeshData{
+		Materials: map[string]*MaterialDef{},
+	}
+}
+
+// LoadMeshData loads a model file with the loader matching its extension
+func LoadMeshData(app *App, filename string) (*MeshData, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".obj":
+		return LoadOBJ(app, filename)
+	case ".gltf", ".glb":
+		return LoadGLTF(app, filename)
+	case ".ply":
+		return LoadPLY(app, filename)
+	case ".stl":
+		return LoadSTL(app, filename)
+	case MESH_CACHE_EXT:
+		return LoadMeshCache(app, filename)
+	}
+	return nil, fmt.Errorf("Unsupported model format '%v'", filename)
+}
+
+// Flags for the attributes of interleaved vertices, positions are always present
+const (
+	VERTEX_NORMALS   = 1
+	VERTEX_TEXCOORDS = 2
+	VERTEX_COLORS    = 4
+	VERTEX_TANGENTS  = 8
+	VERTEX_JOINTS    = 16
+	VERTEX_WEIGHTS   = 32
+)
+
+// VertexFormat returns the VERTEX_* flags of the attributes that have one element per vertex
+func (mesh *MeshData) VertexFormat() uint32 {
+	count := mesh.VertexCount()
+	format := uint32(0)
+	if count == 0 {
+		return format
+	}
+	if len(mesh.Normals) == count {
+		format |= VERTEX_NORMALS
+	}
+	if len(mesh.TexCoords) == count {
+		format |= VERTEX_TEXCOORDS
+	}
+	if len(mesh.Colors) == count {
+		format |= VERTEX_COLORS
+	}
+	if len(mesh.Tangents) == count {
+		format |= VERTEX_TANGENTS
+	}
+	if len(mesh.Joints) == count {
+		format |= VERTEX_JOINTS
+	}
+	if len(mesh.Weights) == count {
+		format |= VERTEX_WEIGHTS
+	}
+	return format
+}
+
+// Interleave packs the positions and the attributes in format into one buffer, in the order
+// Model uploads them: position, normal, texture coordinate, color, tangent, joints, weights.
+// Joint indices are stored as floats.
+func (mesh *MeshData) Interleave(format uint32) []float32 {
+	count := mesh.VertexCount()
+	vertices := make([]float32, 0, count*vertexFloats(format))
+	for i := 0; i < count; i++ {
+		vertices = append(vertices, mesh.Positions[i][:]...)
+		if format&VERTEX_NORMALS != 0 {
+			vertices = append(vertices, mesh.Normals[i][:]...)
+		}
+		if format&VERTEX_TEXCOORDS != 0 {
+			vertices = append(vertices, mesh.TexCoords[i][:]...)
+		}
+		if format&VERTEX_COLORS != 0 {
+			vertices = append(vertices, mesh.Colors[i][:]...)
+		}
+		if format&VERTEX_TANGENTS != 0 {
+			vertices = append(vertices, mesh.Tangents[i][:]...)
+		}
+		if format&VERTEX_JOINTS != 0 {
+			joints := mesh.Joints[i]
+			vertices = append(vertices, float32(joints[0]), float32(joints[1]), float32(joints[2]), float32(joints[3]))
+		}
+		if format&VERTEX_WEIGHTS != 0 {
+			vertices = append(vertices, mesh.Weights[i][:]...)
+		}
+	}
+	return vertices
+}
+
+// deinterleave replaces the attributes in format with ones read from an Interleave buffer
+func (mesh *MeshData) deinterleave(format uint32, vertices []float32) {
+	floats := vertexFloats(format)
+	count := len(vertices) / floats
+
+	mesh.Positions = make([]mgl32.Vec3, count)
+	mesh.Normals = nil
+	mesh.TexCoords = nil
+	mesh.Colors = nil
+	mesh.Tangents = nil
+	mesh.Joints = nil
+	mesh.Weights = nil
+	if format&VERTEX_NORMALS != 0 {
+		mesh.Normals = make([]mgl32.Vec3, count)
+	}
+	if format&VERTEX_TEXCOORDS != 0 {
+		mesh.TexCoords = make([]mgl32.Vec2, count)
+	}
+	if format&VERTEX_COLORS != 0 {
+		mesh.Colors = make([]mgl32.Vec4, count)
+	}
+	if format&VERTEX_TANGENTS != 0 {
+		mesh.Tangents = make([]mgl32.Vec4, count)
+	}
+	if format&VERTEX_JOINTS != 0 {
+		mesh.Joints = make([][4]uint16, count)
+	}
+	if format&VERTEX_WEIGHTS != 0 {
+		mesh.Weights = make([]mgl32.Vec4, count)
+	}
+
+	for i := 0; i < count; i++ {
+		vertex := vertices[i*floats : (i+1)*floats]
+		n := copy(mesh.Positions[i][:], vertex)
+		if format&VERTEX_NORMALS != 0 {
+			n += copy(mesh.Normals[i][:], vertex[n:])
+		}
+		if format&VERTEX_TEXCOORDS != 0 {
+			n += copy(mesh.TexCoords[i][:], vertex[n:])
+		}
+		if format&VERTEX_COLORS != 0 {
+			n += copy(mesh.Colors[i][:], vertex[n:])
+		}
+		if format&VERTEX_TANGENTS != 0 {
+			n += copy(mesh.Tangents[i][:], vertex[n:])
+		}
+		if format&VERTEX_JOINTS != 0 {
+			for j := range mesh.Joints[i] {
+				mesh.Joints[i][j] = uint16(vertex[n+j])
+			}
+			n += 4
+		}
+		if format&VERTEX_WEIGHTS != 0 {
+			copy(mesh.Weights[i][:], vertex[n:])
+		}
+	}
+}
+
+// VertexCount returns the number of unique vertices
+func (mesh *MeshData) VertexCount() int {
+	return len(mesh.Positions)
+}
+
+// TriangleCount returns the number of triangles in all submeshes
+func (mesh *MeshData) TriangleCount() int {
+	return len(mesh.Indices) / 3
+}
+
+// Bounds returns the box and sphere around every vertex used by a triangle, before node transforms
+func (mesh *MeshData) Bounds() (AABB, BoundingSphere) {
+	return boundsOfIndices(mesh.Positions, mesh.Indices)
+}
+
+// SubmeshBounds returns the box and sphere around the vertices of one submesh
+func (mesh *MeshData) SubmeshBounds(sub Submesh) (AABB, BoundingSphere) {
+	return boundsOfIndices(mesh.Positions, mesh.Indices[sub.Start:sub.Start+sub.Count])
+}
+
+// OptimizeVertexCache reorders the triangles of each submesh for the GPU's post-transform cache
+func (mesh *MeshData) OptimizeVertexCache() {
+	for _, sub := range mesh.Submeshes {
+		indices := mesh.Indices[sub.Start : sub.Start+sub.Count]
+		if len(indices) == 0 {
+			continue
+		}
+
+		minIndex, maxIndex := indices[0], indices[0]
+		for _, index := range indices {
+			if index < minIndex {
+				minIndex = index
+			}
+			if index > maxIndex {
+				maxIndex = index
+			}
+		}
+		order := optimizeVertexCache(indices, int(minIndex), int(maxIndex-minIndex)+1)
+
+		// Keep the per-triangle data in the same order as the triangles
+		if order != nil && len(mesh.SmoothingGroups) > 0 {
+			groups := mesh.SmoothingGroups[sub.Start/3 : (sub.Start+sub.Count)/3]
+			reordered := make([]uint32, len(groups))
+			for i, tri := range order {
+				reordered[i] = groups[tri]
+			}
+			copy(groups, reordered)
+		}
+	}
+}
+
+// GenerateNormals replaces the normals with ones averaged from the faces around each position.
+// Faces are only averaged with faces in the same smoothing group, and faces in group 0 are flat.
+// Vertices are split where they need more than one normal.
+func (mesh *MeshData) GenerateNormals() {
+	mesh.GenerateNormalsWithCrease(0)
+}
+
+// GenerateNormalsWithCrease is GenerateNormals, except that faces in the same smoothing group
+// whose normals differ by more than creaseAngle radians aren't averaged. Zero disables the crease.
+func (mesh *MeshData) GenerateNormalsWithCrease(creaseAngle float32) {
+	triCount := mesh.TriangleCount()
+
+	// Unnormalized face normals weight the average by area
+	faceNormals := make([]mgl32.Vec3, triCount)
+	unitNormals := make([]mgl32.Vec3, triCount)
+	for t := range faceNormals {
+		a := mesh.Positions[mesh.Indices[t*3]]
+		b := mesh.Positions[mesh.Indices[t*3+1]]
+		c := mesh.Positions[mesh.Indices[t*3+2]]
+		faceNormals[t] = b.Sub(a).Cross(c.Sub(a))
+		if faceNormals[t].Len() > 0 {
+			unitNormals[t] = faceNormals[t].Normalize()
+		}
+	}
+
+	minCos := float32(-1)
+	if creaseAngle > 0 && creaseAngle < math.Pi {
+		minCos = float32(math.Cos(float64(creaseAngle)))
+	}
+
+	smoothingGroup := func(t int) uint32 {
+		if len(mesh.SmoothingGroups) == 0 {
+			return 1
+		}
+		return mesh.SmoothingGroups[t]
+	}
+
+	// Positions are welded by value, as faces with different texture coordinates still share them
+	type smoothKey struct {
+		Position mgl32.Vec3
+		Group    uint32
+	}
+
+	adjacent := map[smoothKey][]int{}
+	for t := 0; t < triCount; t++ {
+		group := smoothingGroup(t)
+		if group == 0 {
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			key := smoothKey{mesh.Positions[mesh.Indices[t*3+i]], group}
+			adjacent[key] = append(adjacent[key], t)
+		}
+	}
+
+	// Split vertices that end up with more than one normal
+	type vertKey struct {
+		Index  uint32
+		Normal mgl32.Vec3
+	}
+
+	newIndices := map[vertKey]uint32{}
+	remap := []uint32{}
+	normals := []mgl32.Vec3{}
+
+	for t := 0; t < triCount; t++ {
+		group := smoothingGroup(t)
+		for i := 0; i < 3; i++ {
+			index := mesh.Indices[t*3+i]
+
+			normal := faceNormals[t]
+			if group != 0 {
+				normal = mgl32.Vec3{}
+				for _, other := range adjacent[smoothKey{mesh.Positions[index], group}] {
+					if other == t || unitNormals[t].Dot(unitNormals[other]) >= minCos {
+						normal = normal.Add(faceNormals[other])
+					}
+				}
+			}
+			if normal.Len() > 0 {
+				normal = normal.Normalize()
+			}
+
+			key := vertKey{index, normal}
+			newIndex, ok := newIndices[key]
+			if !ok {
+				newIndex = uint32(len(remap))
+				newIndices[key] = newIndex
+				remap = append(remap, index)
+				normals = append(normals, normal)
+			}
+			mesh.Indices[t*3+i] = newIndex
+		}
+	}
+
+	mesh.remapVertices(remap)
+	mesh.Normals = normals
+}
+
+// GenerateTangents replaces the tangents with ones following the texture coordinates, as
+// MikkTSpace does: per-face tangents are weighted by the angle of each corner, and made
+// orthogonal to the vertex normal. The w component is the handedness, the bitangent is
+// cross(normal, tangent) * w. Vertices shared by mirrored faces are split. The mesh needs
+// normals and texture coordinates.
+func (mesh *MeshData) GenerateTangents() {
+	count := mesh.VertexCount()
+	if len(mesh.Normals) != count || len(mesh.TexCoords) != count {
+		return
+	}
+
+	triCount := mesh.TriangleCount()
+
+	// Split vertices by the handedness of the faces using them
+	type vertKey struct {
+		Index    uint32
+		Mirrored bool
+	}
+
+	newIndices := map[vertKey]uint32{}
+	remap := []uint32{}
+	signs := []float32{}
+	tangents := []mgl32.Vec3{}
+
+	for t := 0; t < triCount; t++ {
+		var pos [3]mgl32.Vec3
+		var txcd [3]mgl32.Vec2
+		for i := 0; i < 3; i++ {
+			pos[i] = mesh.Positions[mesh.Indices[t*3+i]]
+			txcd[i] = mesh.TexCoords[mesh.Indices[t*3+i]]
+		}
+
+		edge1, edge2 := pos[1].Sub(pos[0]), pos[2].Sub(pos[0])
+		duv1, duv2 := txcd[1].Sub(txcd[0]), txcd[2].Sub(txcd[0])
+
+		// The sign of the determinant gives the orientation, so its magnitude isn't divided out
+		det := duv1[0]*duv2[1] - duv2[0]*duv1[1]
+		tangent := edge1.Mul(duv2[1]).Sub(edge2.Mul(duv1[1]))
+		bitangent := edge2.Mul(duv1[0]).Sub(edge1.Mul(duv2[0]))
+		if det < 0 {
+			tangent = tangent.Mul(-1)
+			bitangent = bitangent.Mul(-1)
+		}
+		if tangent.Len() > 0 {
+			tangent = tangent.Normalize()
+		}
+
+		faceNormal := edge1.Cross(edge2)
+		mirrored := faceNormal.Cross(tangent).Dot(bitangent) < 0
+
+		for i := 0; i < 3; i++ {
+			index := mesh.Indices[t*3+i]
+			key := vertKey{index, mirrored}
+			newIndex, ok := newIndices[key]
+			if !ok {
+				newIndex = uint32(len(remap))
+				newIndices[key] = newIndex
+				remap = append(remap, index)
+				sign := float32(1)
+				if mirrored {
+					sign = -1
+				}
+				signs = append(signs, sign)
+				tangents = append(tangents, mgl32.Vec3{})
+			}
+			mesh.Indices[t*3+i] = newIndex
+
+			// Weight by the angle of the corner
+			a := pos[(i+1)%3].Sub(pos[i])
+			b := pos[(i+2)%3].Sub(pos[i])
+			if a.Len() > 0 && b.Len() > 0 {
+				cos := mgl32.Clamp(a.Normalize().Dot(b.Normalize()), -1, 1)
+				angle := float32(math.Acos(float64(cos)))
+				tangents[newIndex] = tangents[newIndex].Add(tangent.Mul(angle))
+			}
+		}
+	}
+
+	mesh.remapVertices(remap)
+
+	mesh.Tangents = make([]mgl32.Vec4, len(remap))
+	for i, tangent := range tangents {
+		normal := mesh.Normals[i]
+
+		// Gram-Schmidt against the normal, with any perpendicular vector as a fallback
+		tangent = tangent.Sub(normal.Mul(normal.Dot(tangent)))
+		if tangent.Len() < 1e-6 {
+			axis := mgl32.Vec3{1, 0, 0}
+			if abs32(normal[0]) > 0.9 {
+				axis = mgl32.Vec3{0, 1, 0}
+			}
+			tangent = axis.Sub(normal.Mul(normal.Dot(axis)))
+		}
+		tangent = tangent.Normalize()
+
+		mesh.Tangents[i] = tangent.Vec4(signs[i])
+	}
+}
+
+// remapVertices rebuilds the vertex attributes so that new vertex i is a copy of vertex remap[i]
+func (mesh *MeshData) remapVertices(remap []uint32) {
+	count := mesh.VertexCount()
+
+	positions := make([]mgl32.Vec3, len(remap))
+	for i, index := range remap {
+		positions[i] = mesh.Positions[index]
+	}
+	mesh.Positions = positions
+
+	if len(mesh.Normals) == count {
+		normals := make([]mgl32.Vec3, len(remap))
+		for i, index := range remap {
+			normals[i] = mesh.Normals[index]
+		}
+		mesh.Normals = normals
+	}
+
+	if len(mesh.TexCoords) == count {
+		txcds := make([]mgl32.Vec2, len(remap))
+		for i, index := range remap {
+			txcds[i] = mesh.TexCoords[index]
+		}
+		mesh.TexCoords = txcds
+	}
+
+	if len(mesh.Tangents) == count {
+		tangents := make([]mgl32.Vec4, len(remap))
+		for i, index := range remap {
+			tangents[i] = mesh.Tangents[index]
+		}
+		mesh.Tangents = tangents
+	}
+
+	if len(mesh.Colors) == count {
+		colors := make([]mgl32.Vec4, len(remap))
+		for i, index := range remap {
+			colors[i] = mesh.Colors[index]
+		}
+		mesh.Colors = colors
+	}
+
+	if len(mesh.Joints) == count {
+		joints := make([][4]uint16, len(remap))
+		for i, index := range remap {
+			joints[i] = mesh.Joints[index]
+		}
+		mesh.Joints = joints
+	}
+
+	if len(mesh.Weights) == count {
+		weights := make([]mgl32.Vec4, len(remap))
+		for i, index := range remap {
+			weights[i] = mesh.Weights[index]
+		}
+		mesh.Weights = weights
+	}
+}
@@ -0,0 +1,97 @@
+package dusk
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// MeshNode is a node of a scene hierarchy, as found in glTF files
+type MeshNode struct {
+	Name     string
+	Parent   int
+	Children []int
+
+	Translation mgl32.Vec3
+	Rotation    mgl32.Quat
+	Scale       mgl32.Vec3
+
+	// Skin is an index into MeshData.Skins, or -1
+	Skin int
+}
+
+// LocalTransform returns the node's transform relative to its parent
+func (node *MeshNode) LocalTransform() mgl32.Mat4 {
+	return mgl32.Translate3D(node.Translation[0], node.Translation[1], node.Translation[2]).
+		Mul4(node.Rotation.Mat4()).
+		Mul4(mgl32.Scale3D(node.Scale[0], node.Scale[1], node.Scale[2]))
+}
+
+// MeshSkin binds vertices to a set of joint nodes
+type MeshSkin struct {
+	Name string
+
+	// Joints are indices into MeshData.Nodes, vertex joint indices refer to this list
+	Joints              []int
+	InverseBindMatrices []mgl32.Mat4
+
+	// Skeleton is the root node of the joint hierarchy, or -1
+	Skeleton int
+}
+
+// Animation interpolation modes
+const (
+	INTERPOLATION_LINEAR      = "LINEAR"
+	INTERPOLATION_STEP        = "STEP"
+	INTERPOLATION_CUBICSPLINE = "CUBICSPLINE"
+)
+
+// Animated node properties
+const (
+	ANIMATION_TRANSLATION = "translation"
+	ANIMATION_ROTATION    = "rotation"
+	ANIMATION_SCALE       = "scale"
+	ANIMATION_WEIGHTS     = "weights"
+)
+
+// AnimationSampler holds keyframe times and values. Values has Components floats per keyframe,
+// three times that for cubic splines, which store in-tangent, value and out-tangent.
+type AnimationSampler struct {
+	Times         []float32
+	Values        []float32
+	Components    int
+	Interpolation string
+}
+
+// AnimationChannel animates one property of a node with a sampler
+type AnimationChannel struct {
+	Node    int
+	Path    string
+	Sampler int
+}
+
+type MeshAnimation struct {
+	Name     string
+	Channels []AnimationChannel
+	Samplers []AnimationSampler
+}
+
+// Duration returns the time of the last keyframe
+func (anim *MeshAnimation) Duration() float32 {
+	duration := float32(0)
+	for _, sampler := range anim.Samplers {
+		if len(sampler.Times) > 0 && sampler.Times[len(sampler.Times)-1] > duration {
+			duration = sampler.Times[len(sampler.Times)-1]
+		}
+	}
+	return duration
+}
+
+// NodeWorldTransform returns the transform of a node relative to the scene root
+func (mesh *MeshData) NodeWorldTransform(index int) mgl32.Mat4 {
+	transform := mgl32.Ident4()
+	for index >= 0 && index < len(mesh.Nodes) {
+		node := &mesh.Nodes[index]
+		transform = node.LocalTransform().Mul4(transform)
+		index = node.Parent
+	}
+	return transform
+}
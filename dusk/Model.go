@@ -1,10 +1,10 @@
 package dusk
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
+	"math"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
@@ -15,28 +15,136 @@ const (
 	VERT_ATTRIB = 0
 	NORM_ATTRIB = 1
 	TXCD_ATTRIB = 2
+	COLR_ATTRIB = 3
+	TANG_ATTRIB = 4
+	JOIN_ATTRIB = 10
+	WGHT_ATTRIB = 11
+
+	// Per-instance attributes for RenderInstanced, the transform uses four locations
+	INST_ATTRIB      = 5
+	INST_COLR_ATTRIB = 9
 )
 
+// Floats per instance, a transform and a color
+const instanceFloats = 16 + 4
+
+// Most joints a skinned part can have, the size of the _JointMatrices uniform
+const MAX_JOINTS = 64
+
+// vertexAttributes lists the optional attributes in the order they're interleaved after positions
+var vertexAttributes = []struct {
+	Flag  uint32
+	Index uint32
+	Size  int32
+}{
+	{VERTEX_NORMALS, NORM_ATTRIB, 3},
+	{VERTEX_TEXCOORDS, TXCD_ATTRIB, 2},
+	{VERTEX_COLORS, COLR_ATTRIB, 4},
+	{VERTEX_TANGENTS, TANG_ATTRIB, 4},
+	{VERTEX_JOINTS, JOIN_ATTRIB, 4},
+	{VERTEX_WEIGHTS, WGHT_ATTRIB, 4},
+}
+
+// vertexFloats returns the number of floats in an interleaved vertex with the VERTEX_* flags
+func vertexFloats(format uint32) int {
+	floats := 3
+	for _, attrib := range vertexAttributes {
+		if format&attrib.Flag != 0 {
+			floats += int(attrib.Size)
+		}
+	}
+	return floats
+}
+
 type modelGroup struct {
 	DrawMode uint32
 	Start    int32
 	Count    int32
 	Material *Material
+	Bounds   AABB
+}
+
+// ModelPart is a named group or object of a model. Its Transform is applied before the model's,
+// through the _Part uniform.
+type ModelPart struct {
+	Name      string
+	Visible   bool
+	Transform mgl32.Mat4
+
+	// Bounds and Sphere contain the part's vertices before its Transform. Skinned parts are
+	// bounded in their bind pose.
+	Bounds AABB
+	Sphere BoundingSphere
+
+	// groups has the part's groups for each level of detail, with the full mesh first
+	lods [][]modelGroup
+
+	// node and skin are the indices of the part's mesh node and skin, or -1. joints has the
+	// skin's joint matrices from the last ApplyPose.
+	node   int
+	skin   int
+	joints []mgl32.Mat4
+}
+
+// IsSkinned returns true when the part's vertices are moved by a skin's joints
+func (part *ModelPart) IsSkinned() bool {
+	return len(part.joints) > 0
+}
+
+// lodGroups returns the groups to draw for a level of detail, or the lowest one the part has
+func (part *ModelPart) lodGroups(lod int) []modelGroup {
+	if lod >= len(part.lods) {
+		lod = len(part.lods) - 1
+	}
+	if lod < 0 {
+		return nil
+	}
+	return part.lods[lod]
+}
+
+// Screen size that a model's full detail is meant for, as a fraction of the screen's height
+const LOD_FULL_DETAIL_SIZE = 0.5
+
+// ModelLOD is a level of detail made by Model.GenerateLODs
+type ModelLOD struct {
+	Triangles int
+
+	// Error is how far the surface may have moved from the full mesh, in the model's units
+	Error float32
+
+	// ScreenSize is the fraction of the screen's height the model has to be smaller than for
+	// SelectLOD to use this level. It's not used for the first level.
+	ScreenSize float32
 }
 
 type Model struct {
 	Transform mgl32.Mat4
 
-	glVao  uint32
-	glVbos [3]uint32
-	groups []modelGroup
+	// Parts are in the order they first appear in the mesh's submeshes
+	Parts []*ModelPart
+
+	// Mesh is the data the model was uploaded from, kept for exporters and tools
+	Mesh *MeshData
+
+	// LODs are the levels of detail from GenerateLODs, with the full mesh first. LOD is the
+	// level Render draws, which SelectLOD sets.
+	LODs []ModelLOD
+	LOD  int
+
+	glVao       uint32
+	glVbo       uint32
+	glEbo       uint32
+	glInstances uint32
+	glIndexType uint32
+	indexSize   int32
+	hasColors   bool
+	hasTangents bool
+	hasJoints   bool
 }
 
 func NewModel(app *App) (*Model, error) {
 	return &Model{
 		Transform: mgl32.Ident4(),
-		glVao:     0,
-		glVbos:    [3]uint32{0, 0, 0},
 	}, nil
 }
 
@@ -52,403 +160,553 @@ func NewModelFromFile(app *App, filename string) (*Model, error) {
 	return model, nil
 }
 
+func NewModelFromMesh(app *App, mesh *MeshData) (*Model, error) {
+	model, err := NewModel(app)
+	if err != nil {
+		return model, err
+	}
+	err = model.Upload(app, mesh)
+	if err != nil {
+		return model, err
+	}
+	return model, nil
+}
+
 func (model *Model) Cleanup() {
-	gl.DeleteBuffers(3, &model.glVbos[0])
+	gl.DeleteBuffers(1, &model.glVbo)
+	gl.DeleteBuffers(1, &model.glEbo)
+	gl.DeleteBuffers(1, &model.glInstances)
 	gl.DeleteVertexArrays(1, &model.glVao)
+	model.glVbo = 0
+	model.glEbo = 0
+	model.glInstances = 0
+	model.glVao = 0
+	model.Parts = nil
+	model.LODs = nil
+	model.LOD = 0
 }
 
 func (model *Model) LoadFromFile(app *App, filename string) error {
-	LogLoad("Model '%v'", filename)
+	// Mesh caches are uploaded without interleaving the vertices again
+	if strings.ToLower(path.Ext(filename)) == MESH_CACHE_EXT {
+		mesh, format, vertices, err := readMeshCache(app, filename)
+		if err != nil {
+			return err
+		}
+		return model.upload(app, mesh, format, vertices)
+	}
 
-	// Holds a material
-	type MatDef struct {
-		Ambient     mgl32.Vec3
-		Diffuse     mgl32.Vec3
-		Specular    mgl32.Vec3
-		Shininess   float32
-		Dissolve    float32
-		AmbientMap  string
-		SpecularMap string
-		DiffuseMap  string
-		BumpMap     string
+	mesh, err := LoadMeshData(app, filename)
+	if err != nil {
+		return err
 	}
+	return model.Upload(app, mesh)
+}
+
+// Upload creates the vertex array, buffers and materials for mesh, replacing any previous ones.
+// Vertex attributes are interleaved, and indices are stored as 16-bit when there are few enough
+// vertices.
+func (model *Model) Upload(app *App, mesh *MeshData) error {
+	format := mesh.VertexFormat()
+	return model.upload(app, mesh, format, mesh.Interleave(format))
+}
+
+// upload is Upload with the vertices already interleaved, as read from a mesh cache
+func (model *Model) upload(app *App, mesh *MeshData, format uint32, vertices []float32) error {
+	vertCount := mesh.VertexCount()
+
+	// Create the materials first, so a failure leaves the model untouched
+	materials := map[string]*Material{}
+	parts := []*ModelPart{}
+	partsByName := map[string]*ModelPart{}
+	for _, sub := range mesh.Submeshes {
+		material, ok := materials[sub.Material]
+		if def, found := mesh.Materials[sub.Material]; found && !ok {
+			var err error
+			material, err = NewMaterialFromDef(app, def)
+			if err != nil {
+				return err
+			}
+			materials[sub.Material] = material
+		}
+		part, ok := partsByName[sub.Name]
+		if !ok {
+			part = &ModelPart{
+				Name:      sub.Name,
+				Visible:   true,
+				Transform: mgl32.Ident4(),
+				Bounds:    NewAABB(),
+				node:      -1,
+				skin:      -1,
+			}
 
-	// Holds a single face
-	type Face struct {
-		VertInds [3]int
-		NormInds [3]int
-		TxcdInds [3]int
+			// Parts start at their node's place in the hierarchy, skinned ones are placed by
+			// their joints instead
+			if sub.Node >= 0 && sub.Node < len(mesh.Nodes) {
+				part.node = sub.Node
+				if skin := mesh.Nodes[sub.Node].Skin; skin >= 0 && skin < len(mesh.Skins) {
+					part.skin = skin
+					if joints := len(mesh.Skins[skin].Joints); joints > MAX_JOINTS {
+						LogWarn("Skin '%v' has %v joints, only %v are supported", mesh.Skins[skin].Name, joints, MAX_JOINTS)
+					}
+				} else {
+					part.Transform = mesh.NodeWorldTransform(sub.Node)
+				}
+			}
+			partsByName[sub.Name] = part
+			parts = append(parts, part)
+		}
+		bounds, _ := mesh.SubmeshBounds(sub)
+		part.Bounds = part.Bounds.Union(bounds)
+		if len(part.lods) == 0 {
+			part.lods = [][]modelGroup{nil}
+		}
+		part.lods[0] = append(part.lods[0], modelGroup{
+			DrawMode: gl.TRIANGLES,
+			Start:    int32(sub.Start),
+			Count:    int32(sub.Count),
+			Material: material,
+			Bounds:   bounds,
+		})
 	}
 
-	// Holds a group of faces and a material
-	type Group struct {
-		Name     string
-		Material string
-		Faces    []Face
+	// A part's sphere is fit to all of its vertices, as its groups' spheres would be looser
+	for _, part := range parts {
+		indices := []uint32{}
+		for _, group := range part.lods[0] {
+			indices = append(indices, mesh.Indices[group.Start:group.Start+group.Count]...)
+		}
+		_, part.Sphere = boundsOfIndices(mesh.Positions, indices)
 	}
 
-	LoadMaterials := func(filename string) (map[string]*MatDef, error) {
-		LogLoad("Material '%v'", filename)
+	model.Cleanup()
+	model.Mesh = mesh
+	model.Parts = parts
+	model.hasColors = format&VERTEX_COLORS != 0
+	model.hasTangents = format&VERTEX_TANGENTS != 0
+	model.hasJoints = format&VERTEX_JOINTS != 0 && format&VERTEX_WEIGHTS != 0
 
-		materials := map[string]*MatDef{}
+	gl.GenVertexArrays(1, &model.glVao)
+	gl.BindVertexArray(model.glVao)
 
-		dirname := path.Dir(filename)
+	gl.GenBuffers(1, &model.glVbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, model.glVbo)
+	if len(vertices) > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STATIC_DRAW)
+	}
 
-		// Open the .mtl file
-		data, err := app.AssetFunction(filename)
-		if err != nil {
-			return materials, err
-		}
+	stride := int32(vertexFloats(format) * 4)
 
-		// Create a reader with a specific buffer size, needed by reader.ReadLine()
-		reader := bufio.NewReader(bytes.NewReader(data))
+	offset := 0
+	gl.VertexAttribPointer(VERT_ATTRIB, 3, gl.FLOAT, false, stride, gl.PtrOffset(offset))
+	gl.EnableVertexAttribArray(VERT_ATTRIB)
+	offset += 3 * 4
 
-		var line string
-		var curMat string
+	for _, attrib := range vertexAttributes {
+		if format&attrib.Flag == 0 {
+			continue
+		}
+		gl.VertexAttribPointer(attrib.Index, attrib.Size, gl.FLOAT, false, stride, gl.PtrOffset(offset))
+		gl.EnableVertexAttribArray(attrib.Index)
+		offset += int(attrib.Size) * 4
+	}
 
-		tmp, _, err := reader.ReadLine()
-		for ; err == nil; tmp, _, err = reader.ReadLine() {
-			line = string(tmp)
+	gl.GenBuffers(1, &model.glEbo)
+	if vertCount <= math.MaxUint16+1 {
+		model.glIndexType = gl.UNSIGNED_SHORT
+		model.indexSize = 2
+	} else {
+		model.glIndexType = gl.UNSIGNED_INT
+		model.indexSize = 4
+	}
+	model.uploadIndices(mesh.Indices)
+
+	// Instance attributes advance once per instance, and are only enabled by RenderInstanced
+	gl.GenBuffers(1, &model.glInstances)
+	gl.BindBuffer(gl.ARRAY_BUFFER, model.glInstances)
+	for col := 0; col < 4; col++ {
+		gl.VertexAttribPointer(INST_ATTRIB+uint32(col), 4, gl.FLOAT, false, instanceFloats*4, gl.PtrOffset(col*16))
+		gl.VertexAttribDivisor(INST_ATTRIB+uint32(col), 1)
+	}
+	gl.VertexAttribPointer(INST_COLR_ATTRIB, 4, gl.FLOAT, false, instanceFloats*4, gl.PtrOffset(16*4))
+	gl.VertexAttribDivisor(INST_COLR_ATTRIB, 1)
 
-			// Ignore empty lines and comments
-			if len(line) == 0 || line[0] == '#' {
-				continue
-			}
+	gl.BindVertexArray(0)
 
-			// Split on the first ' ', ignore half lines
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 0 {
-				continue
-			}
+	// Skinned parts start in their rest pose
+	if model.hasJoints && len(mesh.Skins) > 0 {
+		model.ApplyPose(NewPose(mesh))
+	}
 
-			switch parts[0] {
-			case "newmtl":
-
-				curMat = parts[1]
-				materials[curMat] = &MatDef{
-					Ambient:     mgl32.Vec3{0, 0, 0},
-					Diffuse:     mgl32.Vec3{0, 0, 0},
-					Specular:    mgl32.Vec3{0, 0, 0},
-					Shininess:   0.0,
-					Dissolve:    0.0,
-					AmbientMap:  "",
-					SpecularMap: "",
-					DiffuseMap:  "",
-					BumpMap:     "",
-				}
+	return nil
+}
 
-			case "Ka":
-				fmt.Sscanf(parts[1], "%f %f %f",
-					&materials[curMat].Ambient[0],
-					&materials[curMat].Ambient[1],
-					&materials[curMat].Ambient[2],
-				)
-			case "Kd":
-				fmt.Sscanf(parts[1], "%f %f %f",
-					&materials[curMat].Diffuse[0],
-					&materials[curMat].Diffuse[1],
-					&materials[curMat].Diffuse[2],
-				)
-			case "Ks":
-				fmt.Sscanf(parts[1], "%f %f %f",
-					&materials[curMat].Specular[0],
-					&materials[curMat].Specular[1],
-					&materials[curMat].Specular[2],
-				)
-			case "Ns":
-				fmt.Sscanf(parts[1], "%f", &materials[curMat].Shininess)
-			case "d":
-				fmt.Sscanf(parts[1], "%f", &materials[curMat].Dissolve)
-			case "map_Ka":
-				materials[curMat].AmbientMap = path.Join(dirname, parts[1])
-			case "map_Kd":
-				materials[curMat].DiffuseMap = path.Join(dirname, parts[1])
-			case "map_Ks":
-				materials[curMat].SpecularMap = path.Join(dirname, parts[1])
-			case "map_bump":
-				materials[curMat].BumpMap = path.Join(dirname, parts[1])
-			}
+// uploadIndices fills the element buffer with the model's index type, the vertex array must be
+// bound
+func (model *Model) uploadIndices(indices []uint32) {
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, model.glEbo)
+	if len(indices) == 0 {
+		return
+	}
+	if model.glIndexType == gl.UNSIGNED_SHORT {
+		shorts := make([]uint16, len(indices))
+		for i, index := range indices {
+			shorts[i] = uint16(index)
 		}
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(shorts)*2, gl.Ptr(shorts), gl.STATIC_DRAW)
+	} else {
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+	}
+}
 
-		return materials, nil
+// GenerateLODs simplifies the model's mesh to each ratio of its triangles, and uploads the
+// results as levels of detail that share its vertices. Each level is used when the model is
+// smaller on screen than LOD_FULL_DETAIL_SIZE times the square root of its ratio, which keeps
+// about the same number of triangles per pixel. Ratios that can't be reached are skipped.
+func (model *Model) GenerateLODs(ratios ...float32) error {
+	mesh := model.Mesh
+	if mesh == nil {
+		return fmt.Errorf("Model has no mesh to simplify")
 	}
 
-	// Open the .obj file
-	data, err := app.AssetFunction(filename)
-	if err != nil {
-		return err
+	parts := map[string]*ModelPart{}
+	for _, part := range model.Parts {
+		part.lods = part.lods[:1]
+		parts[part.Name] = part
 	}
 
-	// Get the directory name for loading .mtl files
-	dirname := path.Dir(filename)
+	indices := append([]uint32{}, mesh.Indices...)
+	model.LODs = []ModelLOD{{Triangles: mesh.TriangleCount()}}
 
-	// Create a reader with a specific buffer size, needed by reader.ReadLine()
-	reader := bufio.NewReader(bytes.NewReader(data))
+	// Levels go from most to least detailed
+	ratios = append([]float32{}, ratios...)
+	sort.Slice(ratios, func(i, j int) bool { return ratios[i] > ratios[j] })
+	for _, ratio := range ratios {
+		target := int(ratio * float32(mesh.TriangleCount()))
+		if target >= model.LODs[len(model.LODs)-1].Triangles {
+			continue
+		}
 
-	materials := map[string]*MatDef{}
+		lodIndices, submeshes, _, lodError := mesh.simplifyIndices(target, math.MaxFloat32)
+		if len(lodIndices)/3 >= model.LODs[len(model.LODs)-1].Triangles {
+			continue
+		}
 
-	// Create a list of groups, and get a pointer to the first
-	groups := []Group{{}}
-	group := &groups[0]
+		for _, part := range model.Parts {
+			part.lods = append(part.lods, nil)
+		}
+		for _, sub := range submeshes {
+			// Submeshes are in the same order as the part's groups for the full mesh
+			part := parts[sub.Name]
+			lod := &part.lods[len(part.lods)-1]
+			bounds, _ := boundsOfIndices(mesh.Positions, lodIndices[sub.Start:sub.Start+sub.Count])
+			*lod = append(*lod, modelGroup{
+				DrawMode: gl.TRIANGLES,
+				Start:    int32(len(indices) + sub.Start),
+				Count:    int32(sub.Count),
+				Material: part.lods[0][len(*lod)].Material,
+				Bounds:   bounds,
+			})
+		}
+		indices = append(indices, lodIndices...)
 
-	// Create the list of all Vertices, Normals, and Texture Coordinates
-	allVerts := []mgl32.Vec3{}
-	allNorms := []mgl32.Vec3{}
-	allTxcds := []mgl32.Vec2{}
+		model.LODs = append(model.LODs, ModelLOD{
+			Triangles:  len(lodIndices) / 3,
+			Error:      lodError,
+			ScreenSize: LOD_FULL_DETAIL_SIZE * float32(math.Sqrt(float64(ratio))),
+		})
+	}
 
-	var line string
-	var count int
+	gl.BindVertexArray(model.glVao)
+	model.uploadIndices(indices)
+	gl.BindVertexArray(0)
 
-	tmpVec3 := mgl32.Vec3{}
-	tmpVec2 := mgl32.Vec2{}
-	tmpFace := Face{}
+	LogInfo("Generated %v levels of detail, down to %v triangles", len(model.LODs)-1, model.LODs[len(model.LODs)-1].Triangles)
+	return nil
+}
 
-	tmp, _, err := reader.ReadLine()
-	for ; err == nil; tmp, _, err = reader.ReadLine() {
-		line = string(tmp)
+// SelectLOD sets LOD from how large the model is on the camera's screen, and returns it
+func (model *Model) SelectLOD(camera *Camera) int {
+	size := camera.ScreenSize(model.WorldBoundingSphere())
+	model.LOD = 0
+	for i := 1; i < len(model.LODs); i++ {
+		if size < model.LODs[i].ScreenSize {
+			model.LOD = i
+		}
+	}
+	return model.LOD
+}
 
-		// Ignore empty lines and comments
-		if len(line) == 0 || line[0] == '#' {
+// ApplyPose moves the model's parts to pose, which must be for the model's mesh. Parts follow
+// their nodes, and skinned parts get the joint matrices that move their vertices.
+func (model *Model) ApplyPose(pose *Pose) {
+	mesh := model.Mesh
+	if mesh == nil || len(pose.Translations) != len(mesh.Nodes) {
+		return
+	}
+
+	world := pose.WorldTransforms(mesh)
+	for _, part := range model.Parts {
+		if part.node < 0 {
 			continue
 		}
-
-		// Split on the first ' ', ignore half lines
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) == 0 {
+		if part.skin < 0 || !model.hasJoints {
+			part.Transform = world[part.node]
 			continue
 		}
 
-		switch parts[0] {
-		case "usemtl":
-
-			group.Material = parts[1]
-
-		case "mtllib":
-
-			newmats, err := LoadMaterials(path.Join(dirname, parts[1]))
-			if err != nil {
-				return err
+		skin := &mesh.Skins[part.skin]
+		count := len(skin.Joints)
+		if count > MAX_JOINTS {
+			count = MAX_JOINTS
+		}
+		if len(part.joints) != count {
+			part.joints = make([]mgl32.Mat4, count)
+		}
+		for i := range part.joints {
+			joint := mgl32.Ident4()
+			if node := skin.Joints[i]; node >= 0 && node < len(world) {
+				joint = world[node]
 			}
-			for k, v := range newmats {
-				materials[k] = v
+			if i < len(skin.InverseBindMatrices) {
+				joint = joint.Mul4(skin.InverseBindMatrices[i])
 			}
+			part.joints[i] = joint
+		}
+	}
+}
 
-		case "o":
-			fallthrough
-		case "g":
-
-			if group.Name == "" {
-				group.Name = parts[1]
-			} else {
-				groups = append(groups, Group{
-					Name: parts[1],
-				})
-				group = &groups[len(groups)-1]
-			}
+// Part returns the first part with the given name, or nil
+func (model *Model) Part(name string) *ModelPart {
+	for _, part := range model.Parts {
+		if part.Name == name {
+			return part
+		}
+	}
+	return nil
+}
 
-		case "f":
-
-			// Test for and parse faces in the 'v//vn v//vn v//vn' format
-			if strings.Contains(parts[1], "//") {
-				count, err = fmt.Sscanf(parts[1],
-					"%d//%d %d//%d %d//%d",
-					&tmpFace.VertInds[0],
-					&tmpFace.NormInds[0],
-					&tmpFace.VertInds[1],
-					&tmpFace.NormInds[1],
-					&tmpFace.VertInds[2],
-					&tmpFace.NormInds[2],
-				)
-				if err != nil || count != 6 {
-					return fmt.Errorf("Malformed OBJ file '%v' %v", line)
-				}
-				// Test for and parse faces in the 'v/vt v/vt v/vt' format
-			} else if strings.Count(parts[1], "/") == 3 {
-				count, err = fmt.Sscanf(parts[1],
-					"%d/%d %d/%d %d/%d",
-					&tmpFace.VertInds[0],
-					&tmpFace.TxcdInds[0],
-					&tmpFace.VertInds[1],
-					&tmpFace.TxcdInds[1],
-					&tmpFace.VertInds[2],
-					&tmpFace.TxcdInds[2],
-				)
-				if err != nil || count != 6 {
-					return fmt.Errorf("Malformed OBJ file '%v'", line)
-				}
-				// Test for and parse faces in the 'v/vt/vn v/vt/vn v/vt/vn' format
-			} else if strings.Count(parts[1], "/") == 6 {
-				count, err = fmt.Sscanf(parts[1],
-					"%d/%d/%d %d/%d/%d %d/%d/%d",
-					&tmpFace.VertInds[0],
-					&tmpFace.TxcdInds[0],
-					&tmpFace.NormInds[0],
-					&tmpFace.VertInds[1],
-					&tmpFace.TxcdInds[1],
-					&tmpFace.NormInds[1],
-					&tmpFace.VertInds[2],
-					&tmpFace.TxcdInds[2],
-					&tmpFace.NormInds[2],
-				)
-				if err != nil || count != 9 {
-					return fmt.Errorf("Malformed OBJ file '%v'", line)
-				}
-			} else {
-				return fmt.Errorf("Malformed OBJ file '%v'", line)
-			}
+// Bounds returns the box containing the visible parts, in the model's space before its Transform
+func (model *Model) Bounds() AABB {
+	box := NewAABB()
+	for _, part := range model.Parts {
+		if part.Visible {
+			box = box.Union(part.Bounds.Transform(part.Transform))
+		}
+	}
+	return box
+}
 
-			group.Faces = append(group.Faces, tmpFace)
+// BoundingSphere returns the sphere containing the visible parts, in the model's space before its
+// Transform. It's centered on Bounds.
+func (model *Model) BoundingSphere() BoundingSphere {
+	box := model.Bounds()
+	if box.IsEmpty() {
+		return BoundingSphere{Radius: -1}
+	}
 
-		case "v":
+	sphere := BoundingSphere{Center: box.Center()}
+	for _, part := range model.Parts {
+		if !part.Visible || part.Sphere.IsEmpty() {
+			continue
+		}
+		partSphere := part.Sphere.Transform(part.Transform)
+		sphere.Radius = float32(math.Max(float64(sphere.Radius), float64(partSphere.Center.Sub(sphere.Center).Len()+partSphere.Radius)))
+	}
+	return sphere
+}
 
-			count, err = fmt.Sscanf(parts[1], "%f %f %f", &tmpVec3[0], &tmpVec3[1], &tmpVec3[2])
-			if err != nil || count != 3 {
-				return fmt.Errorf("Malformed OBJ file '%v'", line)
-			}
+// WorldBounds returns Bounds after the model's Transform
+func (model *Model) WorldBounds() AABB {
+	return model.Bounds().Transform(model.Transform)
+}
 
-			allVerts = append(allVerts, tmpVec3)
+// WorldBoundingSphere returns BoundingSphere after the model's Transform
+func (model *Model) WorldBoundingSphere() BoundingSphere {
+	return model.BoundingSphere().Transform(model.Transform)
+}
 
-		case "vn":
+// OrientedBounds returns Bounds rotated and scaled with the model, which is tighter than
+// WorldBounds for rotated models
+func (model *Model) OrientedBounds() OBB {
+	return NewOBB(model.Bounds(), model.Transform)
+}
 
-			count, err = fmt.Sscanf(parts[1], "%f %f %f", &tmpVec3[0], &tmpVec3[1], &tmpVec3[2])
-			if err != nil || count != 3 {
-				return fmt.Errorf("Malformed OBJ file '%v'", line)
-			}
+func (model *Model) Render(shader *Shader) {
+	model.render(shader, nil, 0)
+}
 
-			allNorms = append(allNorms, tmpVec3)
+// RenderCulled draws the parts and groups of the model that are inside frustum, which is in
+// world space, such as from Camera.Frustum
+func (model *Model) RenderCulled(shader *Shader, frustum Frustum) {
+	model.render(shader, &frustum, 0)
+}
 
-		case "vt":
+// RenderInstanced draws a copy of the model for each transform, with one draw call for each
+// group. Each transform is applied after the parts' and before _Model. colors is optional, and
+// multiplies the vertex colors of each copy. Copies aren't culled.
+func (model *Model) RenderInstanced(shader *Shader, transforms []mgl32.Mat4, colors []mgl32.Vec4) {
+	if len(transforms) == 0 {
+		return
+	}
 
-			count, err = fmt.Sscanf(parts[1], "%f %f", &tmpVec2[0], &tmpVec2[1])
-			if err != nil || count != 2 {
-				return fmt.Errorf("Malformed OBJ file '%v'", line)
-			}
+	data := make([]float32, 0, len(transforms)*instanceFloats)
+	for i, transform := range transforms {
+		data = append(data, transform[:]...)
+		if i < len(colors) {
+			data = append(data, colors[i][:]...)
+		} else {
+			data = append(data, 1, 1, 1, 1)
+		}
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, model.glInstances)
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, gl.Ptr(data), gl.STREAM_DRAW)
 
-			allTxcds = append(allTxcds, tmpVec2)
+	gl.BindVertexArray(model.glVao)
+	for i := uint32(INST_ATTRIB); i <= INST_COLR_ATTRIB; i++ {
+		gl.EnableVertexAttribArray(i)
+	}
+	model.render(shader, nil, int32(len(transforms)))
+	for i := uint32(INST_ATTRIB); i <= INST_COLR_ATTRIB; i++ {
+		gl.DisableVertexAttribArray(i)
+	}
+}
 
-		}
+// partFrustum returns the frustum in the space of a part's vertices, or nil when not culling.
+// It returns false if the part is outside of it.
+func (model *Model) partFrustum(part *ModelPart, frustum *Frustum) (*Frustum, bool) {
+	// Skinned parts can move outside of their bounds, so they're not culled
+	if frustum == nil || part.IsSkinned() {
+		return nil, true
 	}
+	local := frustum.Transform(model.Transform.Mul4(part.Transform))
+	return &local, local.IntersectsAABB(part.Bounds)
+}
 
-	if group.Name == "" {
-		group.Name = "default"
+// render draws the model, culled if frustum isn't nil, and instanced if instances isn't 0
+func (model *Model) render(shader *Shader, frustum *Frustum, instances int32) {
+	gl.BindVertexArray(model.glVao)
+
+	// Models without vertex colors are drawn white, and without tangents they get zero ones.
+	// The current values of attributes aren't part of the VAO.
+	if !model.hasColors {
+		gl.VertexAttrib4f(COLR_ATTRIB, 1, 1, 1, 1)
+	}
+	if !model.hasTangents {
+		gl.VertexAttrib4f(TANG_ATTRIB, 0, 0, 0, 0)
+	}
+	if !model.hasJoints {
+		gl.VertexAttrib4f(JOIN_ATTRIB, 0, 0, 0, 0)
+		gl.VertexAttrib4f(WGHT_ATTRIB, 0, 0, 0, 0)
 	}
 
-	start := int32(0)
-	verts := []float32{}
-	norms := []float32{}
-	txcds := []float32{}
+	// A single copy has an identity instance transform and a white instance color
+	if instances == 0 {
+		gl.VertexAttrib4f(INST_ATTRIB+0, 1, 0, 0, 0)
+		gl.VertexAttrib4f(INST_ATTRIB+1, 0, 1, 0, 0)
+		gl.VertexAttrib4f(INST_ATTRIB+2, 0, 0, 1, 0)
+		gl.VertexAttrib4f(INST_ATTRIB+3, 0, 0, 0, 1)
+		gl.VertexAttrib4f(INST_COLR_ATTRIB, 1, 1, 1, 1)
+	}
 
-	for g := range groups {
-		group := &groups[g]
-		for f := range group.Faces {
-			face := &group.Faces[f]
-			for i := 0; i < 3; i++ {
-				// Adjust for negative indices
-				if face.VertInds[i] < 0 {
-					face.VertInds[i] += len(allVerts)
-				}
-				if face.NormInds[i] < 0 {
-					face.NormInds[i] += len(allNorms)
-				}
-				if face.TxcdInds[i] < 0 {
-					face.TxcdInds[i] += len(allTxcds)
-				}
+	locs := modelUniforms{
+		Part:          shader.GetUniformLocation("_Part"),
+		Skinned:       shader.GetUniformLocation("_Skinned"),
+		JointMatrices: shader.GetUniformLocation("_JointMatrices"),
+	}
 
-				// Adjust for zero-indexing
-				face.VertInds[i] -= 1
-				face.NormInds[i] -= 1
-				face.TxcdInds[i] -= 1
-
-				// Copy data to final arrays
-				verts = append(verts,
-					allVerts[face.VertInds[i]][0],
-					allVerts[face.VertInds[i]][1],
-					allVerts[face.VertInds[i]][2],
-				)
-				if face.NormInds[i] >= 0 {
-					norms = append(norms,
-						allNorms[face.NormInds[i]][0],
-						allNorms[face.NormInds[i]][1],
-						allNorms[face.NormInds[i]][2],
-					)
-				}
-				if face.TxcdInds[i] >= 0 {
-					txcds = append(txcds,
-						allTxcds[face.TxcdInds[i]][0],
-						allTxcds[face.TxcdInds[i]][1],
-					)
-				}
-			}
+	// Draw opaque groups first, then blend transparent ones over them without writing depth
+	hasTransparent := false
+	for _, part := range model.Parts {
+		if !part.Visible {
+			continue
 		}
-
-		var newMaterial *Material
-		if mat, ok := materials[group.Material]; ok {
-			newMaterial, err = NewMaterial(
-				app,
-				mat.Ambient,
-				mat.Diffuse,
-				mat.Specular,
-				mat.Shininess,
-				mat.Dissolve,
-				mat.AmbientMap,
-				mat.DiffuseMap,
-				mat.SpecularMap,
-				mat.BumpMap,
-			)
-			if err != nil {
-				return err
+		local, inside := model.partFrustum(part, frustum)
+		if !inside {
+			continue
+		}
+		locs.bindPart(part)
+		groups := part.lodGroups(model.LOD)
+		for g := range groups {
+			group := &groups[g]
+			if local != nil && !local.IntersectsAABB(group.Bounds) {
+				continue
 			}
+			if group.Material != nil && group.Material.IsTransparent() {
+				hasTransparent = true
+				continue
+			}
+			model.renderGroup(shader, group, instances)
 		}
-		vertCount := int32(len(group.Faces) * 3 * 3)
-		model.groups = append(model.groups, modelGroup{
-			DrawMode: gl.TRIANGLES,
-			Start:    start,
-			Count:    vertCount,
-			Material: newMaterial,
-		})
-		start += vertCount
 	}
 
-	gl.GenVertexArrays(1, &model.glVao)
-	gl.BindVertexArray(model.glVao)
-	gl.GenBuffers(3, &model.glVbos[0])
+	if !hasTransparent {
+		return
+	}
 
-	gl.BindBuffer(gl.ARRAY_BUFFER, model.glVbos[0])
-	gl.BufferData(gl.ARRAY_BUFFER, len(verts)*4, gl.Ptr(verts), gl.STATIC_DRAW)
-	gl.VertexAttribPointer(VERT_ATTRIB, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-	gl.EnableVertexAttribArray(VERT_ATTRIB)
+	blend := gl.IsEnabled(gl.BLEND)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
 
-	if len(norms) == 0 {
-		gl.DeleteBuffers(1, &model.glVbos[1])
-		model.glVbos[1] = 0
-	} else {
-		gl.BindBuffer(gl.ARRAY_BUFFER, model.glVbos[1])
-		gl.BufferData(gl.ARRAY_BUFFER, len(norms)*4, gl.Ptr(norms), gl.STATIC_DRAW)
-		gl.VertexAttribPointer(NORM_ATTRIB, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-		gl.EnableVertexAttribArray(NORM_ATTRIB)
+	for _, part := range model.Parts {
+		if !part.Visible {
+			continue
+		}
+		local, inside := model.partFrustum(part, frustum)
+		if !inside {
+			continue
+		}
+		locs.bindPart(part)
+		groups := part.lodGroups(model.LOD)
+		for g := range groups {
+			group := &groups[g]
+			if local != nil && !local.IntersectsAABB(group.Bounds) {
+				continue
+			}
+			if group.Material != nil && group.Material.IsTransparent() {
+				model.renderGroup(shader, group, instances)
+			}
+		}
 	}
 
-	if len(txcds) == 0 {
-		gl.DeleteBuffers(1, &model.glVbos[2])
-		model.glVbos[2] = 0
-	} else {
-		gl.BindBuffer(gl.ARRAY_BUFFER, model.glVbos[2])
-		gl.BufferData(gl.ARRAY_BUFFER, len(txcds)*4, gl.Ptr(txcds), gl.STATIC_DRAW)
-		gl.VertexAttribPointer(TXCD_ATTRIB, 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
-		gl.EnableVertexAttribArray(TXCD_ATTRIB)
+	gl.DepthMask(true)
+	if !blend {
+		gl.Disable(gl.BLEND)
 	}
+}
 
-	return nil
+// modelUniforms are the locations of the per-part uniforms, looked up once per render
+type modelUniforms struct {
+	Part          int32
+	Skinned       int32
+	JointMatrices int32
 }
 
-func (model *Model) Render(shader *Shader) {
-	gl.BindVertexArray(model.glVao)
+// bindPart sets the part's transform, and its joint matrices if it's skinned
+func (locs *modelUniforms) bindPart(part *ModelPart) {
+	gl.UniformMatrix4fv(locs.Part, 1, false, &part.Transform[0])
+	if !part.IsSkinned() {
+		gl.Uniform1i(locs.Skinned, 0)
+		return
+	}
+	gl.Uniform1i(locs.Skinned, 1)
+	gl.UniformMatrix4fv(locs.JointMatrices, int32(len(part.joints)), false, &part.joints[0][0])
+}
 
-	for g := range model.groups {
-		group := &model.groups[g]
-		if group.Material != nil {
-			group.Material.Bind(shader)
-		}
-		gl.DrawArrays(group.DrawMode, group.Start, group.Count)
+func (model *Model) renderGroup(shader *Shader, group *modelGroup, instances int32) {
+	if group.Material != nil {
+		group.Material.Bind(shader)
+	}
+
+	drawMode := group.DrawMode
+	if shader.IsTessellated() {
+		drawMode = gl.PATCHES
+	}
+	offset := gl.PtrOffset(int(group.Start * model.indexSize))
+	if instances > 0 {
+		gl.DrawElementsInstanced(drawMode, group.Count, model.glIndexType, offset, instances)
+	} else {
+		gl.DrawElements(drawMode, group.Count, model.glIndexType, offset)
 	}
 }
@@ -0,0 +1,307 @@
+package dusk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// SaveMeshData writes a mesh with the exporter matching the file's extension
+func SaveMeshData(filename string, mesh *MeshData) error {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".obj":
+		return SaveOBJ(filename, mesh)
+	case ".gltf", ".glb":
+		return SaveGLTF(filename, mesh)
+	case MESH_CACHE_EXT:
+		file, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return WriteMeshCache(file, mesh)
+	}
+	return fmt.Errorf("Unsupported model format '%v'", filename)
+}
+
+// SaveOBJ writes mesh to a .obj file, and its materials to a .mtl file of the same name.
+// Textures embedded in the mesh are written next to them.
+func SaveOBJ(filename string, mesh *MeshData) error {
+	LogInfo("Saving mesh '%v'", filename)
+
+	dirname := filepath.Dir(filename)
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	mtllib := ""
+	if len(mesh.Materials) > 0 {
+		mtllib = base + ".mtl"
+
+		// Write embedded textures to files, once for each image
+		materials := map[string]*MaterialDef{}
+		written := map[string]string{}
+		for name, def := range mesh.Materials {
+			copied := *def
+			for _, texMap := range copied.TextureMaps() {
+				if texMap.Data == nil {
+					continue
+				}
+				imageFilename, ok := written[texMap.Filename]
+				if !ok {
+					imageFilename = filepath.Join(dirname, base+"_"+exportImageName(texMap.Filename))
+					if err := os.WriteFile(imageFilename, texMap.Data, 0644); err != nil {
+						return err
+					}
+					written[texMap.Filename] = imageFilename
+				}
+				texMap.Filename = filepath.ToSlash(imageFilename)
+				texMap.Data = nil
+			}
+			materials[name] = &copied
+		}
+
+		file, err := os.Create(filepath.Join(dirname, mtllib))
+		if err != nil {
+			return err
+		}
+		err = WriteMTL(file, materials, filepath.ToSlash(dirname))
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return WriteOBJ(file, mesh, mtllib)
+}
+
+// exportImageName turns the name of an embedded image, such as 'model.gltf#image0.png', into a
+// filename
+func exportImageName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '#' || r == ':' {
+			return '_'
+		}
+		return r
+	}, path.Base(name))
+}
+
+// WriteOBJ writes mesh as a Wavefront .obj file that uses the materials in mtllib, which may be
+// empty. OBJ has no hierarchy, so node transforms are applied to the vertices.
+func WriteOBJ(w io.Writer, mesh *MeshData, mtllib string) error {
+	out := bufio.NewWriter(w)
+	format := mesh.VertexFormat()
+	positions, normals := mesh.bakeNodeTransforms()
+
+	fmt.Fprintf(out, "# Exported by Dusk\n")
+	if mtllib != "" {
+		fmt.Fprintf(out, "mtllib %v\n", mtllib)
+	}
+
+	// Vertex colors follow the position, as most tools expect
+	var buf []byte
+	for i, position := range positions {
+		buf = appendObjFloats(append(buf[:0], 'v'), position[:]...)
+		if format&VERTEX_COLORS != 0 {
+			buf = appendObjFloats(buf, mesh.Colors[i][:3]...)
+		}
+		out.Write(append(buf, '\n'))
+	}
+	if format&VERTEX_TEXCOORDS != 0 {
+		for _, txcd := range mesh.TexCoords {
+			out.Write(append(appendObjFloats(append(buf[:0], "vt"...), txcd[:]...), '\n'))
+		}
+	}
+	if format&VERTEX_NORMALS != 0 {
+		for _, normal := range normals {
+			out.Write(append(appendObjFloats(append(buf[:0], "vn"...), normal[:]...), '\n'))
+		}
+	}
+
+	// Vertices share one index for all of their attributes
+	corner := func(buf []byte, index uint32) []byte {
+		n := strconv.FormatUint(uint64(index)+1, 10)
+		buf = append(append(buf, ' '), n...)
+		switch {
+		case format&VERTEX_TEXCOORDS != 0 && format&VERTEX_NORMALS != 0:
+			buf = append(append(append(append(buf, '/'), n...), '/'), n...)
+		case format&VERTEX_TEXCOORDS != 0:
+			buf = append(append(buf, '/'), n...)
+		case format&VERTEX_NORMALS != 0:
+			buf = append(append(buf, "//"...), n...)
+		}
+		return buf
+	}
+
+	smooth := int64(-1)
+	for _, sub := range mesh.Submeshes {
+		fmt.Fprintf(out, "g %v\n", sub.Name)
+		if sub.Material != "" {
+			fmt.Fprintf(out, "usemtl %v\n", sub.Material)
+		}
+		for t := sub.Start / 3; t < (sub.Start+sub.Count)/3; t++ {
+			if len(mesh.SmoothingGroups) > 0 && int64(mesh.SmoothingGroups[t]) != smooth {
+				smooth = int64(mesh.SmoothingGroups[t])
+				if smooth == 0 {
+					fmt.Fprintf(out, "s off\n")
+				} else {
+					fmt.Fprintf(out, "s %v\n", smooth)
+				}
+			}
+			buf = append(buf[:0], 'f')
+			for i := 0; i < 3; i++ {
+				buf = corner(buf, mesh.Indices[t*3+i])
+			}
+			out.Write(append(buf, '\n'))
+		}
+	}
+
+	return out.Flush()
+}
+
+func appendObjFloats(buf []byte, values ...float32) []byte {
+	for _, v := range values {
+		buf = strconv.AppendFloat(append(buf, ' '), float64(v), 'g', -1, 32)
+	}
+	return buf
+}
+
+// WriteMTL writes materials as a .mtl file, with texture paths relative to dirname
+func WriteMTL(w io.Writer, materials map[string]*MaterialDef, dirname string) error {
+	out := bufio.NewWriter(w)
+
+	names := make([]string, 0, len(materials))
+	for name := range materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	color := func(keyword string, c mgl32.Vec3) {
+		fmt.Fprintf(out, "%v%s\n", keyword, appendObjFloats(nil, c[:]...))
+	}
+
+	fmt.Fprintf(out, "# Exported by Dusk\n")
+	for _, name := range names {
+		def := materials[name]
+
+		fmt.Fprintf(out, "\nnewmtl %v\n", name)
+		color("Ka", def.Ambient)
+		color("Kd", def.Diffuse)
+		color("Ks", def.Specular)
+		if def.Emissive != (mgl32.Vec3{}) {
+			color("Ke", def.Emissive)
+		}
+		if def.TransmissionFilter != (mgl32.Vec3{1, 1, 1}) {
+			color("Tf", def.TransmissionFilter)
+		}
+		fmt.Fprintf(out, "Ns %v\n", def.Shininess)
+		fmt.Fprintf(out, "Ni %v\n", def.IOR)
+		fmt.Fprintf(out, "d %v\n", def.Dissolve)
+		fmt.Fprintf(out, "illum %v\n", def.Illum)
+
+		maps := []struct {
+			Keyword string
+			Map     *TextureMap
+		}{
+			{"map_Ka", &def.AmbientMap},
+			{"map_Kd", &def.DiffuseMap},
+			{"map_Ks", &def.SpecularMap},
+			{"map_Ns", &def.ShininessMap},
+			{"map_Ke", &def.EmissiveMap},
+			{"map_d", &def.AlphaMap},
+			{"map_Bump", &def.BumpMap},
+			{"norm", &def.NormalMap},
+			{"disp", &def.DisplacementMap},
+			{"decal", &def.DecalMap},
+			{"refl", &def.ReflectionMap},
+		}
+		for _, m := range maps {
+			if m.Map.Filename == "" {
+				continue
+			}
+			fmt.Fprintf(out, "%v%v %v\n", m.Keyword, mtlMapOptions(m.Map), exportPath(dirname, m.Map.Filename))
+		}
+	}
+
+	return out.Flush()
+}
+
+// mtlMapOptions returns the options parseTextureMap reads, each with a leading space
+func mtlMapOptions(texMap *TextureMap) string {
+	options := ""
+	if texMap.Clamp {
+		options += " -clamp on"
+	}
+	if texMap.BumpMultiplier != 0 {
+		options += fmt.Sprintf(" -bm %v", texMap.BumpMultiplier)
+	}
+	if texMap.Scale != (mgl32.Vec3{}) && texMap.Scale != (mgl32.Vec3{1, 1, 1}) {
+		options += " -s" + string(appendObjFloats(nil, texMap.Scale[:]...))
+	}
+	if texMap.Offset != (mgl32.Vec3{}) {
+		options += " -o" + string(appendObjFloats(nil, texMap.Offset[:]...))
+	}
+	if texMap.Channel != "" {
+		options += " -imfchan " + texMap.Channel
+	}
+	if texMap.Type != "" {
+		options += " -type " + texMap.Type
+	}
+	return options
+}
+
+// exportPath returns filename relative to dirname when it can be, as asset paths are written
+// relative to the file that refers to them
+func exportPath(dirname, filename string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(dirname), filepath.FromSlash(filename))
+	if err != nil {
+		return filename
+	}
+	return filepath.ToSlash(rel)
+}
+
+// bakeNodeTransforms returns the positions and normals with the transform of the node each
+// submesh is placed by, for formats without a hierarchy. Skinned nodes are left in place.
+func (mesh *MeshData) bakeNodeTransforms() ([]mgl32.Vec3, []mgl32.Vec3) {
+	if len(mesh.Nodes) == 0 {
+		return mesh.Positions, mesh.Normals
+	}
+
+	positions := append([]mgl32.Vec3{}, mesh.Positions...)
+	normals := append([]mgl32.Vec3{}, mesh.Normals...)
+	done := make([]bool, len(positions))
+
+	for _, sub := range mesh.Submeshes {
+		if sub.Node < 0 || sub.Node >= len(mesh.Nodes) || mesh.Nodes[sub.Node].Skin >= 0 {
+			continue
+		}
+		transform := mesh.NodeWorldTransform(sub.Node)
+		normalMatrix := transform.Mat3().Inv().Transpose()
+
+		for _, index := range mesh.Indices[sub.Start : sub.Start+sub.Count] {
+			if done[index] {
+				continue
+			}
+			done[index] = true
+			positions[index] = mgl32.TransformCoordinate(mesh.Positions[index], transform)
+			if int(index) < len(normals) {
+				if normal := normalMatrix.Mul3x1(mesh.Normals[index]); normal.Len() > 0 {
+					normals[index] = normal.Normalize()
+				}
+			}
+		}
+	}
+
+	return positions, normals
+}
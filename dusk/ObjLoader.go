@@ -0,0 +1,464 @@
+package dusk
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// LoadOBJ reads a Wavefront .obj file and the .mtl files it references into MeshData. Only
+// app.AssetFunction is used, so no GL context is needed.
+func LoadOBJ(app *App, filename string) (*MeshData, error) {
+	LogLoad("Mesh '%v'", filename)
+
+	// Holds a single triangle, indices are zero-based and -1 if missing
+	type Face struct {
+		VertInds [3]int
+		NormInds [3]int
+		TxcdInds [3]int
+		Smooth   uint32
+	}
+
+	// Holds a run of faces in one group or object that share a material
+	type Group struct {
+		Name     string
+		Material string
+		Faces    []Face
+	}
+
+	// Open the .obj file
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the directory name for loading .mtl files
+	dirname := path.Dir(filename)
+
+	mesh := NewMeshData()
+
+	// Create a list of groups, a new one is started by 'g', 'o' and 'usemtl' statements
+	groups := []Group{{}}
+
+	// Smoothing groups are only recorded if the file uses them
+	smooth := uint32(0)
+	hasSmooth := false
+
+	// Create the list of all Vertices, Normals, and Texture Coordinates
+	allVerts := []mgl32.Vec3{}
+	allNorms := []mgl32.Vec3{}
+	allTxcds := []mgl32.Vec2{}
+
+	// Reused between faces to avoid allocating
+	polyVerts := []int{}
+	polyNorms := []int{}
+	polyTxcds := []int{}
+	polyPoints := []mgl32.Vec3{}
+	var tris triangulator
+
+	scanner := newObjScanner(data)
+	for scanner.Scan() {
+		group := &groups[len(groups)-1]
+
+		malformed := func(err error) error {
+			return fmt.Errorf("Malformed OBJ file '%v' line %v: %v", filename, scanner.LineNum(), err)
+		}
+
+		// Comparing string(keyword) in a switch doesn't allocate
+		keyword := scanner.Token()
+		switch string(keyword) {
+		case "usemtl":
+
+			material := scanner.Rest()
+			if len(group.Faces) == 0 {
+				group.Material = material
+			} else {
+				groups = append(groups, Group{
+					Name:     group.Name,
+					Material: material,
+				})
+			}
+
+		case "s":
+
+			hasSmooth = true
+			token := scanner.Token()
+			if string(token) == "off" {
+				smooth = 0
+			} else {
+				index, ok := parseObjInt(token)
+				if !ok || index < 0 {
+					return nil, malformed(fmt.Errorf("invalid smoothing group '%s'", token))
+				}
+				smooth = uint32(index)
+			}
+
+		case "mtllib":
+
+			// Several libraries can be given on one line
+			for lib := scanner.Token(); lib != nil; lib = scanner.Token() {
+				err := loadMTL(app, path.Join(dirname, string(lib)), mesh.Materials)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+		case "o", "g":
+
+			name := scanner.Rest()
+			if group.Name == "" && len(group.Faces) == 0 {
+				group.Name = name
+			} else {
+				groups = append(groups, Group{
+					Name:     name,
+					Material: group.Material,
+				})
+			}
+
+		case "f":
+
+			polyVerts = polyVerts[:0]
+			polyNorms = polyNorms[:0]
+			polyTxcds = polyTxcds[:0]
+			polyPoints = polyPoints[:0]
+
+			for {
+				v, vt, vn, ok, err := scanner.FaceVertex(len(allVerts), len(allTxcds), len(allNorms))
+				if err != nil {
+					return nil, malformed(err)
+				}
+				if !ok {
+					break
+				}
+				polyVerts = append(polyVerts, v)
+				polyTxcds = append(polyTxcds, vt)
+				polyNorms = append(polyNorms, vn)
+				polyPoints = append(polyPoints, allVerts[v])
+			}
+
+			if len(polyVerts) < 3 {
+				return nil, malformed(fmt.Errorf("face has %v vertices", len(polyVerts)))
+			}
+
+			for _, tri := range tris.Triangulate(polyPoints) {
+				face := Face{Smooth: smooth}
+				for i, corner := range tri {
+					face.VertInds[i] = polyVerts[corner]
+					face.TxcdInds[i] = polyTxcds[corner]
+					face.NormInds[i] = polyNorms[corner]
+				}
+				group.Faces = append(group.Faces, face)
+			}
+
+		case "v":
+
+			var vec mgl32.Vec3
+			if err := scanner.Floats(vec[:], 3); err != nil {
+				return nil, malformed(err)
+			}
+			allVerts = append(allVerts, vec)
+
+		case "vn":
+
+			var vec mgl32.Vec3
+			if err := scanner.Floats(vec[:], 3); err != nil {
+				return nil, malformed(err)
+			}
+			allNorms = append(allNorms, vec)
+
+		case "vt":
+
+			// The v coordinate is optional, and a w coordinate is ignored
+			var vec mgl32.Vec2
+			if err := scanner.Floats(vec[:], 1); err != nil {
+				return nil, malformed(err)
+			}
+			allTxcds = append(allTxcds, vec)
+
+		}
+	}
+
+	for g := range groups {
+		if groups[g].Name == "" {
+			groups[g].Name = "default"
+		}
+	}
+
+	// Vertices are shared between faces with the same (v, vt, vn) tuple
+	type vertKey struct {
+		Vert, Txcd, Norm int
+	}
+
+	vertIndices := map[vertKey]uint32{}
+	keys := []vertKey{}
+	hasNorms := len(allNorms) > 0
+	hasTxcds := len(allTxcds) > 0
+
+	for g := range groups {
+		group := &groups[g]
+		if len(group.Faces) == 0 {
+			continue
+		}
+
+		start := len(mesh.Indices)
+		for f := range group.Faces {
+			face := &group.Faces[f]
+			if hasSmooth {
+				mesh.SmoothingGroups = append(mesh.SmoothingGroups, face.Smooth)
+			}
+			for i := 0; i < 3; i++ {
+				key := vertKey{face.VertInds[i], face.TxcdInds[i], face.NormInds[i]}
+				index, ok := vertIndices[key]
+				if !ok {
+					index = uint32(len(keys))
+					vertIndices[key] = index
+					keys = append(keys, key)
+				}
+				mesh.Indices = append(mesh.Indices, index)
+
+				// Normals and texture coordinates must cover every vertex, or they are dropped
+				hasNorms = hasNorms && key.Norm >= 0
+				hasTxcds = hasTxcds && key.Txcd >= 0
+			}
+		}
+
+		mesh.Submeshes = append(mesh.Submeshes, Submesh{
+			Name:     group.Name,
+			Start:    start,
+			Count:    len(mesh.Indices) - start,
+			Material: group.Material,
+		})
+	}
+
+	mesh.Positions = make([]mgl32.Vec3, len(keys))
+	if hasNorms {
+		mesh.Normals = make([]mgl32.Vec3, len(keys))
+	}
+	if hasTxcds {
+		mesh.TexCoords = make([]mgl32.Vec2, len(keys))
+	}
+	for i, key := range keys {
+		mesh.Positions[i] = allVerts[key.Vert]
+		if hasNorms {
+			mesh.Normals[i] = allNorms[key.Norm]
+		}
+		if hasTxcds {
+			mesh.TexCoords[i] = allTxcds[key.Txcd]
+		}
+	}
+
+	if !hasNorms {
+		mesh.GenerateNormalsWithCrease(app.CreaseAngle)
+	}
+	mesh.GenerateTangents()
+
+	mesh.OptimizeVertexCache()
+
+	return mesh, nil
+}
+
+// loadMTL reads the materials from a .mtl file into materials
+func loadMTL(app *App, filename string, materials map[string]*MaterialDef) error {
+	LogLoad("Material '%v'", filename)
+
+	dirname := path.Dir(filename)
+
+	// Open the .mtl file
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return err
+	}
+
+	var curMat *MaterialDef
+
+	scanner := newObjScanner(data)
+	for scanner.Scan() {
+		keyword := scanner.Token()
+		if string(keyword) == "newmtl" {
+			curMat = NewMaterialDef()
+			materials[scanner.Rest()] = curMat
+			continue
+		}
+
+		// Ignore statements before the first material
+		if curMat == nil {
+			continue
+		}
+
+		var err error
+		var v [1]float32
+		switch string(keyword) {
+		case "Ka":
+			err = parseMtlColor(scanner, &curMat.Ambient)
+		case "Kd":
+			err = parseMtlColor(scanner, &curMat.Diffuse)
+		case "Ks":
+			err = parseMtlColor(scanner, &curMat.Specular)
+		case "Ke":
+			err = parseMtlColor(scanner, &curMat.Emissive)
+		case "Tf":
+			err = parseMtlColor(scanner, &curMat.TransmissionFilter)
+		case "Ns":
+			err = scanner.Floats(v[:], 1)
+			curMat.Shininess = v[0]
+		case "Ni":
+			err = scanner.Floats(v[:], 1)
+			curMat.IOR = v[0]
+		case "d":
+			// The -halo option changes how dissolve is applied, which is not supported
+			if string(scanner.Peek()) == "-halo" {
+				scanner.Token()
+			}
+			err = scanner.Floats(v[:], 1)
+			curMat.Dissolve = v[0]
+		case "Tr":
+			err = scanner.Floats(v[:], 1)
+			curMat.Dissolve = 1 - v[0]
+		case "illum":
+			err = scanner.Floats(v[:], 1)
+			curMat.Illum = int(v[0])
+		case "map_Ka":
+			curMat.AmbientMap = parseTextureMap(dirname, scanner.Rest())
+		case "map_Kd":
+			curMat.DiffuseMap = parseTextureMap(dirname, scanner.Rest())
+		case "map_Ks":
+			curMat.SpecularMap = parseTextureMap(dirname, scanner.Rest())
+		case "map_Ns":
+			curMat.ShininessMap = parseTextureMap(dirname, scanner.Rest())
+		case "map_Ke":
+			curMat.EmissiveMap = parseTextureMap(dirname, scanner.Rest())
+		case "map_d":
+			curMat.AlphaMap = parseTextureMap(dirname, scanner.Rest())
+		case "map_bump", "map_Bump", "bump":
+			curMat.BumpMap = parseTextureMap(dirname, scanner.Rest())
+		case "norm", "map_Kn":
+			curMat.NormalMap = parseTextureMap(dirname, scanner.Rest())
+		case "disp":
+			curMat.DisplacementMap = parseTextureMap(dirname, scanner.Rest())
+		case "decal":
+			curMat.DecalMap = parseTextureMap(dirname, scanner.Rest())
+		case "refl":
+			curMat.ReflectionMap = parseTextureMap(dirname, scanner.Rest())
+		}
+		if err != nil {
+			return fmt.Errorf("Malformed MTL file '%v' line %v: %v", filename, scanner.LineNum(), err)
+		}
+	}
+
+	return nil
+}
+
+// parseMtlColor parses 'r [g b]', 'xyz x [y z]' or 'spectral file.rfl [factor]'. Missing
+// components repeat the first one. Spectral curves are not supported and leave out unchanged.
+func parseMtlColor(scanner *objScanner, out *mgl32.Vec3) error {
+	switch string(scanner.Peek()) {
+	case "spectral":
+		return nil
+	case "xyz":
+		scanner.Token()
+	}
+
+	var color [3]float32
+	color[1], color[2] = -1, -1
+	if err := scanner.Floats(color[:], 1); err != nil {
+		return err
+	}
+	if color[1] < 0 {
+		color[1] = color[0]
+	}
+	if color[2] < 0 {
+		color[2] = color[1]
+	}
+	*out = color
+	return nil
+}
+
+// Number of arguments taken by each MTL texture map option, and whether they are numbers. Numbers
+// stop at the first word that isn't one, as -o -s and -t take up to 3 and the filename follows.
+var mtlMapOptionArgs = map[string]struct {
+	Args    int
+	Numeric bool
+}{
+	"-blendu":  {1, false},
+	"-blendv":  {1, false},
+	"-bm":      {1, true},
+	"-boost":   {1, true},
+	"-cc":      {1, false},
+	"-clamp":   {1, false},
+	"-imfchan": {1, false},
+	"-mm":      {2, true},
+	"-o":       {3, true},
+	"-s":       {3, true},
+	"-t":       {3, true},
+	"-texres":  {1, true},
+	"-type":    {1, false},
+}
+
+// parseTextureMap parses the arguments of a map statement, e.g. '-clamp on -s 2 2 texture.png'.
+// Options that only affect how a renderer blends or filters the map are skipped.
+func parseTextureMap(dirname string, args string) TextureMap {
+	texMap := TextureMap{
+		Scale: mgl32.Vec3{1, 1, 1},
+	}
+
+	fields := strings.Fields(args)
+	i := 0
+	for i < len(fields) {
+		option := fields[i]
+		opt, ok := mtlMapOptionArgs[option]
+		if !ok {
+			break
+		}
+		i++
+
+		values := []float32{}
+		words := []string{}
+		for n := 0; n < opt.Args && i < len(fields); n++ {
+			if opt.Numeric {
+				v, err := strconv.ParseFloat(fields[i], 32)
+				if err != nil {
+					break
+				}
+				values = append(values, float32(v))
+			}
+			words = append(words, fields[i])
+			i++
+		}
+
+		switch option {
+		case "-clamp":
+			texMap.Clamp = len(words) > 0 && words[0] == "on"
+		case "-bm":
+			if len(values) > 0 {
+				texMap.BumpMultiplier = values[0]
+			}
+		case "-imfchan":
+			if len(words) > 0 {
+				texMap.Channel = words[0]
+			}
+		case "-type":
+			if len(words) > 0 {
+				texMap.Type = words[0]
+			}
+		case "-s", "-o":
+			vec := &texMap.Offset
+			if option == "-s" {
+				vec = &texMap.Scale
+			}
+			// Missing v and w components default to 1 for -s and 0 for -o
+			for n, v := range values {
+				vec[n] = v
+			}
+		}
+	}
+
+	// Whatever remains is the filename, which may contain spaces
+	if i < len(fields) {
+		texMap.Filename = path.Join(dirname, strings.Join(fields[i:], " "))
+	}
+
+	return texMap
+}
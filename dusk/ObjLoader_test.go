@@ -0,0 +1,409 @@
+package dusk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// newMemoryApp returns an App that reads assets from memory instead of the filesystem
+func newMemoryApp(files map[string][]byte) *App {
+	return &App{
+		AssetFunction: func(filename string) ([]byte, error) {
+			data, ok := files[filename]
+			if !ok {
+				return nil, fmt.Errorf("File not found '%v'", filename)
+			}
+			return data, nil
+		},
+	}
+}
+
+// readTestAssets reads files from the examples into memory, keyed by their path relative to dir
+func readTestAssets(tb testing.TB, dir string, filenames ...string) map[string][]byte {
+	files := map[string][]byte{}
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(filename)))
+		if err != nil {
+			tb.Skipf("Missing test asset: %v", err)
+		}
+		files[filename] = data
+	}
+	return files
+}
+
+// quietLogs discards what the loaders print while a benchmark runs
+func quietLogs(tb testing.TB) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	stdout := os.Stdout
+	os.Stdout = null
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		null.Close()
+	})
+}
+
+const globeAssets = "../examples/Textured/assets"
+
+// scanOBJ tokenizes the vertex and face statements of an OBJ file the way LoadOBJ does, without
+// building a mesh
+func scanOBJ(data []byte) (verts, txcds, norms, faceVerts int, err error) {
+	var v [3]float32
+	scanner := newObjScanner(data)
+	for scanner.Scan() {
+		switch string(scanner.Token()) {
+		case "v":
+			err = scanner.Floats(v[:], 3)
+			verts++
+		case "vt":
+			err = scanner.Floats(v[:2], 2)
+			txcds++
+		case "vn":
+			err = scanner.Floats(v[:], 3)
+			norms++
+		case "f":
+			for {
+				_, _, _, ok, ferr := scanner.FaceVertex(verts, txcds, norms)
+				if ferr != nil || !ok {
+					err = ferr
+					break
+				}
+				faceVerts++
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// scanOBJSscanf is the line reader that LoadFromFile used before objScanner, which splits each
+// line into strings and parses them with fmt.Sscanf. Only the triangle formats it supported are
+// handled.
+func scanOBJSscanf(data []byte) (verts, txcds, norms, faceVerts int, err error) {
+	var vec3 mgl32.Vec3
+	var vec2 mgl32.Vec2
+	var inds [9]int
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	tmp, _, rerr := reader.ReadLine()
+	for ; rerr == nil; tmp, _, rerr = reader.ReadLine() {
+		line := string(tmp)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) < 2 {
+			continue
+		}
+
+		count := 0
+		switch parts[0] {
+		case "v":
+			count, err = fmt.Sscanf(parts[1], "%f %f %f", &vec3[0], &vec3[1], &vec3[2])
+			verts++
+		case "vt":
+			count, err = fmt.Sscanf(parts[1], "%f %f", &vec2[0], &vec2[1])
+			txcds++
+		case "vn":
+			count, err = fmt.Sscanf(parts[1], "%f %f %f", &vec3[0], &vec3[1], &vec3[2])
+			norms++
+		case "f":
+			switch {
+			case strings.Contains(parts[1], "//"):
+				count, err = fmt.Sscanf(parts[1], "%d//%d %d//%d %d//%d",
+					&inds[0], &inds[1], &inds[2], &inds[3], &inds[4], &inds[5])
+			case strings.Count(parts[1], "/") == 3:
+				count, err = fmt.Sscanf(parts[1], "%d/%d %d/%d %d/%d",
+					&inds[0], &inds[1], &inds[2], &inds[3], &inds[4], &inds[5])
+			case strings.Count(parts[1], "/") == 6:
+				count, err = fmt.Sscanf(parts[1], "%d/%d/%d %d/%d/%d %d/%d/%d",
+					&inds[0], &inds[1], &inds[2], &inds[3], &inds[4], &inds[5], &inds[6], &inds[7], &inds[8])
+			default:
+				count, err = fmt.Sscanf(parts[1], "%d %d %d", &inds[0], &inds[1], &inds[2])
+			}
+			faceVerts += 3
+		default:
+			continue
+		}
+		if err != nil || count == 0 {
+			return verts, txcds, norms, faceVerts, fmt.Errorf("Malformed OBJ line '%v'", line)
+		}
+	}
+	return verts, txcds, norms, faceVerts, nil
+}
+
+func TestScanOBJMatchesSscanf(t *testing.T) {
+	data := readTestAssets(t, globeAssets, "globe/globe.obj")["globe/globe.obj"]
+
+	v1, vt1, vn1, f1, err := scanOBJ(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, vt2, vn2, f2, err := scanOBJSscanf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1 != v2 || vt1 != vt2 || vn1 != vn2 || f1 != f2 {
+		t.Errorf("Scanner read %v/%v/%v/%v, Sscanf read %v/%v/%v/%v", v1, vt1, vn1, f1, v2, vt2, vn2, f2)
+	}
+}
+
+func TestScanOBJAllocations(t *testing.T) {
+	data := readTestAssets(t, globeAssets, "globe/globe.obj")["globe/globe.obj"]
+
+	allocs := testing.AllocsPerRun(10, func() {
+		scanOBJ(data)
+	})
+	if allocs != 0 {
+		t.Errorf("Scanning the globe allocated %v times, expected 0", allocs)
+	}
+}
+
+// BenchmarkLoadOBJ loads the globe, its materials and all, from memory
+func BenchmarkLoadOBJ(b *testing.B) {
+	app := newMemoryApp(readTestAssets(b, globeAssets, "globe/globe.obj", "globe/globe.mtl"))
+	quietLogs(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadOBJ(app, "globe/globe.obj"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkScanOBJ only tokenizes the globe's statements, which shouldn't allocate
+func BenchmarkScanOBJ(b *testing.B) {
+	data := readTestAssets(b, globeAssets, "globe/globe.obj")["globe/globe.obj"]
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, _, err := scanOBJ(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkScanOBJSscanf is the baseline for BenchmarkScanOBJ, with the previous Sscanf parser
+func BenchmarkScanOBJSscanf(b *testing.B) {
+	data := readTestAssets(b, globeAssets, "globe/globe.obj")["globe/globe.obj"]
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, _, err := scanOBJSscanf(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLoadOBJ(t *testing.T) {
+	const square = "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\n"
+	const fold = "v 0 0 0\nv 1 0 0\nv 0 1 0\nv 0 0 1\n"
+	const mtl = "newmtl red\nKd 1 0 0\nnewmtl blue\nKd 0 0 1\n"
+
+	tests := []struct {
+		Name      string
+		OBJ       string
+		Vertices  int
+		Triangles int
+		Area      float32
+		Normals   bool
+		TexCoords bool
+		Submeshes []Submesh
+		Groups    []uint32
+		Error     string
+	}{
+		{
+			Name:      "Triangle",
+			OBJ:       "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n",
+			Vertices:  3,
+			Triangles: 1,
+			Area:      0.5,
+			Normals:   true,
+		},
+		{
+			Name:      "Quad",
+			OBJ:       square + "f 1 2 3 4\n",
+			Vertices:  4,
+			Triangles: 2,
+			Area:      1,
+			Normals:   true,
+		},
+		{
+			Name:      "Concave n-gon with tabs and repeated spaces",
+			OBJ:       "v 0 0 0\nv 2 0 0\nv 2 1 0\nv 1 1 0\nv 1 2 0\nv 0 2 0\n" + "f\t1  2 3\t4 5   6\n",
+			Vertices:  6,
+			Triangles: 4,
+			Area:      3,
+			Normals:   true,
+		},
+		{
+			Name:      "Negative indices",
+			OBJ:       square + "vt 0 0\nvt 1 0\nvt 1 1\nvt 0 1\n" + "f -4/-4 -3/-3 -2/-2 -1/-1\n",
+			Vertices:  4,
+			Triangles: 2,
+			Area:      1,
+			Normals:   true,
+			TexCoords: true,
+		},
+		{
+			Name:      "Mixed face formats",
+			OBJ:       square + "vt 0 0\nvn 0 0 1\n" + "f 1//1 2//1 3//1\nf 1/1/1 3/1/1 4/1/1\n",
+			Vertices:  6,
+			Triangles: 2,
+			Area:      1,
+			Normals:   true,
+		},
+		{
+			Name:      "Material switches split submeshes",
+			OBJ:       "mtllib test.mtl\n" + square + "g a\nusemtl red\nf 1 2 3\nusemtl blue\nf 1 3 4\ng b\nf 1 2 4\n",
+			Vertices:  4,
+			Triangles: 3,
+			Normals:   true,
+			Submeshes: []Submesh{
+				{Name: "a", Start: 0, Count: 3, Material: "red"},
+				{Name: "a", Start: 3, Count: 3, Material: "blue"},
+				{Name: "b", Start: 6, Count: 3, Material: "blue"},
+			},
+		},
+		{
+			Name:      "Shared smoothing group",
+			OBJ:       fold + "s 1\nf 1 2 3\nf 1 4 2\n",
+			Vertices:  4,
+			Triangles: 2,
+			Normals:   true,
+			Groups:    []uint32{1, 1},
+		},
+		{
+			Name:      "Separate smoothing groups",
+			OBJ:       fold + "s 1\nf 1 2 3\ns 2\nf 1 4 2\n",
+			Vertices:  6,
+			Triangles: 2,
+			Normals:   true,
+			Groups:    []uint32{1, 2},
+		},
+		{
+			Name:      "Smoothing off",
+			OBJ:       fold + "s off\nf 1 2 3\nf 1 4 2\n",
+			Vertices:  6,
+			Triangles: 2,
+			Normals:   true,
+			Groups:    []uint32{0, 0},
+		},
+		{Name: "Two vertex face", OBJ: square + "f 1 2\n", Error: "face has 2 vertices"},
+		{Name: "Index out of range", OBJ: square + "f 1 2 5\n", Error: "out of range"},
+		{Name: "Invalid face vertex", OBJ: square + "f 1 2 x\n", Error: "invalid face vertex"},
+		{Name: "Invalid smoothing group", OBJ: square + "s x\n", Error: "invalid smoothing group"},
+		{Name: "Missing material library", OBJ: "mtllib missing.mtl\n", Error: "File not found"},
+	}
+
+	for _, test := range tests {
+		app := newMemoryApp(map[string][]byte{
+			"test.obj": []byte(test.OBJ),
+			"test.mtl": []byte(mtl),
+		})
+		mesh, err := LoadOBJ(app, "test.obj")
+		if test.Error != "" {
+			if err == nil || !strings.Contains(err.Error(), test.Error) {
+				t.Errorf("%v: expected error containing '%v', got %v", test.Name, test.Error, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.Name, err)
+			continue
+		}
+
+		if mesh.VertexCount() != test.Vertices || mesh.TriangleCount() != test.Triangles {
+			t.Errorf("%v: %v vertices and %v triangles, expected %v and %v",
+				test.Name, mesh.VertexCount(), mesh.TriangleCount(), test.Vertices, test.Triangles)
+		}
+		if (len(mesh.Normals) > 0) != test.Normals || (len(mesh.TexCoords) > 0) != test.TexCoords {
+			t.Errorf("%v: has normals %v and texture coordinates %v, expected %v and %v",
+				test.Name, len(mesh.Normals) > 0, len(mesh.TexCoords) > 0, test.Normals, test.TexCoords)
+		}
+
+		// Triangulating a polygon has to cover exactly its area
+		if test.Area > 0 {
+			area := float32(0)
+			for i := 0; i+2 < len(mesh.Indices); i += 3 {
+				a := mesh.Positions[mesh.Indices[i]]
+				b := mesh.Positions[mesh.Indices[i+1]]
+				c := mesh.Positions[mesh.Indices[i+2]]
+				area += b.Sub(a).Cross(c.Sub(a)).Len() / 2
+			}
+			if mgl32.Abs(area-test.Area) > 1e-5 {
+				t.Errorf("%v: triangles cover an area of %v, expected %v", test.Name, area, test.Area)
+			}
+		}
+
+		if test.Submeshes != nil {
+			if len(mesh.Submeshes) != len(test.Submeshes) {
+				t.Errorf("%v: %v submeshes, expected %v", test.Name, len(mesh.Submeshes), len(test.Submeshes))
+			} else {
+				for i, sub := range mesh.Submeshes {
+					if sub != test.Submeshes[i] {
+						t.Errorf("%v: submesh %v is %+v, expected %+v", test.Name, i, sub, test.Submeshes[i])
+					}
+				}
+			}
+			for _, sub := range test.Submeshes {
+				if _, ok := mesh.Materials[sub.Material]; !ok {
+					t.Errorf("%v: material '%v' wasn't loaded", test.Name, sub.Material)
+				}
+			}
+		} else if len(mesh.Submeshes) != 1 || mesh.Submeshes[0].Name != "default" {
+			t.Errorf("%v: expected a single default submesh, got %+v", test.Name, mesh.Submeshes)
+		}
+
+		// Triangles are reordered for the vertex cache, so only the set of groups is compared
+		groups := append([]uint32{}, mesh.SmoothingGroups...)
+		sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+		if fmt.Sprint(groups) != fmt.Sprint(append([]uint32{}, test.Groups...)) {
+			t.Errorf("%v: smoothing groups %v, expected %v", test.Name, groups, test.Groups)
+		}
+	}
+}
+
+func TestParseTextureMap(t *testing.T) {
+	tests := []struct {
+		Args     string
+		Expected TextureMap
+	}{
+		{"tex.png", TextureMap{Filename: "dir/tex.png", Scale: mgl32.Vec3{1, 1, 1}}},
+		{"my tex.png", TextureMap{Filename: "dir/my tex.png", Scale: mgl32.Vec3{1, 1, 1}}},
+		{"-s 2 3 tex.png", TextureMap{Filename: "dir/tex.png", Scale: mgl32.Vec3{2, 3, 1}}},
+		{"-o 0.5 tex.png", TextureMap{Filename: "dir/tex.png", Scale: mgl32.Vec3{1, 1, 1}, Offset: mgl32.Vec3{0.5, 0, 0}}},
+		{"-s tex.png", TextureMap{Filename: "dir/tex.png", Scale: mgl32.Vec3{1, 1, 1}}},
+		{"-bm foo.png", TextureMap{Filename: "dir/foo.png", Scale: mgl32.Vec3{1, 1, 1}}},
+		{"-bm 0.5 foo.png", TextureMap{Filename: "dir/foo.png", Scale: mgl32.Vec3{1, 1, 1}, BumpMultiplier: 0.5}},
+		{"-clamp on -imfchan r 1.png", TextureMap{Filename: "dir/1.png", Clamp: true, Channel: "r", Scale: mgl32.Vec3{1, 1, 1}}},
+		{"-mm 0 1 -blendu off tex.png", TextureMap{Filename: "dir/tex.png", Scale: mgl32.Vec3{1, 1, 1}}},
+		{"-type sphere env.png", TextureMap{Filename: "dir/env.png", Type: "sphere", Scale: mgl32.Vec3{1, 1, 1}}},
+	}
+
+	for _, test := range tests {
+		texMap := parseTextureMap("dir", test.Args)
+		if fmt.Sprintf("%+v", texMap) != fmt.Sprintf("%+v", test.Expected) {
+			t.Errorf("parseTextureMap('%v') = %+v, expected %+v", test.Args, texMap, test.Expected)
+		}
+	}
+}
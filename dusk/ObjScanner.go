@@ -0,0 +1,296 @@
+package dusk
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// objScanner splits OBJ and MTL files into statements and tokens. Tokens are slices of the file
+// data, so scanning doesn't allocate except when joining lines continued with '\'.
+type objScanner struct {
+	data    []byte
+	pos     int
+	lineNum int
+	line    []byte
+	cursor  int
+	joined  []byte
+}
+
+func newObjScanner(data []byte) *objScanner {
+	return &objScanner{data: data}
+}
+
+// Scan advances to the next statement that isn't blank or a comment
+func (s *objScanner) Scan() bool {
+	s.joined = s.joined[:0]
+
+	for s.pos < len(s.data) {
+		line := s.nextLine()
+
+		if i := bytes.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = trimObjSpace(line)
+
+		if len(line) > 0 && line[len(line)-1] == '\\' {
+			s.joined = append(s.joined, line[:len(line)-1]...)
+			s.joined = append(s.joined, ' ')
+			continue
+		}
+		if len(s.joined) > 0 {
+			s.joined = append(s.joined, line...)
+			line = s.joined
+		}
+
+		if len(line) > 0 {
+			s.line = line
+			s.cursor = 0
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *objScanner) nextLine() []byte {
+	s.lineNum++
+	rest := s.data[s.pos:]
+	if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+		s.pos += i + 1
+		return rest[:i]
+	}
+	s.pos = len(s.data)
+	return rest
+}
+
+// LineNum returns the line number of the current statement, the last line if it was continued
+func (s *objScanner) LineNum() int {
+	return s.lineNum
+}
+
+// Token returns the next whitespace separated token of the statement, or nil at the end
+func (s *objScanner) Token() []byte {
+	line := s.line
+	i := s.cursor
+	for i < len(line) && isObjSpace(line[i]) {
+		i++
+	}
+	start := i
+	for i < len(line) && !isObjSpace(line[i]) {
+		i++
+	}
+	s.cursor = i
+	if start == i {
+		return nil
+	}
+	return line[start:i]
+}
+
+// Peek returns the next token without consuming it
+func (s *objScanner) Peek() []byte {
+	cursor := s.cursor
+	token := s.Token()
+	s.cursor = cursor
+	return token
+}
+
+// Rest returns the remainder of the statement, for names and filenames that may contain spaces
+func (s *objScanner) Rest() string {
+	rest := trimObjSpace(s.line[s.cursor:])
+	s.cursor = len(s.line)
+	return string(rest)
+}
+
+// Floats parses up to len(out) floats, requiring at least min of them. Extra values are ignored.
+func (s *objScanner) Floats(out []float32, min int) error {
+	for i := range out {
+		token := s.Token()
+		if token == nil {
+			if i < min {
+				return fmt.Errorf("expected %v values, got %v", min, i)
+			}
+			return nil
+		}
+		v, err := parseObjFloat(token)
+		if err != nil {
+			return err
+		}
+		out[i] = v
+	}
+	return nil
+}
+
+// FaceVertex parses the next 'v', 'v/vt', 'v//vn' or 'v/vt/vn' token, returning zero-based indices
+// with missing ones as -1. Negative indices are relative to the number of elements read so far.
+func (s *objScanner) FaceVertex(numVerts, numTxcds, numNorms int) (v, vt, vn int, ok bool, err error) {
+	token := s.Token()
+	if token == nil {
+		return 0, 0, 0, false, nil
+	}
+
+	counts := [3]int{numVerts, numTxcds, numNorms}
+	indices := [3]int{-1, -1, -1}
+
+	part := 0
+	i := 0
+	for {
+		start := i
+		for i < len(token) && token[i] != '/' {
+			i++
+		}
+		if part > 2 || (part == 0 && start == i) {
+			return 0, 0, 0, false, fmt.Errorf("invalid face vertex '%s'", token)
+		}
+
+		if start < i {
+			index, valid := parseObjInt(token[start:i])
+			if !valid {
+				return 0, 0, 0, false, fmt.Errorf("invalid face vertex '%s'", token)
+			}
+			if index < 0 {
+				index += counts[part]
+			} else {
+				index--
+			}
+			if index < 0 || index >= counts[part] {
+				return 0, 0, 0, false, fmt.Errorf("face vertex '%s' out of range", token)
+			}
+			indices[part] = index
+		}
+
+		if i == len(token) {
+			break
+		}
+		i++
+		part++
+	}
+
+	return indices[0], indices[1], indices[2], true, nil
+}
+
+func isObjSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func trimObjSpace(b []byte) []byte {
+	for len(b) > 0 && isObjSpace(b[0]) {
+		b = b[1:]
+	}
+	for len(b) > 0 && isObjSpace(b[len(b)-1]) {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func parseObjInt(b []byte) (int, bool) {
+	neg := false
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		neg = b[0] == '-'
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > 18 {
+		return 0, false
+	}
+
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// Powers of ten that are exact in a float64
+var objPow10 = [...]float64{
+	1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11,
+	1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22,
+}
+
+// parseObjFloat parses a decimal float without allocating. When the mantissa and exponent fit
+// in a float64 exactly the result is correctly rounded, other inputs go through strconv.
+func parseObjFloat(b []byte) (float32, error) {
+	i := 0
+	neg := false
+	if i < len(b) && (b[i] == '-' || b[i] == '+') {
+		neg = b[i] == '-'
+		i++
+	}
+
+	mantissa := uint64(0)
+	digits := 0
+	exp := 0
+	sawDigit := false
+
+	for ; i < len(b) && b[i] >= '0' && b[i] <= '9'; i++ {
+		sawDigit = true
+		if mantissa == 0 && b[i] == '0' {
+			continue
+		}
+		if digits < 19 {
+			mantissa = mantissa*10 + uint64(b[i]-'0')
+			digits++
+		} else {
+			exp++
+		}
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		for ; i < len(b) && b[i] >= '0' && b[i] <= '9'; i++ {
+			sawDigit = true
+			if mantissa == 0 && b[i] == '0' {
+				exp--
+				continue
+			}
+			if digits < 19 {
+				mantissa = mantissa*10 + uint64(b[i]-'0')
+				digits++
+				exp--
+			}
+		}
+	}
+	if i < len(b) && sawDigit && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		expNeg := false
+		if i < len(b) && (b[i] == '-' || b[i] == '+') {
+			expNeg = b[i] == '-'
+			i++
+		}
+		e := 0
+		start := i
+		for ; i < len(b) && b[i] >= '0' && b[i] <= '9'; i++ {
+			if e < 10000 {
+				e = e*10 + int(b[i]-'0')
+			}
+		}
+		if start == i {
+			sawDigit = false
+		}
+		if expNeg {
+			e = -e
+		}
+		exp += e
+	}
+
+	if !sawDigit || i != len(b) || mantissa >= 1<<53 || exp < -22 || exp > 22 {
+		// Slow path for nan, inf, hex floats, and values that would lose precision
+		v, err := strconv.ParseFloat(string(b), 32)
+		return float32(v), err
+	}
+
+	v := float64(mantissa)
+	if exp < 0 {
+		v /= objPow10[-exp]
+	} else {
+		v *= objPow10[exp]
+	}
+	if neg {
+		v = -v
+	}
+	return float32(v), nil
+}
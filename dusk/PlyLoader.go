@@ -0,0 +1,399 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Size in bytes of each PLY property type in binary files
+var plyTypeSizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+type plyProperty struct {
+	Name string
+	Type string
+
+	// CountType is the type of the item count of list properties, empty for scalars
+	CountType string
+}
+
+type plyElement struct {
+	Name       string
+	Count      int
+	Properties []plyProperty
+}
+
+// plyReader reads property values from the body of an ASCII or binary PLY file
+type plyReader struct {
+	scanner *objScanner
+	data    []byte
+	pos     int
+	order   binary.ByteOrder
+}
+
+// Value reads one value of the given type
+func (r *plyReader) Value(typ string) (float64, error) {
+	if r.scanner != nil {
+		token := r.scanner.Token()
+		for token == nil {
+			if !r.scanner.Scan() {
+				return 0, fmt.Errorf("unexpected end of file")
+			}
+			token = r.scanner.Token()
+		}
+		switch typ {
+		case "float", "float32", "double", "float64":
+			v, err := strconv.ParseFloat(string(token), 64)
+			return v, err
+		}
+		v, ok := parseObjInt(token)
+		if !ok {
+			return 0, fmt.Errorf("invalid %v '%s'", typ, token)
+		}
+		return float64(v), nil
+	}
+
+	size := plyTypeSizes[typ]
+	if r.pos+size > len(r.data) {
+		return 0, fmt.Errorf("unexpected end of file")
+	}
+	b := r.data[r.pos : r.pos+size]
+	r.pos += size
+
+	switch typ {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(r.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(r.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(r.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(r.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(r.order.Uint32(b))), nil
+	}
+	return math.Float64frombits(r.order.Uint64(b)), nil
+}
+
+// Count reads the item count of a list of typ values. The count is checked before it's converted
+// to an int, and the items must fit in the rest of the file.
+func (r *plyReader) Count(countType, typ string) (int, error) {
+	v, err := r.Value(countType)
+	if err != nil {
+		return 0, err
+	}
+
+	// ASCII items take at least a byte each
+	remaining := 0
+	if r.scanner != nil {
+		remaining = len(r.scanner.data)
+	} else {
+		remaining = (len(r.data) - r.pos) / plyTypeSizes[typ]
+	}
+	if v != math.Trunc(v) || v < 0 || v > float64(remaining) {
+		return 0, fmt.Errorf("invalid list size %v", v)
+	}
+	return int(v), nil
+}
+
+// plyColorScale returns the factor that maps a color property of the given type to 0..1
+func plyColorScale(typ string) float32 {
+	switch typ {
+	case "char", "int8":
+		return 1.0 / math.MaxInt8
+	case "uchar", "uint8":
+		return 1.0 / math.MaxUint8
+	case "short", "int16":
+		return 1.0 / math.MaxInt16
+	case "ushort", "uint16":
+		return 1.0 / math.MaxUint16
+	case "int", "int32":
+		return 1.0 / math.MaxInt32
+	case "uint", "uint32":
+		return 1.0 / math.MaxUint32
+	}
+	return 1
+}
+
+// LoadPLY reads a Stanford .ply file in ASCII or binary format into MeshData. Vertex positions,
+// normals, texture coordinates and colors are read, polygons are triangulated and other
+// elements are skipped.
+func LoadPLY(app *App, filename string) (*MeshData, error) {
+	LogLoad("Mesh '%v'", filename)
+
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	malformed := func(err error) error {
+		return fmt.Errorf("Malformed PLY file '%v': %v", filename, err)
+	}
+
+	if !bytes.HasPrefix(data, []byte("ply\n")) && !bytes.HasPrefix(data, []byte("ply\r\n")) {
+		return nil, fmt.Errorf("Invalid PLY file '%v'", filename)
+	}
+
+	// Read the header, which ends with an end_header line
+	elements := []plyElement{}
+	format := ""
+	ended := false
+
+	scanner := newObjScanner(data)
+	scanner.Scan()
+	for !ended && scanner.Scan() {
+		keyword := scanner.Token()
+		switch string(keyword) {
+		case "format":
+			format = string(scanner.Token())
+			if version := string(scanner.Token()); version != "1.0" {
+				return nil, fmt.Errorf("Unsupported PLY version '%v' in '%v'", version, filename)
+			}
+		case "element":
+			name := string(scanner.Token())
+			count, ok := parseObjInt(scanner.Token())
+			if !ok || count < 0 {
+				return nil, malformed(fmt.Errorf("invalid element '%v' on line %v", name, scanner.LineNum()))
+			}
+			elements = append(elements, plyElement{Name: name, Count: count})
+		case "property":
+			if len(elements) == 0 {
+				return nil, malformed(fmt.Errorf("property before element on line %v", scanner.LineNum()))
+			}
+			prop := plyProperty{Type: string(scanner.Token())}
+			if prop.Type == "list" {
+				prop.CountType = string(scanner.Token())
+				prop.Type = string(scanner.Token())
+				if plyTypeSizes[prop.CountType] == 0 {
+					return nil, malformed(fmt.Errorf("unknown type '%v' on line %v", prop.CountType, scanner.LineNum()))
+				}
+				switch prop.CountType {
+				case "float", "float32", "double", "float64":
+					return nil, malformed(fmt.Errorf("list count type '%v' isn't an integer on line %v", prop.CountType, scanner.LineNum()))
+				}
+			}
+			if plyTypeSizes[prop.Type] == 0 {
+				return nil, malformed(fmt.Errorf("unknown type '%v' on line %v", prop.Type, scanner.LineNum()))
+			}
+			prop.Name = string(scanner.Token())
+			element := &elements[len(elements)-1]
+			element.Properties = append(element.Properties, prop)
+		case "end_header":
+			ended = true
+		}
+	}
+	if !ended {
+		return nil, malformed(fmt.Errorf("missing end_header"))
+	}
+
+	reader := &plyReader{}
+	switch format {
+	case "ascii":
+		reader.scanner = scanner
+	case "binary_little_endian":
+		reader.data = data[scanner.pos:]
+		reader.order = binary.LittleEndian
+	case "binary_big_endian":
+		reader.data = data[scanner.pos:]
+		reader.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("Unsupported PLY format '%v' in '%v'", format, filename)
+	}
+
+	// Slots of the vertex record that each vertex property is read into
+	const (
+		plyPos    = 0
+		plyNorm   = 3
+		plyTxcd   = 6
+		plyColor  = 8
+		plyRecord = 12
+	)
+	vertexSlots := map[string]int{
+		"x": plyPos, "y": plyPos + 1, "z": plyPos + 2,
+		"nx": plyNorm, "ny": plyNorm + 1, "nz": plyNorm + 2,
+		"u": plyTxcd, "s": plyTxcd, "texture_u": plyTxcd, "texture_s": plyTxcd,
+		"v": plyTxcd + 1, "t": plyTxcd + 1, "texture_v": plyTxcd + 1, "texture_t": plyTxcd + 1,
+		"red": plyColor, "green": plyColor + 1, "blue": plyColor + 2, "alpha": plyColor + 3,
+		"diffuse_red": plyColor, "diffuse_green": plyColor + 1, "diffuse_blue": plyColor + 2,
+	}
+
+	mesh := NewMeshData()
+	hasNorms, hasTxcds, hasColors := false, false, false
+
+	// Polygons are kept as runs in polyIndices until all vertices are read
+	polyIndices := []uint32{}
+	polySizes := []int{}
+
+	for _, element := range elements {
+		switch element.Name {
+		case "vertex":
+			slots := make([]int, len(element.Properties))
+			scales := make([]float32, len(element.Properties))
+			for p, prop := range element.Properties {
+				slot, ok := vertexSlots[prop.Name]
+				if !ok || prop.CountType != "" {
+					slots[p] = -1
+					continue
+				}
+				slots[p] = slot
+				scales[p] = 1
+				switch {
+				case slot >= plyColor:
+					hasColors = true
+					scales[p] = plyColorScale(prop.Type)
+				case slot >= plyTxcd:
+					hasTxcds = true
+				case slot >= plyNorm:
+					hasNorms = true
+				}
+			}
+
+			for i := 0; i < element.Count; i++ {
+				record := [plyRecord]float32{plyColor: 1, plyColor + 1: 1, plyColor + 2: 1, plyColor + 3: 1}
+				for p, prop := range element.Properties {
+					if slots[p] < 0 {
+						if err := skipPlyProperty(reader, prop); err != nil {
+							return nil, malformed(err)
+						}
+						continue
+					}
+					v, err := reader.Value(prop.Type)
+					if err != nil {
+						return nil, malformed(err)
+					}
+					record[slots[p]] = float32(v) * scales[p]
+				}
+
+				mesh.Positions = append(mesh.Positions, mgl32.Vec3{record[0], record[1], record[2]})
+				if hasNorms {
+					mesh.Normals = append(mesh.Normals, mgl32.Vec3{record[3], record[4], record[5]})
+				}
+				if hasTxcds {
+					mesh.TexCoords = append(mesh.TexCoords, mgl32.Vec2{record[6], record[7]})
+				}
+				if hasColors {
+					mesh.Colors = append(mesh.Colors, mgl32.Vec4{record[8], record[9], record[10], record[11]})
+				}
+			}
+
+		case "face":
+			for i := 0; i < element.Count; i++ {
+				for _, prop := range element.Properties {
+					if prop.CountType == "" || (prop.Name != "vertex_indices" && prop.Name != "vertex_index") {
+						if err := skipPlyProperty(reader, prop); err != nil {
+							return nil, malformed(err)
+						}
+						continue
+					}
+					count, err := reader.Count(prop.CountType, prop.Type)
+					if err != nil {
+						return nil, malformed(err)
+					}
+					for j := 0; j < count; j++ {
+						index, err := reader.Value(prop.Type)
+						if err != nil {
+							return nil, malformed(err)
+						}
+						polyIndices = append(polyIndices, uint32(index))
+					}
+					polySizes = append(polySizes, count)
+				}
+			}
+
+		default:
+			for i := 0; i < element.Count; i++ {
+				for _, prop := range element.Properties {
+					if err := skipPlyProperty(reader, prop); err != nil {
+						return nil, malformed(err)
+					}
+				}
+			}
+		}
+	}
+
+	// Triangulate the polygons now that their vertices are known
+	var tris triangulator
+	points := []mgl32.Vec3{}
+	start := 0
+	for _, size := range polySizes {
+		poly := polyIndices[start : start+size]
+		start += size
+
+		points = points[:0]
+		valid := true
+		for _, index := range poly {
+			if index >= uint32(len(mesh.Positions)) {
+				valid = false
+				break
+			}
+			points = append(points, mesh.Positions[index])
+		}
+		if !valid {
+			return nil, malformed(fmt.Errorf("face vertex out of range"))
+		}
+
+		for _, tri := range tris.Triangulate(points) {
+			mesh.Indices = append(mesh.Indices, poly[tri[0]], poly[tri[1]], poly[tri[2]])
+		}
+	}
+
+	if len(mesh.Indices) == 0 {
+		LogWarn("PLY file '%v' has no faces", filename)
+	}
+
+	mesh.Materials["default"] = NewMaterialDef()
+	mesh.Submeshes = append(mesh.Submeshes, Submesh{
+		Name:     "default",
+		Count:    len(mesh.Indices),
+		Material: "default",
+	})
+
+	if !hasNorms {
+		mesh.GenerateNormalsWithCrease(app.CreaseAngle)
+	}
+	mesh.GenerateTangents()
+
+	mesh.OptimizeVertexCache()
+
+	return mesh, nil
+}
+
+// skipPlyProperty reads past a property that isn't used
+func skipPlyProperty(reader *plyReader, prop plyProperty) error {
+	count := 1
+	if prop.CountType != "" {
+		var err error
+		if count, err = reader.Count(prop.CountType, prop.Type); err != nil {
+			return err
+		}
+	}
+
+	if reader.scanner == nil {
+		size := count * plyTypeSizes[prop.Type]
+		if reader.pos+size > len(reader.data) {
+			return fmt.Errorf("unexpected end of file")
+		}
+		reader.pos += size
+		return nil
+	}
+
+	for i := 0; i < count; i++ {
+		if _, err := reader.Value(prop.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
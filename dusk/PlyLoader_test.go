@@ -0,0 +1,87 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// plyBinary returns a little endian PLY file with a triangle's vertices and the face data given
+func plyBinary(faceProperties string, face ...interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("ply\nformat binary_little_endian 1.0\nelement vertex 3\n" +
+		"property float x\nproperty float y\nproperty float z\n" +
+		"element face 1\n" + faceProperties + "end_header\n")
+	binary.Write(&buf, binary.LittleEndian, []float32{0, 0, 0, 1, 0, 0, 0, 1, 0})
+	for _, v := range face {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadPLY(t *testing.T) {
+	quietLogs(t)
+
+	tests := []struct {
+		Name      string
+		Data      []byte
+		Triangles int
+		Error     bool
+	}{
+		{"ASCII", []byte(`ply
+format ascii 1.0
+element vertex 3
+property float x
+property float y
+property float z
+element face 1
+property list uchar int vertex_indices
+end_header
+0 0 0
+1 0 0
+0 1 0
+3 0 1 2
+`), 1, false},
+		{"ASCII non-integer count", []byte(`ply
+format ascii 1.0
+element vertex 3
+property float x
+property float y
+property float z
+element face 1
+property list uchar int vertex_indices
+end_header
+0 0 0
+1 0 0
+0 1 0
+1e30 0 1 2
+`), 0, true},
+		{"Binary", plyBinary("property list uchar int vertex_indices\n", uint8(3), []int32{0, 1, 2}), 1, false},
+		{"Binary skipped list", plyBinary("property list uchar float weights\nproperty list uchar int vertex_indices\n",
+			uint8(2), []float32{1, 2}, uint8(3), []int32{0, 1, 2}), 1, false},
+		{"Float count type", plyBinary("property list float int vertex_indices\n", float32(3), []int32{0, 1, 2}), 0, true},
+		{"Negative count", plyBinary("property list int int vertex_indices\n", int32(-1), []int32{0, 1, 2}), 0, true},
+		{"Count past end", plyBinary("property list uint int vertex_indices\n", uint32(0xFFFFFFFF), []int32{0, 1, 2}), 0, true},
+		{"Skipped count past end", plyBinary("property list uint float weights\nproperty list uchar int vertex_indices\n",
+			uint32(0xFFFFFFFF), uint8(3), []int32{0, 1, 2}), 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			app := newMemoryApp(map[string][]byte{"triangle.ply": test.Data})
+			mesh, err := LoadPLY(app, "triangle.ply")
+			if test.Error {
+				if err == nil {
+					t.Errorf("Loaded a malformed file with %v triangles", mesh.TriangleCount())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mesh.TriangleCount() != test.Triangles {
+				t.Errorf("Got %v triangles, expected %v", mesh.TriangleCount(), test.Triangles)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package dusk
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+const tonemapFragmentSource = `#version 330 core
+
+uniform sampler2D _Texture;
+uniform float _Exposure;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec3 color = texture(_Texture, p_TexCoord).rgb * _Exposure;
+
+	// ACES filmic curve, fitted by Krzysztof Narkowicz
+	color = clamp((color * (2.51 * color + 0.03)) / (color * (2.43 * color + 0.59) + 0.14), 0.0, 1.0);
+
+	o_Color = vec4(color, 1.0);
+}
+`
+
+const gammaFragmentSource = `#version 330 core
+
+uniform sampler2D _Texture;
+uniform float _Gamma;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec3 color = texture(_Texture, p_TexCoord).rgb;
+	o_Color = vec4(pow(max(color, vec3(0.0)), vec3(1.0 / _Gamma)), 1.0);
+}
+`
+
+const fxaaFragmentSource = `#version 330 core
+
+const float FXAA_SPAN_MAX = 8.0;
+const float FXAA_REDUCE_MUL = 1.0 / 8.0;
+const float FXAA_REDUCE_MIN = 1.0 / 128.0;
+
+uniform sampler2D _Texture;
+uniform vec2 _Resolution;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec2 texel = 1.0 / _Resolution;
+	vec3 luma = vec3(0.299, 0.587, 0.114);
+
+	vec3 rgbNW = texture(_Texture, p_TexCoord + vec2(-1.0, -1.0) * texel).rgb;
+	vec3 rgbNE = texture(_Texture, p_TexCoord + vec2(1.0, -1.0) * texel).rgb;
+	vec3 rgbSW = texture(_Texture, p_TexCoord + vec2(-1.0, 1.0) * texel).rgb;
+	vec3 rgbSE = texture(_Texture, p_TexCoord + vec2(1.0, 1.0) * texel).rgb;
+	vec3 rgbM = texture(_Texture, p_TexCoord).rgb;
+
+	float lumaNW = dot(rgbNW, luma);
+	float lumaNE = dot(rgbNE, luma);
+	float lumaSW = dot(rgbSW, luma);
+	float lumaSE = dot(rgbSE, luma);
+	float lumaM = dot(rgbM, luma);
+
+	float lumaMin = min(lumaM, min(min(lumaNW, lumaNE), min(lumaSW, lumaSE)));
+	float lumaMax = max(lumaM, max(max(lumaNW, lumaNE), max(lumaSW, lumaSE)));
+
+	// Blur along the edge, perpendicular to the luma gradient
+	vec2 dir = vec2(
+		-((lumaNW + lumaNE) - (lumaSW + lumaSE)),
+		((lumaNW + lumaSW) - (lumaNE + lumaSE)));
+
+	float dirReduce = max((lumaNW + lumaNE + lumaSW + lumaSE) * (0.25 * FXAA_REDUCE_MUL), FXAA_REDUCE_MIN);
+	float rcpDirMin = 1.0 / (min(abs(dir.x), abs(dir.y)) + dirReduce);
+	dir = clamp(dir * rcpDirMin, vec2(-FXAA_SPAN_MAX), vec2(FXAA_SPAN_MAX)) * texel;
+
+	vec3 rgbA = 0.5 * (
+		texture(_Texture, p_TexCoord + dir * (1.0 / 3.0 - 0.5)).rgb +
+		texture(_Texture, p_TexCoord + dir * (2.0 / 3.0 - 0.5)).rgb);
+	vec3 rgbB = rgbA * 0.5 + 0.25 * (
+		texture(_Texture, p_TexCoord + dir * -0.5).rgb +
+		texture(_Texture, p_TexCoord + dir * 0.5).rgb);
+
+	float lumaB = dot(rgbB, luma);
+	if (lumaB < lumaMin || lumaB > lumaMax) {
+		o_Color = vec4(rgbA, 1.0);
+	} else {
+		o_Color = vec4(rgbB, 1.0);
+	}
+}
+`
+
+const vignetteFragmentSource = `#version 330 core
+
+uniform sampler2D _Texture;
+uniform float _Radius;
+uniform float _Softness;
+uniform float _Strength;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec3 color = texture(_Texture, p_TexCoord).rgb;
+
+	float dist = distance(p_TexCoord, vec2(0.5));
+	float vignette = smoothstep(_Radius, _Radius - _Softness, dist);
+
+	o_Color = vec4(color * mix(1.0, vignette, _Strength), 1.0);
+}
+`
+
+// The LUT is a horizontal strip of _LutSize slices, each _LutSize square, with red increasing
+// to the right, green increasing downwards, and blue increasing with each slice
+const colorGradingFragmentSource = `#version 330 core
+
+uniform sampler2D _Texture;
+uniform sampler2D _Lut;
+uniform float _LutSize;
+uniform float _Strength;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec3 color = clamp(texture(_Texture, p_TexCoord).rgb, 0.0, 1.0);
+
+	float blue = color.b * (_LutSize - 1.0);
+	float slice0 = floor(blue);
+	float slice1 = min(slice0 + 1.0, _LutSize - 1.0);
+
+	vec2 uv = vec2(
+		(color.r * (_LutSize - 1.0) + 0.5) / (_LutSize * _LutSize),
+		(color.g * (_LutSize - 1.0) + 0.5) / _LutSize);
+
+	vec3 graded0 = texture(_Lut, uv + vec2(slice0 / _LutSize, 0.0)).rgb;
+	vec3 graded1 = texture(_Lut, uv + vec2(slice1 / _LutSize, 0.0)).rgb;
+	vec3 graded = mix(graded0, graded1, blue - slice0);
+
+	o_Color = vec4(mix(color, graded, _Strength), 1.0);
+}
+`
+
+const bloomBrightFragmentSource = `#version 330 core
+
+uniform sampler2D _Texture;
+uniform float _Threshold;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec3 color = texture(_Texture, p_TexCoord).rgb;
+	float brightness = max(color.r, max(color.g, color.b));
+	float weight = max(brightness - _Threshold, 0.0) / max(brightness, 0.0001);
+	o_Color = vec4(color * weight, 1.0);
+}
+`
+
+const bloomBlurFragmentSource = `#version 330 core
+
+const float WEIGHTS[5] = float[](0.227027, 0.1945946, 0.1216216, 0.054054, 0.016216);
+
+uniform sampler2D _Texture;
+uniform vec2 _Resolution;
+uniform vec2 _Direction;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec2 step = _Direction / _Resolution;
+
+	vec3 color = texture(_Texture, p_TexCoord).rgb * WEIGHTS[0];
+	for (int i = 1; i < 5; ++i) {
+		color += texture(_Texture, p_TexCoord + step * float(i)).rgb * WEIGHTS[i];
+		color += texture(_Texture, p_TexCoord - step * float(i)).rgb * WEIGHTS[i];
+	}
+
+	o_Color = vec4(color, 1.0);
+}
+`
+
+const bloomCombineFragmentSource = `#version 330 core
+
+uniform sampler2D _Texture;
+uniform sampler2D _Bloom;
+uniform float _Intensity;
+
+in vec2 p_TexCoord;
+
+out vec4 o_Color;
+
+void main() {
+	vec3 color = texture(_Texture, p_TexCoord).rgb;
+	vec3 bloom = texture(_Bloom, p_TexCoord).rgb;
+	o_Color = vec4(color + bloom * _Intensity, 1.0);
+}
+`
+
+// NewTonemapEffect maps HDR color into [0, 1], it should come before any other effect
+func NewTonemapEffect(app *App, exposure float32) (*PostEffect, error) {
+	effect, err := newBuiltinPostEffect(app, "tonemap", "tonemap.fs.glsl", tonemapFragmentSource)
+	if err != nil {
+		return nil, err
+	}
+	effect.Uniforms["_Exposure"] = exposure
+	return effect, nil
+}
+
+func NewGammaEffect(app *App, gamma float32) (*PostEffect, error) {
+	effect, err := newBuiltinPostEffect(app, "gamma", "gamma.fs.glsl", gammaFragmentSource)
+	if err != nil {
+		return nil, err
+	}
+	effect.Uniforms["_Gamma"] = gamma
+	return effect, nil
+}
+
+// NewFXAAEffect anti-aliases edges, it works best after tonemapping and gamma correction
+func NewFXAAEffect(app *App) (*PostEffect, error) {
+	return newBuiltinPostEffect(app, "fxaa", "fxaa.fs.glsl", fxaaFragmentSource)
+}
+
+// NewVignetteEffect darkens the image outside of radius, measured from the center in UV space
+func NewVignetteEffect(app *App, radius, softness, strength float32) (*PostEffect, error) {
+	effect, err := newBuiltinPostEffect(app, "vignette", "vignette.fs.glsl", vignetteFragmentSource)
+	if err != nil {
+		return nil, err
+	}
+	effect.Uniforms["_Radius"] = radius
+	effect.Uniforms["_Softness"] = softness
+	effect.Uniforms["_Strength"] = strength
+	return effect, nil
+}
+
+// NewColorGradingEffect remaps colors through a LUT strip, e.g. 256x16 for a 16 entry LUT
+func NewColorGradingEffect(app *App, lutFilename string) (*PostEffect, error) {
+	opts := TextureOptions{
+		MinFilter: gl.LINEAR,
+		MagFilter: gl.LINEAR,
+		WrapS:     gl.CLAMP_TO_EDGE,
+		WrapT:     gl.CLAMP_TO_EDGE,
+		WrapR:     gl.CLAMP_TO_EDGE,
+	}
+
+	lut, err := NewTextureWithOptions(app, lutFilename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	effect, err := newBuiltinPostEffect(app, "colorgrading", "colorgrading.fs.glsl", colorGradingFragmentSource)
+	if err != nil {
+		lut.Cleanup()
+		return nil, err
+	}
+
+	_, lutSize := lut.Size()
+	effect.Uniforms["_LutSize"] = float32(lutSize)
+	effect.Uniforms["_Strength"] = 1.0
+	effect.Bind = func(shader *Shader) {
+		gl.Uniform1i(shader.GetUniformLocation("_Lut"), POST_EXTRA_TEXID)
+		gl.ActiveTexture(gl.TEXTURE0 + POST_EXTRA_TEXID)
+		lut.Bind()
+	}
+	effect.cleanup = lut.Cleanup
+
+	return effect, nil
+}
+
+// NewBloomEffect adds a blurred copy of everything brighter than threshold, it should come before tonemapping
+func NewBloomEffect(app *App, threshold, intensity float32, iterations int) (*PostEffect, error) {
+	effect, err := newBuiltinPostEffect(app, "bloom", "bloom.fs.glsl", bloomCombineFragmentSource)
+	if err != nil {
+		return nil, err
+	}
+
+	bright, err := newBuiltinPostEffect(app, "bloom-bright", "bloom-bright.fs.glsl", bloomBrightFragmentSource)
+	if err != nil {
+		effect.Cleanup()
+		return nil, err
+	}
+
+	blur, err := newBuiltinPostEffect(app, "bloom-blur", "bloom-blur.fs.glsl", bloomBlurFragmentSource)
+	if err != nil {
+		effect.Cleanup()
+		bright.Cleanup()
+		return nil, err
+	}
+
+	// Bloom is blurred at half resolution
+	targets := [2]*RenderTarget{}
+	for i := range targets {
+		targets[i], err = NewRenderTarget(app, RenderTargetOptions{
+			Width:        app.WindowWidth / 2,
+			Height:       app.WindowHeight / 2,
+			ColorFormats: []int32{gl.RGBA16F},
+		})
+		if err != nil {
+			effect.Cleanup()
+			bright.Cleanup()
+			blur.Cleanup()
+			if targets[0] != nil {
+				targets[0].Cleanup()
+			}
+			return nil, err
+		}
+	}
+
+	effect.Uniforms["_Threshold"] = threshold
+	effect.Uniforms["_Intensity"] = intensity
+	bright.Uniforms = effect.Uniforms
+
+	effect.Bind = func(shader *Shader) {
+		gl.Uniform1i(shader.GetUniformLocation("_Bloom"), POST_EXTRA_TEXID)
+		gl.ActiveTexture(gl.TEXTURE0 + POST_EXTRA_TEXID)
+		targets[0].ColorTexture(0).Bind()
+	}
+
+	horizontal := func(shader *Shader) {
+		gl.Uniform2f(shader.GetUniformLocation("_Direction"), 1, 0)
+	}
+	vertical := func(shader *Shader) {
+		gl.Uniform2f(shader.GetUniformLocation("_Direction"), 0, 1)
+	}
+
+	effect.render = func(post *PostProcess, input *Texture, output *RenderTarget) {
+		width, height := input.Size()
+		width, height = maxInt(1, width/2), maxInt(1, height/2)
+		for _, target := range targets {
+			if w, h := target.Size(); w != width || h != height {
+				if err := target.Resize(width, height); err != nil {
+					LogError("Failed to resize bloom target, %v", err)
+					return
+				}
+			}
+		}
+
+		post.RenderPass(bright.Shader, bright, input, targets[0])
+
+		for i := 0; i < iterations; i++ {
+			blur.Bind = horizontal
+			post.RenderPass(blur.Shader, blur, targets[0].ColorTexture(0), targets[1])
+			blur.Bind = vertical
+			post.RenderPass(blur.Shader, blur, targets[1].ColorTexture(0), targets[0])
+		}
+
+		post.RenderPass(effect.Shader, effect, input, output)
+	}
+
+	effect.cleanup = func() {
+		bright.Cleanup()
+		blur.Cleanup()
+		for _, target := range targets {
+			target.Cleanup()
+		}
+	}
+
+	return effect, nil
+}
@@ -0,0 +1,268 @@
+package dusk
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+const (
+	POST_INPUT_TEXID = 0
+	POST_DEPTH_TEXID = 1
+	POST_EXTRA_TEXID = 2
+)
+
+// Draws a single triangle covering the screen, without any vertex data
+const postVertexSource = `#version 330 core
+
+out vec2 p_TexCoord;
+
+void main() {
+	vec2 position = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	p_TexCoord = position;
+	gl_Position = vec4(position * 2.0 - 1.0, 0.0, 1.0);
+}
+`
+
+// PostEffect is a full-screen pass, its shader is given
+//
+//	_Texture    the output of the previous effect, or the scene
+//	_Depth      the depth of the scene
+//	_Resolution the size of the output in pixels
+//	_Time       the total running time in seconds
+//
+// along with every value in Uniforms
+type PostEffect struct {
+	Name     string
+	Enabled  bool
+	Shader   *Shader
+	Uniforms map[string]float32
+
+	// Called after the uniforms are set, to bind anything else the shader needs
+	Bind func(shader *Shader)
+
+	// Replaces the single pass for effects that need more than one, e.g. bloom
+	render  func(post *PostProcess, input *Texture, output *RenderTarget)
+	cleanup func()
+}
+
+func NewPostEffect(name string, shader *Shader) *PostEffect {
+	return &PostEffect{
+		Name:     name,
+		Enabled:  true,
+		Shader:   shader,
+		Uniforms: map[string]float32{},
+	}
+}
+
+// NewPostEffectFromFile builds an effect from a fragment shader, paired with the built-in
+// full-screen vertex shader which provides p_TexCoord
+func NewPostEffectFromFile(app *App, name string, filename string) (*PostEffect, error) {
+	source, err := ReadShaderSource(app, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBuiltinPostEffect(app, name, filename, source)
+}
+
+func newBuiltinPostEffect(app *App, name string, filename string, source string) (*PostEffect, error) {
+	shader, err := NewShaderFromSources(app,
+		[]string{"post.vs.glsl", filename},
+		[]string{postVertexSource, source},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPostEffect(name, shader), nil
+}
+
+func (effect *PostEffect) Cleanup() {
+	if effect.cleanup != nil {
+		effect.cleanup()
+	}
+	effect.Shader.Cleanup()
+}
+
+// PostProcess renders the scene offscreen, then runs each enabled effect in order before presenting
+type PostProcess struct {
+	Effects []*PostEffect
+
+	scene    *RenderTarget
+	pingPong [2]*RenderTarget
+	glVao    uint32
+	time     float32
+
+	prevFbo     int32
+	prevProgram int32
+}
+
+func NewPostProcess(app *App) (*PostProcess, error) {
+	var err error
+
+	// Match the multisampling of the window
+	var samples int32
+	gl.GetIntegerv(gl.SAMPLES, &samples)
+
+	post := &PostProcess{}
+
+	post.scene, err = NewRenderTarget(app, RenderTargetOptions{
+		ColorFormats:     []int32{gl.RGBA16F},
+		Depth:            true,
+		Stencil:          true,
+		Samples:          samples,
+		ResizeWithWindow: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range post.pingPong {
+		post.pingPong[i], err = NewRenderTarget(app, RenderTargetOptions{
+			ColorFormats:     []int32{gl.RGBA16F},
+			ResizeWithWindow: true,
+		})
+		if err != nil {
+			post.Cleanup()
+			return nil, err
+		}
+	}
+
+	gl.GenVertexArrays(1, &post.glVao)
+
+	return post, nil
+}
+
+// Cleanup frees the render targets, and every effect that was added
+func (post *PostProcess) Cleanup() {
+	for _, effect := range post.Effects {
+		effect.Cleanup()
+	}
+	for _, target := range post.pingPong {
+		if target != nil {
+			target.Cleanup()
+		}
+	}
+	if post.scene != nil {
+		post.scene.Cleanup()
+	}
+	gl.DeleteVertexArrays(1, &post.glVao)
+}
+
+func (post *PostProcess) Add(effect *PostEffect) {
+	post.Effects = append(post.Effects, effect)
+}
+
+func (post *PostProcess) Get(name string) *PostEffect {
+	for _, effect := range post.Effects {
+		if effect.Name == name {
+			return effect
+		}
+	}
+	return nil
+}
+
+func (post *PostProcess) SetEnabled(name string, enabled bool) {
+	if effect := post.Get(name); effect != nil {
+		effect.Enabled = enabled
+	}
+}
+
+func (post *PostProcess) HasEnabled() bool {
+	for _, effect := range post.Effects {
+		if effect.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// Scene returns the target the scene is rendered into
+func (post *PostProcess) Scene() *RenderTarget {
+	return post.scene
+}
+
+// Begin directs rendering into the scene target
+func (post *PostProcess) Begin() {
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &post.prevFbo)
+	post.scene.Bind()
+}
+
+// End runs the enabled effects, the last one draws to the framebuffer that was bound at Begin
+func (post *PostProcess) End() {
+	post.scene.Unbind()
+
+	gl.GetIntegerv(gl.CURRENT_PROGRAM, &post.prevProgram)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.BLEND)
+	gl.BindVertexArray(post.glVao)
+
+	enabled := []*PostEffect{}
+	for _, effect := range post.Effects {
+		if effect.Enabled {
+			enabled = append(enabled, effect)
+		}
+	}
+
+	input := post.scene.ColorTexture(0)
+	for i, effect := range enabled {
+		var output *RenderTarget
+		if i < len(enabled)-1 {
+			output = post.pingPong[i%2]
+		}
+
+		if effect.render != nil {
+			effect.render(post, input, output)
+		} else {
+			post.RenderPass(effect.Shader, effect, input, output)
+		}
+
+		if output != nil {
+			input = output.ColorTexture(0)
+		}
+	}
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.UseProgram(uint32(post.prevProgram))
+}
+
+// RenderPass draws a full-screen triangle with shader into output, or to the screen if output is nil
+func (post *PostProcess) RenderPass(shader *Shader, effect *PostEffect, input *Texture, output *RenderTarget) {
+	width, height := post.scene.Size()
+	if output != nil {
+		output.Bind()
+		width, height = output.Size()
+	} else {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(post.prevFbo))
+	}
+
+	shader.Use()
+	gl.Uniform1i(shader.GetUniformLocation("_Texture"), POST_INPUT_TEXID)
+	gl.Uniform1i(shader.GetUniformLocation("_Depth"), POST_DEPTH_TEXID)
+	gl.Uniform2f(shader.GetUniformLocation("_Resolution"), float32(width), float32(height))
+	gl.Uniform1f(shader.GetUniformLocation("_Time"), post.time)
+
+	gl.ActiveTexture(gl.TEXTURE0 + POST_INPUT_TEXID)
+	input.Bind()
+	if depth := post.scene.DepthTexture(); depth != nil {
+		gl.ActiveTexture(gl.TEXTURE0 + POST_DEPTH_TEXID)
+		depth.Bind()
+	}
+
+	if effect != nil {
+		for name, value := range effect.Uniforms {
+			gl.Uniform1f(shader.GetUniformLocation(name), value)
+		}
+		if effect.Bind != nil {
+			effect.Bind(shader)
+		}
+	}
+
+	gl.BindVertexArray(post.glVao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+
+	if output != nil {
+		output.Unbind()
+	}
+}
@@ -0,0 +1,390 @@
+package dusk
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// primitiveBuilder appends generated surfaces to a mesh. Texture coordinates have their origin
+// at the bottom left, as they're stored for OBJ files, and triangles are counter-clockwise.
+type primitiveBuilder struct {
+	mesh *MeshData
+}
+
+func newPrimitiveBuilder() *primitiveBuilder {
+	return &primitiveBuilder{mesh: NewMeshData()}
+}
+
+func (b *primitiveBuilder) vertex(position, normal mgl32.Vec3, txcd mgl32.Vec2) uint32 {
+	b.mesh.Positions = append(b.mesh.Positions, position)
+	b.mesh.Normals = append(b.mesh.Normals, normal)
+	b.mesh.TexCoords = append(b.mesh.TexCoords, txcd)
+	return uint32(len(b.mesh.Positions) - 1)
+}
+
+// triangle adds a triangle unless it has no area, as at the poles of a sphere or the tip of a cone
+func (b *primitiveBuilder) triangle(i0, i1, i2 uint32) {
+	p := b.mesh.Positions
+	e1, e2 := p[i1].Sub(p[i0]), p[i2].Sub(p[i0])
+	if e1.Cross(e2).Len() <= 1e-6*(e1.LenSqr()+e2.LenSqr()) {
+		return
+	}
+	b.mesh.Indices = append(b.mesh.Indices, i0, i1, i2)
+}
+
+// grid adds the triangles of a grid of vertices, with cols+1 vertices in each row starting at
+// first, and rows going up in v
+func (b *primitiveBuilder) grid(first uint32, cols, rows int) {
+	stride := uint32(cols + 1)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			i := first + uint32(row)*stride + uint32(col)
+			b.triangle(i, i+1, i+stride+1)
+			b.triangle(i, i+stride+1, i+stride)
+		}
+	}
+}
+
+// face adds a flat rectangle from origin along the u and v axes, facing along their cross product
+func (b *primitiveBuilder) face(origin, uAxis, vAxis mgl32.Vec3, cols, rows int) {
+	normal := uAxis.Cross(vAxis).Normalize()
+	first := uint32(len(b.mesh.Positions))
+	for row := 0; row <= rows; row++ {
+		v := float32(row) / float32(rows)
+		for col := 0; col <= cols; col++ {
+			u := float32(col) / float32(cols)
+			b.vertex(origin.Add(uAxis.Mul(u)).Add(vAxis.Mul(v)), normal, mgl32.Vec2{u, v})
+		}
+	}
+	b.grid(first, cols, rows)
+}
+
+// profilePoint is a point of a lathe profile, with its distance from the Y axis, height, and
+// normal in the same plane. Normals face right of the direction the profile is drawn in.
+type profilePoint struct {
+	Radius float32
+	Y      float32
+	Normal mgl32.Vec2
+}
+
+// lathe revolves a profile, drawn bottom to top, around the Y axis. U goes around the axis from
+// -Z through +X, so the texture seam is at the back, and V follows the length of the profile.
+func (b *primitiveBuilder) lathe(profile []profilePoint, segments int) {
+	length := make([]float32, len(profile))
+	for i := 1; i < len(profile); i++ {
+		step := mgl32.Vec2{profile[i].Radius - profile[i-1].Radius, profile[i].Y - profile[i-1].Y}
+		length[i] = length[i-1] + step.Len()
+	}
+	total := length[len(length)-1]
+
+	first := uint32(len(b.mesh.Positions))
+	for i, point := range profile {
+		v := length[i] / total
+		for s := 0; s <= segments; s++ {
+			u := float32(s) / float32(segments)
+			sin, cos := sincos((float64(u) - 0.5) * 2 * math.Pi)
+			b.vertex(
+				mgl32.Vec3{point.Radius * sin, point.Y, point.Radius * cos},
+				mgl32.Vec3{point.Normal[0] * sin, point.Normal[1], point.Normal[0] * cos},
+				mgl32.Vec2{u, v},
+			)
+		}
+	}
+	b.grid(first, segments, len(profile)-1)
+}
+
+// disc adds a flat circle at height y facing up or down, with its texture projected onto it from
+// the side it faces
+func (b *primitiveBuilder) disc(radius, y float32, up bool, segments int) {
+	normal := mgl32.Vec3{0, -1, 0}
+	if up {
+		normal = mgl32.Vec3{0, 1, 0}
+	}
+
+	center := b.vertex(mgl32.Vec3{0, y, 0}, normal, mgl32.Vec2{0.5, 0.5})
+	for s := 0; s <= segments; s++ {
+		x, z := sincos(float64(s) / float64(segments) * 2 * math.Pi)
+		b.vertex(mgl32.Vec3{radius * x, y, radius * z}, normal, mgl32.Vec2{0.5 + x/2, 0.5 - normal[1]*z/2})
+		if s > 0 {
+			i := uint32(len(b.mesh.Positions) - 1)
+			if up {
+				b.triangle(center, i-1, i)
+			} else {
+				b.triangle(center, i, i-1)
+			}
+		}
+	}
+}
+
+// finish puts the whole mesh in one submesh with the default material, and generates tangents.
+// Vertices only used by skipped triangles are removed.
+func (b *primitiveBuilder) finish(name string) *MeshData {
+	mesh := b.mesh
+
+	remap := []uint32{}
+	indices := map[uint32]uint32{}
+	for i, index := range mesh.Indices {
+		newIndex, ok := indices[index]
+		if !ok {
+			newIndex = uint32(len(remap))
+			indices[index] = newIndex
+			remap = append(remap, index)
+		}
+		mesh.Indices[i] = newIndex
+	}
+	mesh.remapVertices(remap)
+
+	mesh.Materials["default"] = NewMaterialDef()
+	mesh.Submeshes = []Submesh{{
+		Name:     name,
+		Count:    len(mesh.Indices),
+		Material: "default",
+	}}
+	mesh.GenerateTangents()
+	mesh.OptimizeVertexCache()
+	return mesh
+}
+
+// circleProfile returns the points of an arc of the given radius around (centerRadius, centerY),
+// from angle start to end in radians, with the normals facing away from the center
+func circleProfile(radius, centerRadius, centerY, start, end float32, steps int) []profilePoint {
+	points := make([]profilePoint, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		angle := start + (end-start)*float32(i)/float32(steps)
+		sin, cos := sincos(float64(angle))
+		points = append(points, profilePoint{
+			Radius: centerRadius + radius*cos,
+			Y:      centerY + radius*sin,
+			Normal: mgl32.Vec2{cos, sin},
+		})
+	}
+	return points
+}
+
+// sincos is math.Sincos with values that should be zero snapped to it, so the ends of a seam and
+// the points at a pole are exactly the same
+func sincos(angle float64) (float32, float32) {
+	sin, cos := math.Sincos(angle)
+	if math.Abs(sin) < 1e-6 {
+		sin = 0
+	}
+	if math.Abs(cos) < 1e-6 {
+		cos = 0
+	}
+	return float32(sin), float32(cos)
+}
+
+// clampSegments keeps generator parameters at a usable minimum
+func clampSegments(value, min int) int {
+	if value < min {
+		return min
+	}
+	return value
+}
+
+// NewCubeMesh returns a cube centered on the origin, with the whole texture on each face
+func NewCubeMesh(size float32) *MeshData {
+	b := newPrimitiveBuilder()
+	h := size / 2
+	x, y, z := mgl32.Vec3{size, 0, 0}, mgl32.Vec3{0, size, 0}, mgl32.Vec3{0, 0, size}
+
+	b.face(mgl32.Vec3{-h, -h, h}, x, y, 1, 1)         // front
+	b.face(mgl32.Vec3{h, -h, -h}, x.Mul(-1), y, 1, 1) // back
+	b.face(mgl32.Vec3{h, -h, h}, z.Mul(-1), y, 1, 1)  // right
+	b.face(mgl32.Vec3{-h, -h, -h}, z, y, 1, 1)        // left
+	b.face(mgl32.Vec3{-h, h, h}, x, z.Mul(-1), 1, 1)  // top
+	b.face(mgl32.Vec3{-h, -h, -h}, x, z, 1, 1)        // bottom
+	return b.finish("cube")
+}
+
+// NewPlaneMesh returns a plane on the XZ axes facing up, centered on the origin and divided into
+// a grid of quads
+func NewPlaneMesh(width, depth float32, cols, rows int) *MeshData {
+	b := newPrimitiveBuilder()
+	b.face(mgl32.Vec3{-width / 2, 0, depth / 2}, mgl32.Vec3{width, 0, 0}, mgl32.Vec3{0, 0, -depth},
+		clampSegments(cols, 1), clampSegments(rows, 1))
+	return b.finish("plane")
+}
+
+// NewUVSphereMesh returns a sphere made of segments around the Y axis and rings from pole to pole,
+// with the texture wrapped around it as an equirectangular map
+func NewUVSphereMesh(radius float32, segments, rings int) *MeshData {
+	b := newPrimitiveBuilder()
+	b.lathe(circleProfile(radius, 0, 0, -math.Pi/2, math.Pi/2, clampSegments(rings, 2)), clampSegments(segments, 3))
+	return b.finish("sphere")
+}
+
+// NewIcosphereMesh returns a sphere made by subdividing an icosahedron, which has more evenly sized
+// triangles than a UV sphere. The texture is wrapped around it as on a UV sphere.
+func NewIcosphereMesh(radius float32, subdivisions int) *MeshData {
+	t := float32((1 + math.Sqrt(5)) / 2)
+	points := []mgl32.Vec3{
+		{-1, t, 0}, {1, t, 0}, {-1, -t, 0}, {1, -t, 0},
+		{0, -1, t}, {0, 1, t}, {0, -1, -t}, {0, 1, -t},
+		{t, 0, -1}, {t, 0, 1}, {-t, 0, -1}, {-t, 0, 1},
+	}
+	for i := range points {
+		points[i] = points[i].Normalize()
+	}
+	triangles := [][3]int{
+		{0, 11, 5}, {0, 5, 1}, {0, 1, 7}, {0, 7, 10}, {0, 10, 11},
+		{1, 5, 9}, {5, 11, 4}, {11, 10, 2}, {10, 7, 6}, {7, 1, 8},
+		{3, 9, 4}, {3, 4, 2}, {3, 2, 6}, {3, 6, 8}, {3, 8, 9},
+		{4, 9, 5}, {2, 4, 11}, {6, 2, 10}, {8, 6, 7}, {9, 8, 1},
+	}
+
+	// Each edge is split at its midpoint, shared by the triangles on either side
+	for i := 0; i < subdivisions; i++ {
+		midpoints := map[[2]int]int{}
+		midpoint := func(a, b int) int {
+			if a > b {
+				a, b = b, a
+			}
+			if m, ok := midpoints[[2]int{a, b}]; ok {
+				return m
+			}
+			points = append(points, points[a].Add(points[b]).Normalize())
+			midpoints[[2]int{a, b}] = len(points) - 1
+			return len(points) - 1
+		}
+
+		divided := make([][3]int, 0, len(triangles)*4)
+		for _, tri := range triangles {
+			ab, bc, ca := midpoint(tri[0], tri[1]), midpoint(tri[1], tri[2]), midpoint(tri[2], tri[0])
+			divided = append(divided,
+				[3]int{tri[0], ab, ca}, [3]int{tri[1], bc, ab}, [3]int{tri[2], ca, bc}, [3]int{ab, bc, ca})
+		}
+		triangles = divided
+	}
+
+	// Vertices are split where triangles cross the texture seam at the back, and at the poles
+	// so each triangle there has its own U
+	type sphereVertex struct {
+		Point int
+		UV    mgl32.Vec2
+	}
+	b := newPrimitiveBuilder()
+	indices := map[sphereVertex]uint32{}
+	for _, tri := range triangles {
+		var uvs [3]mgl32.Vec2
+		for c, p := range tri {
+			point := points[p]
+			uvs[c] = mgl32.Vec2{
+				float32(math.Atan2(float64(point[0]), float64(point[2]))/(2*math.Pi)) + 0.5,
+				float32(math.Asin(float64(mgl32.Clamp(point[1], -1, 1)))/math.Pi) + 0.5,
+			}
+		}
+
+		minU := math.Min(float64(uvs[0][0]), math.Min(float64(uvs[1][0]), float64(uvs[2][0])))
+		maxU := math.Max(float64(uvs[0][0]), math.Max(float64(uvs[1][0]), float64(uvs[2][0])))
+		if maxU-minU > 0.5 {
+			for c := range uvs {
+				if uvs[c][0] < 0.5 {
+					uvs[c][0]++
+				}
+			}
+		}
+		for c, p := range tri {
+			if math.Abs(float64(points[p][1])) > 0.99999 {
+				uvs[c][0] = (uvs[(c+1)%3][0] + uvs[(c+2)%3][0]) / 2
+			}
+		}
+
+		var corners [3]uint32
+		for c, p := range tri {
+			key := sphereVertex{p, uvs[c]}
+			index, ok := indices[key]
+			if !ok {
+				index = b.vertex(points[p].Mul(radius), points[p], uvs[c])
+				indices[key] = index
+			}
+			corners[c] = index
+		}
+		b.triangle(corners[0], corners[1], corners[2])
+	}
+	return b.finish("icosphere")
+}
+
+// NewCylinderMesh returns a capped cylinder along the Y axis, centered on the origin
+func NewCylinderMesh(radius, height float32, segments int) *MeshData {
+	return newFrustumMesh("cylinder", radius, radius, height, segments)
+}
+
+// NewConeMesh returns a cone along the Y axis with its base at -height/2 and tip at height/2
+func NewConeMesh(radius, height float32, segments int) *MeshData {
+	return newFrustumMesh("cone", radius, 0, height, segments)
+}
+
+func newFrustumMesh(name string, bottom, top, height float32, segments int) *MeshData {
+	segments = clampSegments(segments, 3)
+	b := newPrimitiveBuilder()
+
+	// The side's normal leans by how much the radius narrows
+	normal := mgl32.Vec2{height, bottom - top}.Normalize()
+	b.lathe([]profilePoint{
+		{Radius: bottom, Y: -height / 2, Normal: normal},
+		{Radius: top, Y: height / 2, Normal: normal},
+	}, segments)
+
+	b.disc(bottom, -height/2, false, segments)
+	if top > 0 {
+		b.disc(top, height/2, true, segments)
+	}
+	return b.finish(name)
+}
+
+// NewTorusMesh returns a torus around the Y axis. radius is the distance from the center to the
+// middle of the tube, made of sides around its length of segments.
+func NewTorusMesh(radius, tubeRadius float32, segments, sides int) *MeshData {
+	b := newPrimitiveBuilder()
+	b.lathe(circleProfile(tubeRadius, radius, 0, -math.Pi, math.Pi, clampSegments(sides, 3)), clampSegments(segments, 3))
+	return b.finish("torus")
+}
+
+// NewCapsuleMesh returns a cylinder along the Y axis with hemispheres on each end, height tall in
+// total including them. Each hemisphere has rings from its pole to the cylinder.
+func NewCapsuleMesh(radius, height float32, segments, rings int) *MeshData {
+	rings = clampSegments(rings, 1)
+	half := float32(math.Max(float64(height/2-radius), 0))
+
+	// The two halves share the points where they meet, leaving the cylinder between them
+	profile := circleProfile(radius, 0, -half, -math.Pi/2, 0, rings)
+	profile = append(profile, circleProfile(radius, 0, half, 0, math.Pi/2, rings)...)
+
+	b := newPrimitiveBuilder()
+	b.lathe(profile, clampSegments(segments, 3))
+	return b.finish("capsule")
+}
+
+func NewCubeModel(app *App, size float32) (*Model, error) {
+	return NewModelFromMesh(app, NewCubeMesh(size))
+}
+
+func NewPlaneModel(app *App, width, depth float32, cols, rows int) (*Model, error) {
+	return NewModelFromMesh(app, NewPlaneMesh(width, depth, cols, rows))
+}
+
+func NewUVSphereModel(app *App, radius float32, segments, rings int) (*Model, error) {
+	return NewModelFromMesh(app, NewUVSphereMesh(radius, segments, rings))
+}
+
+func NewIcosphereModel(app *App, radius float32, subdivisions int) (*Model, error) {
+	return NewModelFromMesh(app, NewIcosphereMesh(radius, subdivisions))
+}
+
+func NewCylinderModel(app *App, radius, height float32, segments int) (*Model, error) {
+	return NewModelFromMesh(app, NewCylinderMesh(radius, height, segments))
+}
+
+func NewConeModel(app *App, radius, height float32, segments int) (*Model, error) {
+	return NewModelFromMesh(app, NewConeMesh(radius, height, segments))
+}
+
+func NewTorusModel(app *App, radius, tubeRadius float32, segments, sides int) (*Model, error) {
+	return NewModelFromMesh(app, NewTorusMesh(radius, tubeRadius, segments, sides))
+}
+
+func NewCapsuleModel(app *App, radius, height float32, segments, rings int) (*Model, error) {
+	return NewModelFromMesh(app, NewCapsuleMesh(radius, height, segments, rings))
+}
@@ -0,0 +1,353 @@
+package dusk
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type RenderTargetOptions struct {
+	// Size in pixels, the window size is used if either is 0
+	Width  int
+	Height int
+
+	// Internal format of each color attachment, e.g. gl.RGBA8 or gl.RGBA16F, defaults to a single gl.RGBA8
+	ColorFormats []int32
+
+	Depth   bool
+	Stencil bool
+
+	// Number of samples for multisampling, 0 or 1 to disable
+	Samples int32
+
+	// Follow the size of the window when it changes
+	ResizeWithWindow bool
+}
+
+type RenderTarget struct {
+	options RenderTargetOptions
+	width   int
+	height  int
+
+	// When multisampling, rendering goes into renderbuffers which are resolved into the textures
+	glFbo        uint32
+	glResolveFbo uint32
+	glColorRbos  []uint32
+	glDepthRbo   uint32
+
+	colorTextures []*Texture
+	depthTexture  *Texture
+
+	prevFbo      int32
+	prevViewport [4]int32
+
+	app    *App
+	resize func(data interface{})
+}
+
+func NewRenderTarget(app *App, opts RenderTargetOptions) (*RenderTarget, error) {
+	if len(opts.ColorFormats) == 0 {
+		opts.ColorFormats = []int32{gl.RGBA8}
+	}
+
+	var maxAttachments int32
+	gl.GetIntegerv(gl.MAX_COLOR_ATTACHMENTS, &maxAttachments)
+	if int32(len(opts.ColorFormats)) > maxAttachments {
+		return nil, fmt.Errorf("Render target has %v color attachments, the maximum is %v",
+			len(opts.ColorFormats), maxAttachments)
+	}
+
+	width, height := opts.Width, opts.Height
+	if width == 0 || height == 0 {
+		width, height = app.WindowWidth, app.WindowHeight
+	}
+
+	target := &RenderTarget{
+		options: opts,
+		app:     app,
+	}
+
+	gl.GenFramebuffers(1, &target.glFbo)
+
+	for range opts.ColorFormats {
+		target.colorTextures = append(target.colorTextures, &Texture{})
+	}
+	if opts.Depth || opts.Stencil {
+		target.depthTexture = &Texture{}
+	}
+
+	if target.multisampled() {
+		gl.GenFramebuffers(1, &target.glResolveFbo)
+		target.glColorRbos = make([]uint32, len(opts.ColorFormats))
+		gl.GenRenderbuffers(int32(len(target.glColorRbos)), &target.glColorRbos[0])
+		if opts.Depth || opts.Stencil {
+			gl.GenRenderbuffers(1, &target.glDepthRbo)
+		}
+	}
+
+	for _, tex := range target.colorTextures {
+		gl.GenTextures(1, &tex.glId)
+	}
+	if target.depthTexture != nil {
+		gl.GenTextures(1, &target.depthTexture.glId)
+	}
+
+	err := target.Resize(width, height)
+	if err != nil {
+		target.Cleanup()
+		return nil, err
+	}
+
+	if opts.ResizeWithWindow {
+		target.resize = func(data interface{}) {
+			size := data.(mgl32.Vec2)
+
+			err := target.Resize(int(size.X()), int(size.Y()))
+			if err != nil {
+				LogError("Failed to resize render target, %v", err)
+			}
+		}
+		app.EvtResize.Subscribe(&target.resize)
+	}
+
+	return target, nil
+}
+
+func (target *RenderTarget) Cleanup() {
+	if target.resize != nil {
+		target.app.EvtResize.Unsubscribe(&target.resize)
+	}
+
+	for _, tex := range target.colorTextures {
+		tex.Cleanup()
+	}
+	if target.depthTexture != nil {
+		target.depthTexture.Cleanup()
+	}
+	if len(target.glColorRbos) > 0 {
+		gl.DeleteRenderbuffers(int32(len(target.glColorRbos)), &target.glColorRbos[0])
+	}
+	if target.glDepthRbo != 0 {
+		gl.DeleteRenderbuffers(1, &target.glDepthRbo)
+	}
+	if target.glResolveFbo != 0 {
+		gl.DeleteFramebuffers(1, &target.glResolveFbo)
+	}
+	gl.DeleteFramebuffers(1, &target.glFbo)
+}
+
+func (target *RenderTarget) multisampled() bool {
+	return target.options.Samples > 1
+}
+
+func (target *RenderTarget) Size() (int, int) {
+	return target.width, target.height
+}
+
+// ColorTexture returns the i-th color attachment, usable as a Texture once Unbind has been called
+func (target *RenderTarget) ColorTexture(i int) *Texture {
+	return target.colorTextures[i]
+}
+
+func (target *RenderTarget) ColorCount() int {
+	return len(target.colorTextures)
+}
+
+// DepthTexture returns the depth (and stencil) attachment, or nil if there isn't one
+func (target *RenderTarget) DepthTexture() *Texture {
+	return target.depthTexture
+}
+
+func (target *RenderTarget) depthFormat() textureFormat {
+	if target.options.Stencil {
+		return uncompressedFormat(gl.DEPTH24_STENCIL8, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, 4)
+	}
+	return uncompressedFormat(gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, 4)
+}
+
+func (target *RenderTarget) depthAttachment() uint32 {
+	if target.options.Stencil {
+		return gl.DEPTH_STENCIL_ATTACHMENT
+	}
+	return gl.DEPTH_ATTACHMENT
+}
+
+// Resize reallocates all attachments, the Textures returned by ColorTexture and DepthTexture remain valid
+func (target *RenderTarget) Resize(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("Invalid render target size %vx%v", width, height)
+	}
+
+	target.width = width
+	target.height = height
+
+	var prevFbo int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &prevFbo)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(prevFbo))
+
+	// The textures are attached to the resolve framebuffer when multisampling
+	texFbo := target.glFbo
+	if target.multisampled() {
+		texFbo = target.glResolveFbo
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, texFbo)
+	for i, tex := range target.colorTextures {
+		format := renderTargetColorFormat(target.options.ColorFormats[i])
+		allocRenderTexture(tex, format, width, height)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+uint32(i), gl.TEXTURE_2D, tex.glId, 0)
+	}
+	if target.depthTexture != nil {
+		allocRenderTexture(target.depthTexture, target.depthFormat(), width, height)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, target.depthAttachment(), gl.TEXTURE_2D, target.depthTexture.glId, 0)
+	}
+	if err := checkFramebuffer(); err != nil {
+		return err
+	}
+
+	if target.multisampled() {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, target.glFbo)
+		for i, rbo := range target.glColorRbos {
+			gl.BindRenderbuffer(gl.RENDERBUFFER, rbo)
+			gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, target.options.Samples,
+				uint32(target.options.ColorFormats[i]), int32(width), int32(height))
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+uint32(i), gl.RENDERBUFFER, rbo)
+		}
+		if target.glDepthRbo != 0 {
+			gl.BindRenderbuffer(gl.RENDERBUFFER, target.glDepthRbo)
+			gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, target.options.Samples,
+				target.depthFormat().InternalFormat, int32(width), int32(height))
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, target.depthAttachment(), gl.RENDERBUFFER, target.glDepthRbo)
+		}
+		gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+		if err := checkFramebuffer(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Bind directs rendering into the target, until Unbind is called
+func (target *RenderTarget) Bind() {
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &target.prevFbo)
+	gl.GetIntegerv(gl.VIEWPORT, &target.prevViewport[0])
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, target.glFbo)
+	gl.Viewport(0, 0, int32(target.width), int32(target.height))
+
+	drawBuffers := make([]uint32, len(target.colorTextures))
+	for i := range drawBuffers {
+		drawBuffers[i] = gl.COLOR_ATTACHMENT0 + uint32(i)
+	}
+	gl.DrawBuffers(int32(len(drawBuffers)), &drawBuffers[0])
+}
+
+// Unbind resolves multisampling, and restores the previous framebuffer and viewport
+func (target *RenderTarget) Unbind() {
+	if target.multisampled() {
+		target.Resolve()
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(target.prevFbo))
+	gl.Viewport(target.prevViewport[0], target.prevViewport[1], target.prevViewport[2], target.prevViewport[3])
+}
+
+// Resolve copies the multisampled attachments into the textures, Unbind does this automatically
+func (target *RenderTarget) Resolve() {
+	if !target.multisampled() {
+		return
+	}
+
+	var prevRead, prevDraw int32
+	gl.GetIntegerv(gl.READ_FRAMEBUFFER_BINDING, &prevRead)
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &prevDraw)
+
+	width, height := int32(target.width), int32(target.height)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, target.glFbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, target.glResolveFbo)
+
+	for i := range target.colorTextures {
+		attachment := gl.COLOR_ATTACHMENT0 + uint32(i)
+		gl.ReadBuffer(attachment)
+		gl.DrawBuffers(1, &attachment)
+		gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	}
+
+	if target.depthTexture != nil {
+		mask := uint32(gl.DEPTH_BUFFER_BIT)
+		if target.options.Stencil {
+			mask |= gl.STENCIL_BUFFER_BIT
+		}
+		gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, mask, gl.NEAREST)
+	}
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, uint32(prevRead))
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, uint32(prevDraw))
+}
+
+// renderTargetColorFormat picks a pixel format and type that are valid with the internal format
+func renderTargetColorFormat(internalFormat int32) textureFormat {
+	format := uint32(internalFormat)
+	switch internalFormat {
+	case gl.RGBA32F:
+		return uncompressedFormat(format, gl.RGBA, gl.FLOAT, 16)
+	case gl.RGBA16F:
+		return uncompressedFormat(format, gl.RGBA, gl.FLOAT, 8)
+	case gl.RGB32F:
+		return uncompressedFormat(format, gl.RGB, gl.FLOAT, 12)
+	case gl.RGB16F:
+		return uncompressedFormat(format, gl.RGB, gl.FLOAT, 6)
+	case gl.R11F_G11F_B10F:
+		return uncompressedFormat(format, gl.RGB, gl.FLOAT, 4)
+	case gl.RG32F:
+		return uncompressedFormat(format, gl.RG, gl.FLOAT, 8)
+	case gl.RG16F:
+		return uncompressedFormat(format, gl.RG, gl.FLOAT, 4)
+	case gl.R32F:
+		return uncompressedFormat(format, gl.RED, gl.FLOAT, 4)
+	case gl.R16F:
+		return uncompressedFormat(format, gl.RED, gl.FLOAT, 2)
+	case gl.RG8:
+		return uncompressedFormat(format, gl.RG, gl.UNSIGNED_BYTE, 2)
+	case gl.R8:
+		return uncompressedFormat(format, gl.RED, gl.UNSIGNED_BYTE, 1)
+	}
+	return uncompressedFormat(format, gl.RGBA, gl.UNSIGNED_BYTE, 4)
+}
+
+// allocRenderTexture (re)allocates the storage of an attachment texture, keeping its id
+func allocRenderTexture(tex *Texture, format textureFormat, width, height int) {
+	tex.format = format
+	tex.width = width
+	tex.height = height
+	tex.memory = int64(format.levelSize(width, height))
+	tex.options = TextureOptions{
+		MinFilter: gl.LINEAR,
+		MagFilter: gl.LINEAR,
+		WrapS:     gl.CLAMP_TO_EDGE,
+		WrapT:     gl.CLAMP_TO_EDGE,
+		WrapR:     gl.CLAMP_TO_EDGE,
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, tex.glId)
+	gl.TexImage2D(
+		gl.TEXTURE_2D, 0, int32(format.InternalFormat),
+		int32(width), int32(height), 0,
+		format.Format, format.Type, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+func checkFramebuffer() error {
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		return fmt.Errorf("Framebuffer is incomplete, status 0x%X", status)
+	}
+	return nil
+}
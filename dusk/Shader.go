@@ -5,15 +5,78 @@ import (
 	"strings"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
+	gl43 "github.com/go-gl/gl/v4.3-core/gl"
 )
 
+const (
+	VERTEX_BARRIER         = gl43.VERTEX_ATTRIB_ARRAY_BARRIER_BIT
+	ELEMENT_BARRIER        = gl43.ELEMENT_ARRAY_BARRIER_BIT
+	UNIFORM_BARRIER        = gl43.UNIFORM_BARRIER_BIT
+	TEXTURE_FETCH_BARRIER  = gl43.TEXTURE_FETCH_BARRIER_BIT
+	IMAGE_ACCESS_BARRIER   = gl43.SHADER_IMAGE_ACCESS_BARRIER_BIT
+	COMMAND_BARRIER        = gl43.COMMAND_BARRIER_BIT
+	BUFFER_UPDATE_BARRIER  = gl43.BUFFER_UPDATE_BARRIER_BIT
+	FRAMEBUFFER_BARRIER    = gl43.FRAMEBUFFER_BARRIER_BIT
+	SHADER_STORAGE_BARRIER = gl43.SHADER_STORAGE_BARRIER_BIT
+	ALL_BARRIERS           = gl43.ALL_BARRIER_BITS
+)
+
+// Shader types by filename suffix
+var shaderSuffixes = map[string]uint32{
+	".vs.glsl":  gl.VERTEX_SHADER,
+	".tcs.glsl": gl.TESS_CONTROL_SHADER,
+	".tes.glsl": gl.TESS_EVALUATION_SHADER,
+	".gs.glsl":  gl.GEOMETRY_SHADER,
+	".fs.glsl":  gl.FRAGMENT_SHADER,
+	".cs.glsl":  gl43.COMPUTE_SHADER,
+}
+
+// ShaderError holds the info log of a shader that failed to compile or link
+type ShaderError struct {
+	Filenames []string
+	Log       string
+	Linking   bool
+}
+
+func (err *ShaderError) Error() string {
+	if err.Linking {
+		return fmt.Sprintf("Failed to link shader program %v: %v", err.Filenames, err.Log)
+	}
+	return fmt.Sprintf("Failed to compile shader '%v': %v", err.Filenames[0], err.Log)
+}
+
 type Shader struct {
-	glId uint32
+	glId        uint32
+	tessellated bool
+	compute     bool
 }
 
 func NewShader(app *App, filenames ...string) (*Shader, error) {
+	sources := make([]string, len(filenames))
+	for i, f := range filenames {
+		source, err := ReadShaderSource(app, f)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = source
+	}
+
+	return NewShaderFromSources(app, filenames, sources)
+}
+
+// NewShaderFromSources builds a program from source held in memory, the filenames choose the
+// shader types and are used in error messages
+func NewShaderFromSources(app *App, filenames []string, sources []string) (*Shader, error) {
+	if len(filenames) != len(sources) {
+		return nil, fmt.Errorf("Expected %v shader sources, got %v", len(filenames), len(sources))
+	}
+
 	glProgId := gl.CreateProgram()
 
+	shader := &Shader{
+		glId: glProgId,
+	}
+
 	glIds := []uint32{}
 	defer func() {
 		for _, glId := range glIds {
@@ -21,16 +84,40 @@ func NewShader(app *App, filenames ...string) (*Shader, error) {
 		}
 	}()
 
-	for _, f := range filenames {
-		glId, err := compileShader(app, f)
+	for i, f := range filenames {
+		shaderType, err := GetShaderType(f)
+		if err != nil {
+			gl.DeleteProgram(glProgId)
+			return nil, err
+		}
+
+		switch shaderType {
+		case gl.TESS_CONTROL_SHADER, gl.TESS_EVALUATION_SHADER:
+			shader.tessellated = true
+		case gl43.COMPUTE_SHADER:
+			if !app.SupportsCompute() {
+				gl.DeleteProgram(glProgId)
+				return nil, fmt.Errorf("Compute shader '%v' requires OpenGL 4.3, have %v.%v",
+					f, app.glMajorVersion, app.glMinorVersion)
+			}
+			shader.compute = true
+		}
+
+		glId, err := compileShader(f, shaderType, sources[i])
 		if err != nil {
-            gl.DeleteProgram(glProgId)
+			gl.DeleteProgram(glProgId)
 			return nil, err
 		}
 
+		glIds = append(glIds, glId)
 		gl.AttachShader(glProgId, glId)
 	}
 
+	if shader.compute && len(filenames) > 1 {
+		gl.DeleteProgram(glProgId)
+		return nil, fmt.Errorf("Compute shaders cannot be linked with other stages")
+	}
+
 	gl.LinkProgram(glProgId)
 
 	var status int32
@@ -42,11 +129,12 @@ func NewShader(app *App, filenames ...string) (*Shader, error) {
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(glProgId, logLength, nil, gl.Str(log))
 
-		return nil, fmt.Errorf("Failed to link shader program: %v", log)
-	}
-
-	shader := &Shader{
-		glId: glProgId,
+		gl.DeleteProgram(glProgId)
+		return nil, &ShaderError{
+			Filenames: filenames,
+			Log:       strings.TrimRight(log, "\x00"),
+			Linking:   true,
+		}
 	}
 
 	return shader, nil
@@ -58,28 +146,64 @@ func (shader *Shader) Cleanup() {
 
 func (shader *Shader) Use() {
 	gl.UseProgram(shader.glId)
+
+	// Models are made of triangles, so each patch is one triangle
+	if shader.tessellated {
+		gl.PatchParameteri(gl.PATCH_VERTICES, 3)
+	}
 }
 
 func (shader *Shader) GetUniformLocation(name string) int32 {
 	return gl.GetUniformLocation(shader.glId, gl.Str(name+"\x00"))
 }
 
-func compileShader(app *App, filename string) (uint32, error) {
-	var shaderType uint32
-	if strings.HasSuffix(filename, ".vs.glsl") {
-		shaderType = gl.VERTEX_SHADER
-	} else if strings.HasSuffix(filename, ".fs.glsl") {
-		shaderType = gl.FRAGMENT_SHADER
-	} else if strings.HasSuffix(filename, ".gs.glsl") {
-		shaderType = gl.GEOMETRY_SHADER
+// IsTessellated returns true if the program has tessellation stages, and must be drawn with gl.PATCHES
+func (shader *Shader) IsTessellated() bool {
+	return shader.tessellated
+}
+
+func (shader *Shader) IsCompute() bool {
+	return shader.compute
+}
+
+// Dispatch runs a compute shader with the given number of work groups
+func (shader *Shader) Dispatch(x, y, z uint32) {
+	if !shader.compute {
+		LogWarn("Dispatch called on a non-compute shader")
+		return
 	}
 
+	shader.Use()
+	gl43.DispatchCompute(x, y, z)
+}
+
+func GetShaderType(filename string) (uint32, error) {
+	for suffix, shaderType := range shaderSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return shaderType, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown shader type '%v'", filename)
+}
+
+// ReadShaderSource loads a shader through the App's AssetFunction and prepares it for compiling
+func ReadShaderSource(app *App, filename string) (string, error) {
 	data, err := app.AssetFunction(filename)
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+
+	// Drivers stop at the first null, and some reject a byte order mark
+	source := strings.TrimPrefix(string(data), "\uFEFF")
+	if i := strings.IndexByte(source, 0); i >= 0 {
+		source = source[:i]
 	}
 
-	source := string(data) + "\x00"
+	return source, nil
+}
+
+func compileShader(filename string, shaderType uint32, source string) (uint32, error) {
+	source += "\x00"
 
 	glId := gl.CreateShader(shaderType)
 
@@ -98,7 +222,11 @@ func compileShader(app *App, filename string) (uint32, error) {
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetShaderInfoLog(glId, logLength, nil, gl.Str(log))
 
-		return glId, fmt.Errorf("Failed to compile shader '%v': %v", filename, log)
+		gl.DeleteShader(glId)
+		return 0, &ShaderError{
+			Filenames: []string{filename},
+			Log:       strings.TrimRight(log, "\x00"),
+		}
 	}
 
 	return glId, nil
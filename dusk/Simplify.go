@@ -0,0 +1,413 @@
+package dusk
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Weight of the planes that keep borders and seams in place, relative to the surface
+const simplifyBorderWeight = 10
+
+// quadric is the symmetric 4x4 matrix of a quadric error metric, summing the squared distances
+// to a set of planes, with the total weight of the planes
+type quadric struct {
+	m      [10]float64
+	weight float64
+}
+
+func planeQuadric(normal mgl32.Vec3, point mgl32.Vec3, weight float64) quadric {
+	a, b, c := float64(normal[0]), float64(normal[1]), float64(normal[2])
+	d := -(a*float64(point[0]) + b*float64(point[1]) + c*float64(point[2]))
+	return quadric{
+		m: [10]float64{
+			a * a * weight, a * b * weight, a * c * weight, a * d * weight,
+			b * b * weight, b * c * weight, b * d * weight,
+			c * c * weight, c * d * weight,
+			d * d * weight,
+		},
+		weight: weight,
+	}
+}
+
+func (q *quadric) add(other quadric) {
+	for i := range q.m {
+		q.m[i] += other.m[i]
+	}
+	q.weight += other.weight
+}
+
+// error returns the weighted average distance of point from the planes
+func (q *quadric) error(point mgl32.Vec3) float64 {
+	if q.weight == 0 {
+		return 0
+	}
+	x, y, z := float64(point[0]), float64(point[1]), float64(point[2])
+	m := q.m
+	e := m[0]*x*x + 2*m[1]*x*y + 2*m[2]*x*z + 2*m[3]*x +
+		m[4]*y*y + 2*m[5]*y*z + 2*m[6]*y +
+		m[7]*z*z + 2*m[8]*z +
+		m[9]
+	return math.Sqrt(math.Abs(e) / q.weight)
+}
+
+type simplifyCollapse struct {
+	From, To int32
+	Cost     float64
+}
+
+// simplifier reduces triangles by collapsing edges between positions. Vertices at the same
+// position, such as on either side of a texture seam, move together. Collapses only move a
+// position onto one of its neighbours, so the result only uses the mesh's existing vertices.
+type simplifier struct {
+	mesh *MeshData
+
+	// Welded positions, and the position of each vertex
+	points   []mgl32.Vec3
+	position []int32
+
+	// Triangles with their submesh and index in the mesh
+	triangles [][3]uint32
+	triSub    []int
+	triIndex  []int
+	alive     []bool
+	quadrics  []quadric
+}
+
+func newSimplifier(mesh *MeshData) *simplifier {
+	s := &simplifier{
+		mesh:     mesh,
+		position: make([]int32, mesh.VertexCount()),
+	}
+
+	welded := map[mgl32.Vec3]int32{}
+	for i, point := range mesh.Positions {
+		p, ok := welded[point]
+		if !ok {
+			p = int32(len(s.points))
+			welded[point] = p
+			s.points = append(s.points, point)
+		}
+		s.position[i] = p
+	}
+
+	for sub, submesh := range mesh.Submeshes {
+		for t := submesh.Start; t+3 <= submesh.Start+submesh.Count; t += 3 {
+			tri := [3]uint32{mesh.Indices[t], mesh.Indices[t+1], mesh.Indices[t+2]}
+			s.triangles = append(s.triangles, tri)
+			s.triSub = append(s.triSub, sub)
+			s.triIndex = append(s.triIndex, t/3)
+			s.alive = append(s.alive, !s.isDegenerate(tri))
+		}
+	}
+
+	// Each position starts with the planes of the triangles around it, weighted by their area
+	s.quadrics = make([]quadric, len(s.points))
+	for t, tri := range s.triangles {
+		if !s.alive[t] {
+			continue
+		}
+		p0, p1, p2 := s.points[s.position[tri[0]]], s.points[s.position[tri[1]]], s.points[s.position[tri[2]]]
+		normal := p1.Sub(p0).Cross(p2.Sub(p0))
+		area := normal.Len() / 2
+		if area == 0 {
+			continue
+		}
+		q := planeQuadric(normal.Normalize(), p0, float64(area))
+		for _, v := range tri {
+			s.quadrics[s.position[v]].add(q)
+		}
+	}
+
+	// Borders and seams get planes along them, so collapses don't pull them out of shape
+	for key, edge := range s.edges() {
+		if !edge.Border {
+			continue
+		}
+		tri := s.triangles[edge.Triangle]
+		p0, p1, p2 := s.points[s.position[tri[0]]], s.points[s.position[tri[1]]], s.points[s.position[tri[2]]]
+		normal := p1.Sub(p0).Cross(p2.Sub(p0))
+		a, b := s.points[key[0]], s.points[key[1]]
+		side := b.Sub(a).Cross(normal)
+		if side.Len() == 0 {
+			continue
+		}
+		q := planeQuadric(side.Normalize(), a, float64(b.Sub(a).LenSqr())*simplifyBorderWeight)
+		s.quadrics[key[0]].add(q)
+		s.quadrics[key[1]].add(q)
+	}
+
+	return s
+}
+
+func (s *simplifier) isDegenerate(tri [3]uint32) bool {
+	p0, p1, p2 := s.position[tri[0]], s.position[tri[1]], s.position[tri[2]]
+	return p0 == p1 || p1 == p2 || p2 == p0
+}
+
+type simplifyEdge struct {
+	Count    int
+	Triangle int
+	From, To uint32
+	Border   bool
+}
+
+// edges returns the edges between positions of the remaining triangles. Edges are borders if
+// they're open, non-manifold, or the vertices or submeshes on either side differ.
+func (s *simplifier) edges() map[[2]int32]*simplifyEdge {
+	edges := map[[2]int32]*simplifyEdge{}
+	for t, tri := range s.triangles {
+		if !s.alive[t] {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			a, b := tri[c], tri[(c+1)%3]
+			if s.position[a] > s.position[b] {
+				a, b = b, a
+			}
+			key := [2]int32{s.position[a], s.position[b]}
+			edge, ok := edges[key]
+			if !ok {
+				edges[key] = &simplifyEdge{Count: 1, Triangle: t, From: a, To: b}
+				continue
+			}
+			edge.Count++
+			if edge.Count > 2 || edge.From != a || edge.To != b || s.triSub[edge.Triangle] != s.triSub[t] {
+				edge.Border = true
+			}
+		}
+	}
+	for _, edge := range edges {
+		if edge.Count == 1 {
+			edge.Border = true
+		}
+	}
+	return edges
+}
+
+// run collapses edges until there are targetTriangles left, or no collapse is below maxError.
+// It returns the largest error of the collapses made.
+func (s *simplifier) run(targetTriangles int, maxError float64) float64 {
+	live := 0
+	for _, alive := range s.alive {
+		if alive {
+			live++
+		}
+	}
+
+	result := 0.0
+	for live > targetTriangles {
+		edges := s.edges()
+
+		// Positions with two border edges can slide along them, those with more are corners
+		borders := make([]int, len(s.points))
+		for key, edge := range edges {
+			if edge.Border {
+				borders[key[0]]++
+				borders[key[1]]++
+			}
+		}
+
+		triangles := make([][]int, len(s.points))
+		for t, tri := range s.triangles {
+			if s.alive[t] {
+				for _, v := range tri {
+					triangles[s.position[v]] = append(triangles[s.position[v]], t)
+				}
+			}
+		}
+
+		collapses := []simplifyCollapse{}
+		for key, edge := range edges {
+			best := simplifyCollapse{Cost: math.Inf(1)}
+			for _, dir := range [2][2]int32{{key[0], key[1]}, {key[1], key[0]}} {
+				if borders[dir[0]] != 0 && (borders[dir[0]] != 2 || !edge.Border) {
+					continue
+				}
+				q := s.quadrics[dir[0]]
+				q.add(s.quadrics[dir[1]])
+				if cost := q.error(s.points[dir[1]]); cost < best.Cost {
+					best = simplifyCollapse{From: dir[0], To: dir[1], Cost: cost}
+				}
+			}
+			if best.Cost <= maxError {
+				collapses = append(collapses, best)
+			}
+		}
+		sort.Slice(collapses, func(i, j int) bool {
+			if collapses[i].Cost != collapses[j].Cost {
+				return collapses[i].Cost < collapses[j].Cost
+			}
+			if collapses[i].From != collapses[j].From {
+				return collapses[i].From < collapses[j].From
+			}
+			return collapses[i].To < collapses[j].To
+		})
+
+		// Each position is only changed once per pass, so the costs stay close to correct
+		locked := make([]bool, len(s.points))
+		done := 0
+		for _, collapse := range collapses {
+			if live <= targetTriangles {
+				break
+			}
+			if locked[collapse.From] || locked[collapse.To] {
+				continue
+			}
+			removed, ok := s.collapse(collapse.From, collapse.To, triangles)
+			if !ok {
+				continue
+			}
+			locked[collapse.From] = true
+			locked[collapse.To] = true
+			triangles[collapse.To] = append(triangles[collapse.To], triangles[collapse.From]...)
+			live -= removed
+			done++
+			result = math.Max(result, collapse.Cost)
+		}
+		if done == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// collapse moves position from onto position to, returning the number of triangles removed. It
+// fails if a triangle would flip, or a vertex at from has no vertex at to to join.
+func (s *simplifier) collapse(from, to int32, triangles [][]int) (int, bool) {
+	// Vertices are matched across the edge by the triangles that share it
+	remap := map[uint32]uint32{}
+	for _, t := range triangles[from] {
+		tri := s.triangles[t]
+		for c := 0; c < 3; c++ {
+			if s.position[tri[c]] != from {
+				continue
+			}
+			for o := 1; o < 3; o++ {
+				if other := tri[(c+o)%3]; s.position[other] == to {
+					remap[tri[c]] = other
+				}
+			}
+		}
+	}
+
+	target := s.points[to]
+	for _, t := range triangles[from] {
+		if !s.alive[t] {
+			continue
+		}
+		tri := s.triangles[t]
+		if _, ok := remap[tri[0]]; !ok && s.position[tri[0]] == from {
+			return 0, false
+		}
+		if _, ok := remap[tri[1]]; !ok && s.position[tri[1]] == from {
+			return 0, false
+		}
+		if _, ok := remap[tri[2]]; !ok && s.position[tri[2]] == from {
+			return 0, false
+		}
+
+		// Triangles on the edge are removed, the rest must keep facing the same way
+		if s.position[tri[0]] == to || s.position[tri[1]] == to || s.position[tri[2]] == to {
+			continue
+		}
+		var before, after [3]mgl32.Vec3
+		for c := 0; c < 3; c++ {
+			before[c] = s.points[s.position[tri[c]]]
+			after[c] = before[c]
+			if s.position[tri[c]] == from {
+				after[c] = target
+			}
+		}
+		n0 := before[1].Sub(before[0]).Cross(before[2].Sub(before[0]))
+		n1 := after[1].Sub(after[0]).Cross(after[2].Sub(after[0]))
+		if n0.Dot(n1) <= 0.2*n0.Len()*n1.Len() {
+			return 0, false
+		}
+	}
+
+	removed := 0
+	for _, t := range triangles[from] {
+		if !s.alive[t] {
+			continue
+		}
+		tri := &s.triangles[t]
+		for c := 0; c < 3; c++ {
+			if s.position[tri[c]] == from {
+				tri[c] = remap[tri[c]]
+			}
+		}
+		if s.isDegenerate(*tri) {
+			s.alive[t] = false
+			removed++
+		}
+	}
+	s.quadrics[to].add(s.quadrics[from])
+	return removed, true
+}
+
+// output returns the remaining triangles as indices and submeshes, in the mesh's submesh order
+func (s *simplifier) output() ([]uint32, []Submesh, []uint32) {
+	mesh := s.mesh
+	indices := []uint32{}
+	submeshes := make([]Submesh, len(mesh.Submeshes))
+	groups := []uint32{}
+
+	t := 0
+	for i, submesh := range mesh.Submeshes {
+		submeshes[i] = submesh
+		submeshes[i].Start = len(indices)
+		for ; t < len(s.triangles) && s.triSub[t] == i; t++ {
+			if !s.alive[t] {
+				continue
+			}
+			indices = append(indices, s.triangles[t][:]...)
+			if len(mesh.SmoothingGroups) > 0 {
+				groups = append(groups, mesh.SmoothingGroups[s.triIndex[t]])
+			}
+		}
+		submeshes[i].Count = len(indices) - submeshes[i].Start
+	}
+	return indices, submeshes, groups
+}
+
+// simplifyIndices returns the indices and submeshes of the mesh reduced to about targetTriangles,
+// with the largest error in the mesh's units
+func (mesh *MeshData) simplifyIndices(targetTriangles int, maxError float32) ([]uint32, []Submesh, []uint32, float32) {
+	s := newSimplifier(mesh)
+	err := s.run(targetTriangles, float64(maxError))
+	indices, submeshes, groups := s.output()
+	return indices, submeshes, groups, float32(err)
+}
+
+// Simplify returns a copy of the mesh reduced to ratio of its triangles using quadric error
+// metrics, or as close as it can get without moving any surface further than maxError. maxError
+// is relative to the radius of the mesh's bounding sphere. Borders, texture seams and the edges
+// between submeshes are kept in place. The copy shares materials, nodes and animations with
+// the mesh.
+func (mesh *MeshData) Simplify(ratio, maxError float32) *MeshData {
+	_, sphere := mesh.Bounds()
+	indices, submeshes, groups, _ := mesh.simplifyIndices(int(float32(mesh.TriangleCount())*ratio), maxError*sphere.Radius)
+
+	simple := *mesh
+	simple.Indices = indices
+	simple.Submeshes = submeshes
+	simple.SmoothingGroups = groups
+
+	// Only keep the vertices still in use
+	remap := []uint32{}
+	newIndices := map[uint32]uint32{}
+	for i, index := range simple.Indices {
+		newIndex, ok := newIndices[index]
+		if !ok {
+			newIndex = uint32(len(remap))
+			newIndices[index] = newIndex
+			remap = append(remap, index)
+		}
+		simple.Indices[i] = newIndex
+	}
+	simple.remapVertices(remap)
+
+	return &simple
+}
@@ -0,0 +1,109 @@
+package dusk
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+const skyboxVertexSource = `#version 330 core
+
+layout(location = 0) in vec3 _Vertex;
+
+uniform mat4 _View;
+uniform mat4 _Proj;
+
+out vec3 p_Direction;
+
+void main() {
+	p_Direction = _Vertex;
+
+	// Force the depth to the far plane, so the skybox is behind everything
+	vec4 position = _Proj * _View * vec4(_Vertex, 1.0);
+	gl_Position = position.xyww;
+}
+`
+
+const skyboxFragmentSource = `#version 330 core
+
+uniform samplerCube _Skybox;
+
+in vec3 p_Direction;
+
+out vec4 o_Color;
+
+void main() {
+	o_Color = texture(_Skybox, p_Direction);
+}
+`
+
+var skyboxVertices = []float32{
+	-1, 1, -1, -1, -1, -1, 1, -1, -1, 1, -1, -1, 1, 1, -1, -1, 1, -1,
+	-1, -1, 1, -1, -1, -1, -1, 1, -1, -1, 1, -1, -1, 1, 1, -1, -1, 1,
+	1, -1, -1, 1, -1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, 1, -1, -1,
+	-1, -1, 1, -1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, 1, -1, -1, 1,
+	-1, 1, -1, 1, 1, -1, 1, 1, 1, 1, 1, 1, -1, 1, 1, -1, 1, -1,
+	-1, -1, -1, -1, -1, 1, 1, -1, -1, 1, -1, -1, -1, -1, 1, 1, -1, 1,
+}
+
+type Skybox struct {
+	Cubemap *Cubemap
+
+	shader *Shader
+	glVao  uint32
+	glVbo  uint32
+}
+
+func NewSkybox(app *App, cubemap *Cubemap) (*Skybox, error) {
+	shader, err := NewShaderFromSources(app,
+		[]string{"skybox.vs.glsl", "skybox.fs.glsl"},
+		[]string{skyboxVertexSource, skyboxFragmentSource},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	skybox := &Skybox{
+		Cubemap: cubemap,
+		shader:  shader,
+	}
+
+	gl.GenVertexArrays(1, &skybox.glVao)
+	gl.BindVertexArray(skybox.glVao)
+	gl.GenBuffers(1, &skybox.glVbo)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, skybox.glVbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(skyboxVertices)*4, gl.Ptr(skyboxVertices), gl.STATIC_DRAW)
+	gl.VertexAttribPointer(VERT_ATTRIB, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	gl.EnableVertexAttribArray(VERT_ATTRIB)
+
+	return skybox, nil
+}
+
+func (skybox *Skybox) Cleanup() {
+	gl.DeleteBuffers(1, &skybox.glVbo)
+	gl.DeleteVertexArrays(1, &skybox.glVao)
+	skybox.shader.Cleanup()
+}
+
+// Render draws the skybox around the camera, the previously used shader is restored afterwards
+func (skybox *Skybox) Render(camera *Camera) {
+	var prevProgram int32
+	gl.GetIntegerv(gl.CURRENT_PROGRAM, &prevProgram)
+
+	// Only keep the rotation of the view, so the skybox never gets closer
+	view := camera.View.Mat3().Mat4()
+
+	skybox.shader.Use()
+	gl.UniformMatrix4fv(skybox.shader.GetUniformLocation("_View"), 1, false, &view[0])
+	gl.UniformMatrix4fv(skybox.shader.GetUniformLocation("_Proj"), 1, false, &camera.Proj[0])
+	gl.Uniform1i(skybox.shader.GetUniformLocation("_Skybox"), 0)
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	skybox.Cubemap.Bind()
+
+	gl.DepthMask(false)
+	gl.BindVertexArray(skybox.glVao)
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(skyboxVertices)/3))
+	gl.DepthMask(true)
+
+	gl.UseProgram(uint32(prevProgram))
+}
@@ -0,0 +1,213 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	STL_HEADER_SIZE   = 80
+	STL_TRIANGLE_SIZE = 50
+)
+
+// LoadSTL reads a .stl file in ASCII or binary format into MeshData. Vertices are welded by
+// position and the mesh is flat shaded, or smoothed up to app.CreaseAngle if it's set.
+// Per-triangle colors in binary files are read into vertex colors.
+func LoadSTL(app *App, filename string) (*MeshData, error) {
+	LogLoad("Mesh '%v'", filename)
+
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	mesh := NewMeshData()
+
+	// Binary files can start with "solid" too, so the size is checked first. Some writers pad
+	// binary files after the last triangle, while the text of an ASCII file read as a triangle
+	// count would need gigabytes of data.
+	isBinary := false
+	if len(data) >= STL_HEADER_SIZE+4 {
+		count := int64(binary.LittleEndian.Uint32(data[STL_HEADER_SIZE:]))
+		isBinary = int64(len(data)) >= STL_HEADER_SIZE+4+count*STL_TRIANGLE_SIZE
+	}
+
+	if isBinary {
+		loadBinarySTL(data, mesh)
+	} else if bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("solid")) {
+		if err := loadASCIISTL(data, mesh); err != nil {
+			return nil, fmt.Errorf("Malformed STL file '%v': %v", filename, err)
+		}
+	} else {
+		return nil, fmt.Errorf("Invalid STL file '%v'", filename)
+	}
+
+	mesh.Materials["default"] = NewMaterialDef()
+	for i := range mesh.Submeshes {
+		mesh.Submeshes[i].Material = "default"
+	}
+
+	// STL only has facet normals, so triangles are flat shaded unless there's a crease angle
+	if app.CreaseAngle == 0 {
+		mesh.SmoothingGroups = make([]uint32, mesh.TriangleCount())
+	}
+	mesh.GenerateNormalsWithCrease(app.CreaseAngle)
+
+	mesh.OptimizeVertexCache()
+
+	return mesh, nil
+}
+
+// stlWelder shares vertices between triangles with the same position and color
+type stlWelder struct {
+	mesh    *MeshData
+	indices map[stlVertex]uint32
+}
+
+type stlVertex struct {
+	Position mgl32.Vec3
+	Color    mgl32.Vec4
+}
+
+func (w *stlWelder) Add(position mgl32.Vec3, color mgl32.Vec4, hasColor bool) {
+	key := stlVertex{position, color}
+	index, ok := w.indices[key]
+	if !ok {
+		index = uint32(len(w.mesh.Positions))
+		w.indices[key] = index
+		w.mesh.Positions = append(w.mesh.Positions, position)
+		if hasColor {
+			w.mesh.Colors = append(w.mesh.Colors, color)
+		}
+	}
+	w.mesh.Indices = append(w.mesh.Indices, index)
+}
+
+// stlColor decodes a 15-bit color from the attribute bytes of a binary triangle. Materialise
+// Magics files have COLOR= in the header, store red in the low bits and clear bit 15 when the
+// triangle has its own color. Other writers, such as VisCAM and SolidView, store blue in the low
+// bits and set bit 15 instead.
+func stlColor(attr uint16, magics bool) (mgl32.Vec4, bool) {
+	r := float32(attr&31) / 31
+	g := float32((attr>>5)&31) / 31
+	b := float32((attr>>10)&31) / 31
+	if magics {
+		return mgl32.Vec4{r, g, b, 1}, attr&0x8000 == 0
+	}
+	return mgl32.Vec4{b, g, r, 1}, attr&0x8000 != 0
+}
+
+func loadBinarySTL(data []byte, mesh *MeshData) {
+	header := data[:STL_HEADER_SIZE]
+	count := int(binary.LittleEndian.Uint32(data[STL_HEADER_SIZE:]))
+	triangles := data[STL_HEADER_SIZE+4:]
+
+	// The default color of Magics files follows COLOR= as RGBA bytes
+	magics := false
+	defaultColor := mgl32.Vec4{1, 1, 1, 1}
+	if i := bytes.Index(header, []byte("COLOR=")); i >= 0 && i+10 <= len(header) {
+		magics = true
+		for c := 0; c < 4; c++ {
+			defaultColor[c] = float32(header[i+6+c]) / 255
+		}
+	}
+
+	// Colors are only kept if some triangle has one
+	hasColor := false
+	for t := 0; t < count; t++ {
+		attr := binary.LittleEndian.Uint16(triangles[t*STL_TRIANGLE_SIZE+48:])
+		if _, ok := stlColor(attr, magics); ok {
+			hasColor = true
+			break
+		}
+	}
+
+	welder := &stlWelder{mesh: mesh, indices: map[stlVertex]uint32{}}
+	for t := 0; t < count; t++ {
+		tri := triangles[t*STL_TRIANGLE_SIZE:]
+
+		color := defaultColor
+		if hasColor {
+			if c, ok := stlColor(binary.LittleEndian.Uint16(tri[48:]), magics); ok {
+				color = c
+			}
+		}
+
+		// Skip the facet normal
+		for v := 0; v < 3; v++ {
+			var position mgl32.Vec3
+			for c := range position {
+				bits := binary.LittleEndian.Uint32(tri[12+v*12+c*4:])
+				position[c] = math.Float32frombits(bits)
+			}
+			welder.Add(position, color, hasColor)
+		}
+	}
+
+	mesh.Submeshes = append(mesh.Submeshes, Submesh{
+		Name:  "default",
+		Count: len(mesh.Indices),
+	})
+}
+
+// loadASCIISTL reads each solid of an ASCII file as a submesh
+func loadASCIISTL(data []byte, mesh *MeshData) error {
+	welder := &stlWelder{mesh: mesh, indices: map[stlVertex]uint32{}}
+	white := mgl32.Vec4{1, 1, 1, 1}
+
+	var tris triangulator
+	loop := []mgl32.Vec3{}
+	name := ""
+	start := 0
+
+	endSolid := func() {
+		if name == "" {
+			name = "default"
+		}
+		if len(mesh.Indices) > start {
+			mesh.Submeshes = append(mesh.Submeshes, Submesh{
+				Name:  name,
+				Start: start,
+				Count: len(mesh.Indices) - start,
+			})
+		}
+		start = len(mesh.Indices)
+		name = ""
+	}
+
+	scanner := newObjScanner(data)
+	for scanner.Scan() {
+		switch string(scanner.Token()) {
+		case "solid":
+			name = scanner.Rest()
+		case "outer":
+			loop = loop[:0]
+		case "vertex":
+			var position mgl32.Vec3
+			if err := scanner.Floats(position[:], 3); err != nil {
+				return fmt.Errorf("line %v: %v", scanner.LineNum(), err)
+			}
+			loop = append(loop, position)
+		case "endloop":
+			for _, tri := range tris.Triangulate(loop) {
+				for _, v := range tri {
+					welder.Add(loop[v], white, false)
+				}
+			}
+		case "endsolid":
+			endSolid()
+		}
+	}
+
+	// Some writers leave out the final endsolid
+	endSolid()
+
+	if len(mesh.Indices) == 0 {
+		return fmt.Errorf("no facets")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// stlFile returns a binary STL file of a square made from two triangles, with header at its start
+// and padding bytes after the last triangle
+func stlFile(header string, padding int) []byte {
+	square := [][3][3]float32{
+		{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}},
+		{{0, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+	}
+
+	var data bytes.Buffer
+	var head [STL_HEADER_SIZE]byte
+	copy(head[:], header)
+	data.Write(head[:])
+	binary.Write(&data, binary.LittleEndian, uint32(len(square)))
+	for _, tri := range square {
+		binary.Write(&data, binary.LittleEndian, [3]float32{0, 0, 1})
+		binary.Write(&data, binary.LittleEndian, tri)
+		binary.Write(&data, binary.LittleEndian, uint16(0))
+	}
+	data.Write(make([]byte, padding))
+	return data.Bytes()
+}
+
+func TestLoadSTL(t *testing.T) {
+	quietLogs(t)
+
+	tests := []struct {
+		Name      string
+		Data      []byte
+		Triangles int
+		Error     bool
+	}{
+		{"Binary", stlFile("binary", 0), 2, false},
+		{"Binary starting with solid", stlFile("solid square", 0), 2, false},
+		{"Binary with padding", stlFile("solid square", 13), 2, false},
+		{"Truncated binary", stlFile("binary", 0)[:STL_HEADER_SIZE+4+STL_TRIANGLE_SIZE], 0, true},
+		{"Truncated binary starting with solid", stlFile("solid square", 0)[:STL_HEADER_SIZE+4+STL_TRIANGLE_SIZE], 0, true},
+		{"ASCII", []byte(`solid square
+facet normal 0 0 1
+  outer loop
+    vertex 0 0 0
+    vertex 1 0 0
+    vertex 1 1 0
+    vertex 0 1 0
+  endloop
+endfacet
+endsolid square
+`), 2, false},
+		{"ASCII without facets", []byte("solid square\nendsolid square\n"), 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			app := newMemoryApp(map[string][]byte{"square.stl": test.Data})
+			mesh, err := LoadSTL(app, "square.stl")
+			if test.Error {
+				if err == nil {
+					t.Errorf("Loaded a malformed file with %v triangles", mesh.TriangleCount())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mesh.TriangleCount() != test.Triangles || mesh.VertexCount() != 4 {
+				t.Errorf("Got %v triangles and %v vertices, expected %v and 4", mesh.TriangleCount(), mesh.VertexCount(), test.Triangles)
+			}
+		})
+	}
+}
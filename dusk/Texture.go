@@ -1,62 +1,377 @@
 package dusk
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"image"
 	"image/draw"
 	_ "image/jpeg"
 	_ "image/png"
+	"path"
+	"strings"
+	"unsafe"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 )
 
+// From EXT_texture_filter_anisotropic, which is not part of the 4.1 core profile
+const (
+	TEXTURE_MAX_ANISOTROPY     = 0x84FE
+	MAX_TEXTURE_MAX_ANISOTROPY = 0x84FF
+)
+
+type TextureOptions struct {
+	MinFilter  int32
+	MagFilter  int32
+	WrapS      int32
+	WrapT      int32
+	WrapR      int32
+	Anisotropy float32
+	Mipmaps    bool
+	SRGB       bool
+}
+
+func DefaultTextureOptions() TextureOptions {
+	return TextureOptions{
+		MinFilter:  gl.LINEAR_MIPMAP_LINEAR,
+		MagFilter:  gl.LINEAR,
+		WrapS:      gl.REPEAT,
+		WrapT:      gl.REPEAT,
+		WrapR:      gl.REPEAT,
+		Anisotropy: 1.0,
+		Mipmaps:    true,
+		SRGB:       false,
+	}
+}
+
+// SetWrap sets the wrap mode for all axes
+func (opts *TextureOptions) SetWrap(wrap int32) {
+	opts.WrapS = wrap
+	opts.WrapT = wrap
+	opts.WrapR = wrap
+}
+
+func (opts TextureOptions) internalFormat() int32 {
+	if opts.SRGB {
+		return gl.SRGB8_ALPHA8
+	}
+	return gl.RGBA8
+}
+
+// apply sets the sampler state for the texture bound to target
+func (opts TextureOptions) apply(app *App, target uint32) {
+	minFilter := opts.MinFilter
+
+	// Without mipmaps the texture would be incomplete, so fall back to the base level
+	if !opts.Mipmaps {
+		switch minFilter {
+		case gl.NEAREST_MIPMAP_NEAREST, gl.NEAREST_MIPMAP_LINEAR:
+			minFilter = gl.NEAREST
+		case gl.LINEAR_MIPMAP_NEAREST, gl.LINEAR_MIPMAP_LINEAR:
+			minFilter = gl.LINEAR
+		}
+	}
+
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, minFilter)
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, opts.MagFilter)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, opts.WrapS)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, opts.WrapT)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_R, opts.WrapR)
+
+	if app.maxAnisotropy > 0 && opts.Anisotropy >= 1.0 {
+		anisotropy := opts.Anisotropy
+		if anisotropy > app.maxAnisotropy {
+			anisotropy = app.maxAnisotropy
+		}
+		gl.TexParameterf(target, TEXTURE_MAX_ANISOTROPY, anisotropy)
+	}
+}
+
 type Texture struct {
-	glId uint32
+	glId    uint32
+	options TextureOptions
+	format  textureFormat
+	width   int
+	height  int
+	memory  int64
+
+	// Set for textures loaded by a TextureStreamer
+	stream *textureStream
 }
 
 func NewTexture(app *App, filename string) (*Texture, error) {
-	LogLoad("Texture '%v'", filename)
+	return NewTextureWithOptions(app, filename, app.TextureOptions)
+}
+
+// NewTextureWithOptions loads a PNG or JPEG image, a DDS, KTX or KTX2 container, or a Radiance HDR
+// image, containers are uploaded in their stored format along with their mipmaps
+func NewTextureWithOptions(app *App, filename string, opts TextureOptions) (*Texture, error) {
 	if filename == "" {
 		return nil, fmt.Errorf("Filename cannot be empty")
 	}
 
+	if app.TextureStreamer != nil {
+		return app.TextureStreamer.Load(filename, opts)
+	}
+
+	LogLoad("Texture '%v'", filename)
+
+	texData, err := loadTextureData(app, filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load texture '%v': %v", filename, err)
+	}
+
+	return newTextureFromData(app, texData, opts)
+}
+
+// NewTextureFromImage uploads an image that is already in memory
+func NewTextureFromImage(app *App, img image.Image, opts TextureOptions) (*Texture, error) {
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) || rgba.Stride != rgba.Rect.Size().X*4 {
+		rgba = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+
+	return newTextureFromData(app, &textureData{
+		Format: formatRGBA8,
+		Width:  rgba.Rect.Size().X,
+		Height: rgba.Rect.Size().Y,
+		Levels: [][]byte{rgba.Pix},
+	}, opts)
+}
+
+// NewTextureFromMemory is NewTextureWithOptions for a file that is already in memory, e.g. one
+// embedded in a model. The name's extension selects the format.
+func NewTextureFromMemory(app *App, name string, data []byte, opts TextureOptions) (*Texture, error) {
+	LogLoad("Texture '%v'", name)
+
+	texData, err := parseTextureData(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load texture '%v': %v", name, err)
+	}
+
+	return newTextureFromData(app, texData, opts)
+}
+
+func loadTextureData(app *App, filename string) (*textureData, error) {
 	data, err := app.AssetFunction(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	img, _, err := image.Decode(bytes.NewReader(data))
+	return parseTextureData(filename, data)
+}
+
+func parseTextureData(filename string, data []byte) (*textureData, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".dds":
+		return parseDDS(data)
+	case ".ktx":
+		return parseKTX(data)
+	case ".ktx2":
+		return parseKTX2(data)
+	case ".hdr":
+		return parseHDR(data)
+	}
+
+	rgba, err := decodeImageRGBA(data)
 	if err != nil {
 		return nil, err
 	}
 
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return nil, fmt.Errorf("unsupported stride")
+	return &textureData{
+		Format: formatRGBA8,
+		Width:  rgba.Rect.Size().X,
+		Height: rgba.Rect.Size().Y,
+		Levels: [][]byte{rgba.Pix},
+	}, nil
+}
+
+// parseTextureSize reads only the width and height from an image's header
+func parseTextureSize(filename string, data []byte) (int, int, error) {
+	le := binary.LittleEndian
+	width, height := 0, 0
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".dds":
+		if len(data) < 4+ddsHeaderSize || string(data[:4]) != "DDS " {
+			return 0, 0, fmt.Errorf("Invalid DDS header")
+		}
+		height = int(le.Uint32(data[4+8:]))
+		width = int(le.Uint32(data[4+12:]))
+	case ".ktx":
+		if len(data) < 64 || !bytes.Equal(data[:12], ktx1Identifier) {
+			return 0, 0, fmt.Errorf("Invalid KTX header")
+		}
+		var order binary.ByteOrder = le
+		if order.Uint32(data[12:]) != 0x04030201 {
+			order = binary.BigEndian
+		}
+		width = int(order.Uint32(data[36:]))
+		height = maxInt(1, int(order.Uint32(data[40:])))
+	case ".ktx2":
+		if len(data) < 80 || !bytes.Equal(data[:12], ktx2Identifier) {
+			return 0, 0, fmt.Errorf("Invalid KTX2 header")
+		}
+		width = int(le.Uint32(data[20:]))
+		height = maxInt(1, int(le.Uint32(data[24:])))
+	case ".hdr":
+		_, w, h, err := readHDRHeader(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return 0, 0, err
+		}
+		width, height = w, h
+	default:
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return 0, 0, err
+		}
+		width, height = config.Width, config.Height
+	}
+
+	if err := checkTextureSize(width, height); err != nil {
+		return 0, 0, err
+	}
+	return width, height, nil
+}
+
+func newTextureFromData(app *App, texData *textureData, opts TextureOptions) (*Texture, error) {
+	format := texData.Format
+	if opts.SRGB {
+		format = format.withSRGB()
+	}
+
+	if !format.supported(app) {
+		return nil, fmt.Errorf("Texture format 0x%X is not supported by this context", format.InternalFormat)
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
 	var glId uint32
 	gl.GenTextures(1, &glId)
 	gl.ActiveTexture(gl.TEXTURE0)
 	gl.BindTexture(gl.TEXTURE_2D, glId)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexImage2D(
-		gl.TEXTURE_2D, 0, gl.RGBA,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
-		0, gl.RGBA, gl.UNSIGNED_BYTE,
-		gl.Ptr(rgba.Pix))
+
+	memory := int64(0)
+	for i, level := range texData.Levels {
+		if len(level) == 0 {
+			gl.DeleteTextures(1, &glId)
+			return nil, fmt.Errorf("Empty mipmap level %v", i)
+		}
+
+		width, height := texData.levelSize(i)
+		uploadTextureLevel(format, i, width, height, level)
+		memory += int64(len(level))
+	}
+
+	// Use the prebuilt mipmaps if there are any, compressed textures can't generate their own
+	if len(texData.Levels) > 1 {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, int32(len(texData.Levels)-1))
+		opts.Mipmaps = true
+	} else if opts.Mipmaps && format.Compressed {
+		opts.Mipmaps = false
+	} else if opts.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+		memory = memory * 4 / 3
+	}
+	opts.apply(app, gl.TEXTURE_2D)
 
 	return &Texture{
-		glId: glId,
+		glId:    glId,
+		options: opts,
+		format:  format,
+		width:   texData.Width,
+		height:  texData.Height,
+		memory:  memory,
 	}, nil
 }
 
+// uploadTextureLevel defines a mipmap level of the bound texture, data may be nil to only allocate it
+func uploadTextureLevel(format textureFormat, level, width, height int, data []byte) {
+	var ptr unsafe.Pointer
+	if len(data) > 0 {
+		ptr = gl.Ptr(data)
+	}
+
+	if format.Compressed {
+		gl.CompressedTexImage2D(
+			gl.TEXTURE_2D, int32(level), format.InternalFormat,
+			int32(width), int32(height), 0,
+			int32(format.levelSize(width, height)), ptr)
+	} else {
+		gl.TexImage2D(
+			gl.TEXTURE_2D, int32(level), int32(format.InternalFormat),
+			int32(width), int32(height), 0,
+			format.Format, format.Type, ptr)
+	}
+}
+
+func (tex *Texture) Cleanup() {
+	if tex.stream != nil {
+		tex.stream.streamer.remove(tex)
+		return
+	}
+	gl.DeleteTextures(1, &tex.glId)
+}
+
+func (tex *Texture) Size() (int, int) {
+	return tex.width, tex.height
+}
+
+// MemorySize returns the approximate number of bytes the texture uses on the GPU
+func (tex *Texture) MemorySize() int64 {
+	return tex.memory
+}
+
+func (tex *Texture) Options() TextureOptions {
+	return tex.options
+}
+
+// SetOptions changes the sampler state, the internal format cannot be changed after loading
+func (tex *Texture) SetOptions(app *App, opts TextureOptions) {
+	opts.SRGB = tex.options.SRGB
+
+	gl.BindTexture(gl.TEXTURE_2D, tex.glId)
+	if opts.Mipmaps && !tex.options.Mipmaps {
+		if tex.format.Compressed {
+			opts.Mipmaps = false
+		} else {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+		}
+	}
+	opts.apply(app, gl.TEXTURE_2D)
+
+	tex.options = opts
+}
+
 func (tex *Texture) Bind() {
+	if tex.stream != nil {
+		tex.stream.touch(tex)
+	}
 	gl.BindTexture(gl.TEXTURE_2D, tex.glId)
 }
+
+// loadImageRGBA decodes an image through the App's AssetFunction and converts it to RGBA
+func loadImageRGBA(app *App, filename string) (*image.RGBA, error) {
+	data, err := app.AssetFunction(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeImageRGBA(data)
+}
+
+func decodeImageRGBA(data []byte) (*image.RGBA, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	if rgba.Stride != rgba.Rect.Size().X*4 {
+		return nil, fmt.Errorf("unsupported stride")
+	}
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	return rgba, nil
+}
@@ -0,0 +1,149 @@
+package dusk
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	ddsHeaderSize      = 124
+	ddsFlagMipmapCount = 0x20000
+	ddsPixelFourCC     = 0x4
+	ddsPixelRGB        = 0x40
+	ddsCaps2Cubemap    = 0x200
+	ddsCaps2Volume     = 0x200000
+)
+
+// DXGI_FORMAT values from the DX10 extended header
+var ddsDxgiFormats = map[uint32]textureFormat{
+	2:  formatRGBA32F,
+	10: formatRGBA16F,
+	28: formatRGBA8,
+	29: formatSRGBA8,
+	71: formatBC1A,
+	72: formatBC1ASRGB,
+	74: formatBC2,
+	75: formatBC2SRGB,
+	77: formatBC3,
+	78: formatBC3SRGB,
+	80: formatBC4,
+	81: formatBC4S,
+	83: formatBC5,
+	84: formatBC5S,
+	87: formatBGRA8,
+	91: formatSBGRA8,
+	95: formatBC6HU,
+	96: formatBC6HS,
+	98: formatBC7,
+	99: formatBC7SRGB,
+}
+
+var ddsFourCCFormats = map[string]textureFormat{
+	"DXT1": formatBC1A,
+	"DXT2": formatBC2,
+	"DXT3": formatBC2,
+	"DXT4": formatBC3,
+	"DXT5": formatBC3,
+	"ATI1": formatBC4,
+	"BC4U": formatBC4,
+	"BC4S": formatBC4S,
+	"ATI2": formatBC5,
+	"BC5U": formatBC5,
+	"BC5S": formatBC5S,
+	"ETC ": formatETC2,
+	"ETC1": formatETC2,
+	"ETC2": formatETC2,
+	"ETCA": formatETC2A8,
+}
+
+// parseDDS reads a DirectDraw Surface, with an optional DX10 header and mipmap chain
+func parseDDS(data []byte) (*textureData, error) {
+	if len(data) < 4+ddsHeaderSize || string(data[:4]) != "DDS " {
+		return nil, fmt.Errorf("Invalid DDS header")
+	}
+
+	le := binary.LittleEndian
+	header := data[4 : 4+ddsHeaderSize]
+	body := data[4+ddsHeaderSize:]
+
+	flags := le.Uint32(header[4:])
+	height := int(le.Uint32(header[8:]))
+	width := int(le.Uint32(header[12:]))
+	mipCount := int(le.Uint32(header[24:]))
+	pixelFlags := le.Uint32(header[76:])
+	fourCC := string(header[80:84])
+	bitCount := le.Uint32(header[84:])
+	redMask := le.Uint32(header[88:])
+	caps2 := le.Uint32(header[108:])
+
+	if caps2&(ddsCaps2Cubemap|ddsCaps2Volume) != 0 {
+		return nil, fmt.Errorf("DDS cubemaps and volume textures are not supported")
+	}
+
+	if flags&ddsFlagMipmapCount == 0 || mipCount == 0 {
+		mipCount = 1
+	}
+	if err := checkTextureSize(width, height); err != nil {
+		return nil, err
+	}
+	if maxCount := maxMipCount(width, height); mipCount > maxCount {
+		mipCount = maxCount
+	}
+
+	var format textureFormat
+	var ok bool
+
+	switch {
+	case pixelFlags&ddsPixelFourCC != 0 && fourCC == "DX10":
+		if len(body) < 20 {
+			return nil, fmt.Errorf("Invalid DDS DX10 header")
+		}
+		dxgiFormat := le.Uint32(body[0:])
+		arraySize := le.Uint32(body[12:])
+		if arraySize > 1 {
+			return nil, fmt.Errorf("DDS texture arrays are not supported")
+		}
+		body = body[20:]
+
+		format, ok = ddsDxgiFormats[dxgiFormat]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported DXGI format %v", dxgiFormat)
+		}
+
+	case pixelFlags&ddsPixelFourCC != 0:
+		// D3DFMT values stored in place of a FourCC
+		switch le.Uint32(header[80:]) {
+		case 113:
+			format, ok = formatRGBA16F, true
+		case 116:
+			format, ok = formatRGBA32F, true
+		default:
+			format, ok = ddsFourCCFormats[fourCC]
+		}
+		if !ok {
+			return nil, fmt.Errorf("Unsupported DDS FourCC '%v'", fourCC)
+		}
+
+	case pixelFlags&ddsPixelRGB != 0 && bitCount == 32:
+		if redMask == 0x000000ff {
+			format = formatRGBA8
+		} else {
+			format = formatBGRA8
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported DDS pixel format")
+	}
+
+	levels, err := splitLevels(format, width, height, mipCount, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &textureData{
+		Format: format,
+		Width:  width,
+		Height: height,
+		Levels: levels,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// ddsFile builds a 32 bit RGBA DDS file with mipCount levels taken from body
+func ddsFile(width, height, mipCount uint32, body []byte) []byte {
+	header := make([]uint32, ddsHeaderSize/4)
+	header[0] = ddsHeaderSize
+	header[1] = ddsFlagMipmapCount
+	header[2] = height
+	header[3] = width
+	header[6] = mipCount
+	header[18] = 32
+	header[19] = ddsPixelRGB
+	header[21] = 32
+	header[22] = 0x000000ff
+
+	var buf bytes.Buffer
+	buf.WriteString("DDS ")
+	binary.Write(&buf, binary.LittleEndian, header)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func TestParseDDS(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Data   []byte
+		Levels int
+		Error  string
+	}{
+		{"RGBA8", ddsFile(4, 4, 1, make([]byte, 64)), 1, ""},
+		{"Mipmaps", ddsFile(4, 4, 3, make([]byte, 64+16+4)), 3, ""},
+		{"Extra mipmaps", ddsFile(4, 4, 0xFFFFFFFF, make([]byte, 64+16+4)), 3, ""},
+		{"Short RGBA8", ddsFile(4, 4, 1, make([]byte, 60)), 0, "Truncated"},
+		{"Huge size", ddsFile(0xFFFFFFF0, 0xFFFFFFFF, 1, make([]byte, 64)), 0, "Invalid texture size"},
+		{"Zero width", ddsFile(0, 4, 0xFFFFFFFF, nil), 0, "Invalid texture size"},
+	}
+
+	for _, test := range tests {
+		texData, err := parseDDS(test.Data)
+		if test.Error == "" {
+			if err != nil {
+				t.Errorf("%v: %v", test.Name, err)
+			} else if len(texData.Levels) != test.Levels {
+				t.Errorf("%v: got %v levels, expected %v", test.Name, len(texData.Levels), test.Levels)
+			}
+		}
+		if test.Error != "" && (err == nil || !strings.Contains(err.Error(), test.Error)) {
+			t.Errorf("%v: expected error containing '%v', got %v", test.Name, test.Error, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package dusk
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Compressed formats, not all of which are part of the 4.1 core profile
+const (
+	COMPRESSED_RGB_S3TC_DXT1             = 0x83F0
+	COMPRESSED_RGBA_S3TC_DXT1            = 0x83F1
+	COMPRESSED_RGBA_S3TC_DXT3            = 0x83F2
+	COMPRESSED_RGBA_S3TC_DXT5            = 0x83F3
+	COMPRESSED_SRGB_S3TC_DXT1            = 0x8C4C
+	COMPRESSED_SRGB_ALPHA_S3TC_DXT1      = 0x8C4D
+	COMPRESSED_SRGB_ALPHA_S3TC_DXT3      = 0x8C4E
+	COMPRESSED_SRGB_ALPHA_S3TC_DXT5      = 0x8C4F
+	COMPRESSED_RED_RGTC1                 = 0x8DBB
+	COMPRESSED_SIGNED_RED_RGTC1          = 0x8DBC
+	COMPRESSED_RG_RGTC2                  = 0x8DBD
+	COMPRESSED_SIGNED_RG_RGTC2           = 0x8DBE
+	COMPRESSED_RGBA_BPTC_UNORM           = 0x8E8C
+	COMPRESSED_SRGB_ALPHA_BPTC_UNORM     = 0x8E8D
+	COMPRESSED_RGB_BPTC_SIGNED_FLOAT     = 0x8E8E
+	COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT   = 0x8E8F
+	COMPRESSED_R11_EAC                   = 0x9270
+	COMPRESSED_SIGNED_R11_EAC            = 0x9271
+	COMPRESSED_RG11_EAC                  = 0x9272
+	COMPRESSED_SIGNED_RG11_EAC           = 0x9273
+	COMPRESSED_RGB8_ETC2                 = 0x9274
+	COMPRESSED_SRGB8_ETC2                = 0x9275
+	COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1  = 0x9276
+	COMPRESSED_SRGB8_PUNCHTHROUGH_ALPHA1 = 0x9277
+	COMPRESSED_RGBA8_ETC2_EAC            = 0x9278
+	COMPRESSED_SRGB8_ALPHA8_ETC2_EAC     = 0x9279
+	COMPRESSED_ETC1_RGB8                 = 0x8D64
+)
+
+type textureFormat struct {
+	InternalFormat uint32
+	Format         uint32
+	Type           uint32
+
+	// Bytes per 4x4 block for compressed formats, or per pixel otherwise
+	BlockBytes int
+	Compressed bool
+
+	// Supported when the context is at least CoreVersion (e.g. 43), or has any of the Extensions
+	CoreVersion int32
+	Extensions  []string
+}
+
+func uncompressedFormat(internalFormat, format, xtype uint32, pixelBytes int) textureFormat {
+	return textureFormat{
+		InternalFormat: internalFormat,
+		Format:         format,
+		Type:           xtype,
+		BlockBytes:     pixelBytes,
+	}
+}
+
+func compressedFormat(internalFormat uint32, blockBytes int, coreVersion int32, extensions ...string) textureFormat {
+	return textureFormat{
+		InternalFormat: internalFormat,
+		BlockBytes:     blockBytes,
+		Compressed:     true,
+		CoreVersion:    coreVersion,
+		Extensions:     extensions,
+	}
+}
+
+var (
+	formatRGBA8      = uncompressedFormat(gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE, 4)
+	formatSRGBA8     = uncompressedFormat(gl.SRGB8_ALPHA8, gl.RGBA, gl.UNSIGNED_BYTE, 4)
+	formatBGRA8      = uncompressedFormat(gl.RGBA8, gl.BGRA, gl.UNSIGNED_BYTE, 4)
+	formatSBGRA8     = uncompressedFormat(gl.SRGB8_ALPHA8, gl.BGRA, gl.UNSIGNED_BYTE, 4)
+	formatRGBA16F    = uncompressedFormat(gl.RGBA16F, gl.RGBA, gl.HALF_FLOAT, 8)
+	formatRGBA32F    = uncompressedFormat(gl.RGBA32F, gl.RGBA, gl.FLOAT, 16)
+	formatRGB16F     = uncompressedFormat(gl.RGB16F, gl.RGB, gl.HALF_FLOAT, 6)
+	formatBC1        = compressedFormat(COMPRESSED_RGB_S3TC_DXT1, 8, 0, "GL_EXT_texture_compression_s3tc")
+	formatBC1A       = compressedFormat(COMPRESSED_RGBA_S3TC_DXT1, 8, 0, "GL_EXT_texture_compression_s3tc")
+	formatBC2        = compressedFormat(COMPRESSED_RGBA_S3TC_DXT3, 16, 0, "GL_EXT_texture_compression_s3tc")
+	formatBC3        = compressedFormat(COMPRESSED_RGBA_S3TC_DXT5, 16, 0, "GL_EXT_texture_compression_s3tc")
+	formatBC1SRGB    = compressedFormat(COMPRESSED_SRGB_S3TC_DXT1, 8, 0, "GL_EXT_texture_sRGB")
+	formatBC1ASRGB   = compressedFormat(COMPRESSED_SRGB_ALPHA_S3TC_DXT1, 8, 0, "GL_EXT_texture_sRGB")
+	formatBC2SRGB    = compressedFormat(COMPRESSED_SRGB_ALPHA_S3TC_DXT3, 16, 0, "GL_EXT_texture_sRGB")
+	formatBC3SRGB    = compressedFormat(COMPRESSED_SRGB_ALPHA_S3TC_DXT5, 16, 0, "GL_EXT_texture_sRGB")
+	formatBC4        = compressedFormat(COMPRESSED_RED_RGTC1, 8, 30)
+	formatBC4S       = compressedFormat(COMPRESSED_SIGNED_RED_RGTC1, 8, 30)
+	formatBC5        = compressedFormat(COMPRESSED_RG_RGTC2, 16, 30)
+	formatBC5S       = compressedFormat(COMPRESSED_SIGNED_RG_RGTC2, 16, 30)
+	formatBC6HU      = compressedFormat(COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT, 16, 42, "GL_ARB_texture_compression_bptc")
+	formatBC6HS      = compressedFormat(COMPRESSED_RGB_BPTC_SIGNED_FLOAT, 16, 42, "GL_ARB_texture_compression_bptc")
+	formatBC7        = compressedFormat(COMPRESSED_RGBA_BPTC_UNORM, 16, 42, "GL_ARB_texture_compression_bptc")
+	formatBC7SRGB    = compressedFormat(COMPRESSED_SRGB_ALPHA_BPTC_UNORM, 16, 42, "GL_ARB_texture_compression_bptc")
+	formatETC2       = compressedFormat(COMPRESSED_RGB8_ETC2, 8, 43, "GL_ARB_ES3_compatibility")
+	formatETC2SRGB   = compressedFormat(COMPRESSED_SRGB8_ETC2, 8, 43, "GL_ARB_ES3_compatibility")
+	formatETC2A1     = compressedFormat(COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1, 8, 43, "GL_ARB_ES3_compatibility")
+	formatETC2A1SRGB = compressedFormat(COMPRESSED_SRGB8_PUNCHTHROUGH_ALPHA1, 8, 43, "GL_ARB_ES3_compatibility")
+	formatETC2A8     = compressedFormat(COMPRESSED_RGBA8_ETC2_EAC, 16, 43, "GL_ARB_ES3_compatibility")
+	formatETC2A8SRGB = compressedFormat(COMPRESSED_SRGB8_ALPHA8_ETC2_EAC, 16, 43, "GL_ARB_ES3_compatibility")
+	formatEACR11     = compressedFormat(COMPRESSED_R11_EAC, 8, 43, "GL_ARB_ES3_compatibility")
+	formatEACR11S    = compressedFormat(COMPRESSED_SIGNED_R11_EAC, 8, 43, "GL_ARB_ES3_compatibility")
+	formatEACRG11    = compressedFormat(COMPRESSED_RG11_EAC, 16, 43, "GL_ARB_ES3_compatibility")
+	formatEACRG11S   = compressedFormat(COMPRESSED_SIGNED_RG11_EAC, 16, 43, "GL_ARB_ES3_compatibility")
+)
+
+// The sRGB variant of each linear format, used when TextureOptions.SRGB is set
+var srgbFormats = map[uint32]textureFormat{
+	gl.RGBA8:                            formatSRGBA8,
+	COMPRESSED_RGB_S3TC_DXT1:            formatBC1SRGB,
+	COMPRESSED_RGBA_S3TC_DXT1:           formatBC1ASRGB,
+	COMPRESSED_RGBA_S3TC_DXT3:           formatBC2SRGB,
+	COMPRESSED_RGBA_S3TC_DXT5:           formatBC3SRGB,
+	COMPRESSED_RGBA_BPTC_UNORM:          formatBC7SRGB,
+	COMPRESSED_RGB8_ETC2:                formatETC2SRGB,
+	COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1: formatETC2A1SRGB,
+	COMPRESSED_RGBA8_ETC2_EAC:           formatETC2A8SRGB,
+}
+
+// withSRGB returns the sRGB variant of the format if one exists
+func (format textureFormat) withSRGB() textureFormat {
+	if srgb, ok := srgbFormats[format.InternalFormat]; ok {
+		if format.Format == gl.BGRA {
+			return formatSBGRA8
+		}
+		return srgb
+	}
+	return format
+}
+
+// Largest width or height accepted from an image file, which keeps level sizes from overflowing
+const MAX_TEXTURE_DIMENSION = 1 << 16
+
+// checkTextureSize returns an error if an image file's dimensions can't be a texture
+func checkTextureSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > MAX_TEXTURE_DIMENSION || height > MAX_TEXTURE_DIMENSION {
+		return fmt.Errorf("Invalid texture size %vx%v", width, height)
+	}
+	return nil
+}
+
+// maxMipCount returns the number of levels in a full mipmap chain, down to 1x1
+func maxMipCount(width, height int) int {
+	return bits.Len(uint(maxInt(width, height)))
+}
+
+// pixelBytes returns the size of a pixel with the given GL format and type, or 0 if unknown
+func pixelBytes(format, xtype uint32) int {
+	// Packed types hold the whole pixel
+	switch xtype {
+	case gl.UNSIGNED_BYTE_3_3_2, gl.UNSIGNED_BYTE_2_3_3_REV:
+		return 1
+	case gl.UNSIGNED_SHORT_5_6_5, gl.UNSIGNED_SHORT_5_6_5_REV,
+		gl.UNSIGNED_SHORT_4_4_4_4, gl.UNSIGNED_SHORT_4_4_4_4_REV,
+		gl.UNSIGNED_SHORT_5_5_5_1, gl.UNSIGNED_SHORT_1_5_5_5_REV:
+		return 2
+	case gl.UNSIGNED_INT_8_8_8_8, gl.UNSIGNED_INT_8_8_8_8_REV,
+		gl.UNSIGNED_INT_10_10_10_2, gl.UNSIGNED_INT_2_10_10_10_REV,
+		gl.UNSIGNED_INT_10F_11F_11F_REV, gl.UNSIGNED_INT_5_9_9_9_REV,
+		gl.UNSIGNED_INT_24_8:
+		return 4
+	}
+
+	size := 0
+	switch xtype {
+	case gl.UNSIGNED_BYTE, gl.BYTE:
+		size = 1
+	case gl.UNSIGNED_SHORT, gl.SHORT, gl.HALF_FLOAT:
+		size = 2
+	case gl.UNSIGNED_INT, gl.INT, gl.FLOAT:
+		size = 4
+	}
+
+	switch format {
+	case gl.RED, gl.RED_INTEGER, gl.DEPTH_COMPONENT:
+		return size
+	case gl.RG, gl.RG_INTEGER:
+		return size * 2
+	case gl.RGB, gl.BGR, gl.RGB_INTEGER, gl.BGR_INTEGER:
+		return size * 3
+	case gl.RGBA, gl.BGRA, gl.RGBA_INTEGER, gl.BGRA_INTEGER:
+		return size * 4
+	}
+	return 0
+}
+
+// levelSize returns the number of bytes in a single mipmap level. Uncompressed rows are padded
+// to 4 bytes, the default unpack alignment.
+func (format textureFormat) levelSize(width, height int) int {
+	if format.Compressed {
+		return ((width + 3) / 4) * ((height + 3) / 4) * format.BlockBytes
+	}
+	return ((width*format.BlockBytes + 3) &^ 3) * height
+}
+
+func (format textureFormat) supported(app *App) bool {
+	if format.CoreVersion == 0 && len(format.Extensions) == 0 {
+		return true
+	}
+	if format.CoreVersion > 0 && app.glMajorVersion*10+app.glMinorVersion >= format.CoreVersion {
+		return true
+	}
+	for _, ext := range format.Extensions {
+		if app.HasExtension(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// textureData holds a decoded image, with all of its mipmap levels, ready to upload
+type textureData struct {
+	Format textureFormat
+	Width  int
+	Height int
+	Levels [][]byte
+}
+
+// levelSize returns the width and height of a mipmap level
+func (texData *textureData) levelSize(level int) (int, int) {
+	return maxInt(1, texData.Width>>uint(level)), maxInt(1, texData.Height>>uint(level))
+}
+
+// splitLevels cuts a mipmap chain stored back to back into individual levels
+func splitLevels(format textureFormat, width, height, count int, data []byte) ([][]byte, error) {
+	levels := [][]byte{}
+	for i := 0; i < count; i++ {
+		size := format.levelSize(width, height)
+		if len(data) < size {
+			return nil, fmt.Errorf("Truncated mipmap level %v", i)
+		}
+		levels = append(levels, data[:size])
+		data = data[size:]
+
+		width = maxInt(1, width/2)
+		height = maxInt(1, height/2)
+	}
+	return levels, nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,182 @@
+package dusk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// parseHDR reads a Radiance RGBE image into half float RGB
+func parseHDR(data []byte) (*textureData, error) {
+	reader := bufio.NewReader(bytes.NewReader(data))
+
+	yDir, width, height, err := readHDRHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every scanline takes at least a few bytes per channel, so a file that's too short for its
+	// size fails before the pixels are allocated
+	minScanline := width * 4
+	if width >= 8 {
+		minScanline = 4 + 4*2*((width+127)/128)
+	}
+	if height*minScanline > len(data) {
+		return nil, fmt.Errorf("Truncated Radiance HDR data")
+	}
+
+	// Rows of 6 byte pixels are padded to the unpack alignment
+	format := formatRGB16F
+	stride := format.levelSize(width, 1)
+	level := make([]byte, stride*height)
+	scanline := make([]byte, width*4)
+
+	for y := 0; y < height; y++ {
+		err = readHDRScanline(reader, scanline, width)
+		if err != nil {
+			return nil, err
+		}
+
+		// -Y is stored top to bottom, matching the other loaders
+		row := y
+		if yDir == "+Y" {
+			row = height - y - 1
+		}
+
+		for x := 0; x < width; x++ {
+			r, g, b, e := scanline[x*4], scanline[x*4+1], scanline[x*4+2], scanline[x*4+3]
+			if e == 0 {
+				continue
+			}
+			f := float32(math.Ldexp(1.0, int(e)-(128+8)))
+			pixel := level[row*stride+x*6:]
+			binary.LittleEndian.PutUint16(pixel[0:], halfFloat((float32(r)+0.5)*f))
+			binary.LittleEndian.PutUint16(pixel[2:], halfFloat((float32(g)+0.5)*f))
+			binary.LittleEndian.PutUint16(pixel[4:], halfFloat((float32(b)+0.5)*f))
+		}
+	}
+
+	return &textureData{
+		Format: format,
+		Width:  width,
+		Height: height,
+		Levels: [][]byte{level},
+	}, nil
+}
+
+// readHDRHeader reads the header and resolution line, returning the direction rows are stored in
+func readHDRHeader(reader *bufio.Reader) (string, int, int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil || !(strings.HasPrefix(line, "#?RADIANCE") || strings.HasPrefix(line, "#?RGBE")) {
+		return "", 0, 0, fmt.Errorf("Invalid Radiance HDR header")
+	}
+
+	// Header variables end with an empty line
+	for {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("Truncated Radiance HDR header")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return "", 0, 0, fmt.Errorf("Unsupported Radiance HDR format '%v'", line[7:])
+		}
+	}
+
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("Truncated Radiance HDR header")
+	}
+
+	var yDir, xDir string
+	var width, height int
+	_, err = fmt.Sscanf(line, "%2s %d %2s %d", &yDir, &height, &xDir, &width)
+	if err != nil || xDir != "+X" || (yDir != "-Y" && yDir != "+Y") {
+		return "", 0, 0, fmt.Errorf("Unsupported Radiance HDR resolution '%v'", strings.TrimSpace(line))
+	}
+	if err := checkTextureSize(width, height); err != nil {
+		return "", 0, 0, err
+	}
+
+	return yDir, width, height, nil
+}
+
+// readHDRScanline reads one scanline in either the flat or the run length encoded format
+func readHDRScanline(reader *bufio.Reader, scanline []byte, width int) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return fmt.Errorf("Truncated Radiance HDR data")
+	}
+
+	// Run length encoded scanlines start with 2, 2 and the width
+	if width < 8 || width > 0x7fff || header[0] != 2 || header[1] != 2 || int(header[2])<<8|int(header[3]) != width {
+		copy(scanline, header)
+		if _, err := io.ReadFull(reader, scanline[4:]); err != nil {
+			return fmt.Errorf("Truncated Radiance HDR data")
+		}
+		return nil
+	}
+
+	// Each channel is encoded separately
+	for channel := 0; channel < 4; channel++ {
+		for x := 0; x < width; {
+			count, err := reader.ReadByte()
+			if err != nil {
+				return fmt.Errorf("Truncated Radiance HDR data")
+			}
+
+			if count > 128 {
+				run := int(count) - 128
+				value, err := reader.ReadByte()
+				if err != nil || x+run > width {
+					return fmt.Errorf("Malformed Radiance HDR data")
+				}
+				for ; run > 0; run-- {
+					scanline[x*4+channel] = value
+					x++
+				}
+			} else {
+				if count == 0 || x+int(count) > width {
+					return fmt.Errorf("Malformed Radiance HDR data")
+				}
+				for n := 0; n < int(count); n++ {
+					value, err := reader.ReadByte()
+					if err != nil {
+						return fmt.Errorf("Truncated Radiance HDR data")
+					}
+					scanline[x*4+channel] = value
+					x++
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// halfFloat converts a float to IEEE 754 half precision, clamping values too large for it
+func halfFloat(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23&0xff) - 127 + 15
+	mantissa := bits & 0x7fffff
+
+	switch {
+	case exp >= 31:
+		return sign | 0x7bff
+	case exp <= 0:
+		// Subnormal, or too small to represent
+		if exp < -10 {
+			return sign
+		}
+		return sign | uint16((mantissa|0x800000)>>uint(14-exp))
+	}
+	return sign | uint16(exp)<<10 | uint16(mantissa>>13)
+}
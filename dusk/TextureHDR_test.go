@@ -0,0 +1,57 @@
+package dusk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHDR(t *testing.T) {
+	header := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n"
+	tests := []struct {
+		Name  string
+		Data  string
+		Error string
+	}{
+		{"Flat", header + "-Y 1 +X 2\n" + "\x80\x80\x80\x81\x80\x80\x80\x81", ""},
+		{"Zero width", header + "-Y 1 +X 0\n", "Invalid texture size"},
+		{"Negative height", header + "-Y -1 +X 4\n", "Invalid texture size"},
+		{"Huge", header + "-Y 65536 +X 65536\n", "Truncated"},
+		{"Short", header + "-Y 2 +X 2\n" + "\x80\x80\x80\x81", "Truncated"},
+	}
+
+	for _, test := range tests {
+		texData, err := parseHDR([]byte(test.Data))
+		if test.Error == "" {
+			if err != nil {
+				t.Errorf("%v: %v", test.Name, err)
+			} else if len(texData.Levels[0]) < texData.Format.levelSize(texData.Width, texData.Height) {
+				t.Errorf("%v: level has %v bytes, expected %v", test.Name, len(texData.Levels[0]), texData.Format.levelSize(texData.Width, texData.Height))
+			}
+		}
+		if test.Error != "" && (err == nil || !strings.Contains(err.Error(), test.Error)) {
+			t.Errorf("%v: expected error containing '%v', got %v", test.Name, test.Error, err)
+		}
+	}
+}
+
+func TestHalfFloat(t *testing.T) {
+	tests := []struct {
+		Value float32
+		Half  uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},
+		{1e10, 0x7bff},
+		{5.9604645e-08, 0x0001},
+		{1e-10, 0x0000},
+	}
+
+	for _, test := range tests {
+		if half := halfFloat(test.Value); half != test.Half {
+			t.Errorf("halfFloat(%v) = 0x%04x, expected 0x%04x", test.Value, half, test.Half)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+var (
+	ktx1Identifier = []byte{0xAB, 'K', 'T', 'X', ' ', '1', '1', 0xBB, '\r', '\n', 0x1A, '\n'}
+	ktx2Identifier = []byte{0xAB, 'K', 'T', 'X', ' ', '2', '0', 0xBB, '\r', '\n', 0x1A, '\n'}
+)
+
+// VkFormat values used by KTX2
+var ktx2VkFormats = map[uint32]textureFormat{
+	37:  formatRGBA8,
+	43:  formatSRGBA8,
+	44:  formatBGRA8,
+	50:  formatSBGRA8,
+	97:  formatRGBA16F,
+	109: formatRGBA32F,
+	131: formatBC1,
+	132: formatBC1SRGB,
+	133: formatBC1A,
+	134: formatBC1ASRGB,
+	135: formatBC2,
+	136: formatBC2SRGB,
+	137: formatBC3,
+	138: formatBC3SRGB,
+	139: formatBC4,
+	140: formatBC4S,
+	141: formatBC5,
+	142: formatBC5S,
+	143: formatBC6HU,
+	144: formatBC6HS,
+	145: formatBC7,
+	146: formatBC7SRGB,
+	147: formatETC2,
+	148: formatETC2SRGB,
+	149: formatETC2A1,
+	150: formatETC2A1SRGB,
+	151: formatETC2A8,
+	152: formatETC2A8SRGB,
+	153: formatEACR11,
+	154: formatEACR11S,
+	155: formatEACRG11,
+	156: formatEACRG11S,
+}
+
+// ktx1Formats maps the glInternalFormat of a KTX1 file to a known format, so support can be checked
+var ktx1Formats = map[uint32]textureFormat{}
+
+func init() {
+	for _, format := range ktx2VkFormats {
+		if format.Compressed {
+			ktx1Formats[format.InternalFormat] = format
+		}
+	}
+	ktx1Formats[COMPRESSED_ETC1_RGB8] = formatETC2
+}
+
+// parseKTX reads a KTX 1.1 file, which stores OpenGL enums directly
+func parseKTX(data []byte) (*textureData, error) {
+	if len(data) < 64 || !bytes.Equal(data[:12], ktx1Identifier) {
+		return nil, fmt.Errorf("Invalid KTX header")
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(data[12:]) != 0x04030201 {
+		order = binary.BigEndian
+	}
+
+	field := func(i int) uint32 {
+		return order.Uint32(data[16+i*4:])
+	}
+
+	glType := field(0)
+	glTypeSize := field(1)
+	glFormat := field(2)
+	glInternalFormat := field(3)
+	width := int(field(5))
+	height := int(field(6))
+	depth := field(7)
+	arrayElements := field(8)
+	faces := field(9)
+	mipCount := int(field(10))
+	keyValueBytes := int(field(11))
+
+	if depth > 0 || arrayElements > 0 || faces > 1 {
+		return nil, fmt.Errorf("KTX arrays, cubemaps and volume textures are not supported")
+	}
+	if mipCount == 0 {
+		mipCount = 1
+	}
+	if height == 0 {
+		height = 1
+	}
+	if err := checkTextureSize(width, height); err != nil {
+		return nil, err
+	}
+	if maxCount := maxMipCount(width, height); mipCount > maxCount {
+		mipCount = maxCount
+	}
+
+	var format textureFormat
+	if glType == 0 {
+		known, ok := ktx1Formats[glInternalFormat]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported KTX compressed format 0x%X", glInternalFormat)
+		}
+		format = known
+	} else {
+		format = uncompressedFormat(glInternalFormat, glFormat, glType, pixelBytes(glFormat, glType))
+		if format.BlockBytes == 0 {
+			return nil, fmt.Errorf("Unsupported KTX format 0x%X with type 0x%X", glFormat, glType)
+		}
+	}
+
+	offset := 64 + keyValueBytes
+	levels := [][]byte{}
+	for i := 0; i < mipCount; i++ {
+		if offset+4 > len(data) {
+			return nil, fmt.Errorf("Truncated KTX mipmap level %v", i)
+		}
+		size := int(order.Uint32(data[offset:]))
+		offset += 4
+		if offset+size > len(data) || size < format.levelSize(maxInt(1, width>>uint(i)), maxInt(1, height>>uint(i))) {
+			return nil, fmt.Errorf("Truncated KTX mipmap level %v", i)
+		}
+
+		level := data[offset : offset+size]
+
+		// Uncompressed data is stored in the file's byte order
+		if order == binary.BigEndian && glTypeSize > 1 {
+			level = swapBytes(level, int(glTypeSize))
+		}
+		levels = append(levels, level)
+
+		// Levels are padded to 4 bytes
+		offset += (size + 3) &^ 3
+	}
+
+	return &textureData{
+		Format: format,
+		Width:  width,
+		Height: height,
+		Levels: levels,
+	}, nil
+}
+
+// parseKTX2 reads a KTX 2.0 file, supercompressed files (e.g. Basis Universal) are not supported
+func parseKTX2(data []byte) (*textureData, error) {
+	if len(data) < 80 || !bytes.Equal(data[:12], ktx2Identifier) {
+		return nil, fmt.Errorf("Invalid KTX2 header")
+	}
+
+	le := binary.LittleEndian
+	vkFormat := le.Uint32(data[12:])
+	width := int(le.Uint32(data[20:]))
+	height := int(le.Uint32(data[24:]))
+	depth := le.Uint32(data[28:])
+	layers := le.Uint32(data[32:])
+	faces := le.Uint32(data[36:])
+	mipCount := int(le.Uint32(data[40:]))
+	supercompression := le.Uint32(data[44:])
+
+	if depth > 0 || layers > 0 || faces > 1 {
+		return nil, fmt.Errorf("KTX2 arrays, cubemaps and volume textures are not supported")
+	}
+	if supercompression != 0 {
+		return nil, fmt.Errorf("KTX2 supercompression scheme %v is not supported", supercompression)
+	}
+	if mipCount == 0 {
+		mipCount = 1
+	}
+	if height == 0 {
+		height = 1
+	}
+	if err := checkTextureSize(width, height); err != nil {
+		return nil, err
+	}
+	if maxCount := maxMipCount(width, height); mipCount > maxCount {
+		mipCount = maxCount
+	}
+
+	format, ok := ktx2VkFormats[vkFormat]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported KTX2 VkFormat %v", vkFormat)
+	}
+
+	// The level index follows the header, with the base level first
+	if len(data) < 80+mipCount*24 {
+		return nil, fmt.Errorf("Truncated KTX2 level index")
+	}
+
+	levels := [][]byte{}
+	for i := 0; i < mipCount; i++ {
+		entry := data[80+i*24:]
+		offset := le.Uint64(entry[0:])
+		size := le.Uint64(entry[8:])
+		// Checked separately so a huge offset can't overflow past the end
+		if offset > uint64(len(data)) || size > uint64(len(data))-offset ||
+			size < uint64(format.levelSize(maxInt(1, width>>uint(i)), maxInt(1, height>>uint(i)))) {
+			return nil, fmt.Errorf("Truncated KTX2 mipmap level %v", i)
+		}
+		levels = append(levels, data[offset:offset+size])
+	}
+
+	return &textureData{
+		Format: format,
+		Width:  width,
+		Height: height,
+		Levels: levels,
+	}, nil
+}
+
+// swapBytes reverses the byte order of each element of the given size
+func swapBytes(data []byte, size int) []byte {
+	swapped := make([]byte, len(data))
+	for i := 0; i+size <= len(data); i += size {
+		for j := 0; j < size; j++ {
+			swapped[i+j] = data[i+size-j-1]
+		}
+	}
+	return swapped
+}
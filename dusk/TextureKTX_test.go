@@ -0,0 +1,82 @@
+package dusk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// ktxFile builds a KTX 1.1 file with one mipmap level
+func ktxFile(glType, glFormat, internalFormat uint32, width, height int, level []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(ktx1Identifier)
+	fields := []uint32{0x04030201, glType, 1, glFormat, internalFormat, glFormat, uint32(width), uint32(height), 0, 0, 1, 1, 0, uint32(len(level))}
+	binary.Write(&buf, binary.LittleEndian, fields)
+	buf.Write(level)
+	return buf.Bytes()
+}
+
+// ktx2File builds a KTX 2.0 file with one mipmap level
+func ktx2File(vkFormat uint32, width, height int, offset, size uint64, level []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(ktx2Identifier)
+	binary.Write(&buf, binary.LittleEndian, []uint32{vkFormat, 1, uint32(width), uint32(height), 0, 0, 1, 1, 0})
+	buf.Write(make([]byte, 80-buf.Len()))
+	binary.Write(&buf, binary.LittleEndian, []uint64{offset, size, size})
+	buf.Write(level)
+	return buf.Bytes()
+}
+
+func TestParseKTX(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Data  []byte
+		Error string
+	}{
+		{"RGBA8", ktxFile(gl.UNSIGNED_BYTE, gl.RGBA, gl.RGBA8, 4, 4, make([]byte, 64)), ""},
+		{"RGB8 padded rows", ktxFile(gl.UNSIGNED_BYTE, gl.RGB, gl.RGB8, 3, 2, make([]byte, 24)), ""},
+		{"Short RGBA8", ktxFile(gl.UNSIGNED_BYTE, gl.RGBA, gl.RGBA8, 4, 4, make([]byte, 4)), "Truncated"},
+		{"Short RGB8 rows", ktxFile(gl.UNSIGNED_BYTE, gl.RGB, gl.RGB8, 3, 2, make([]byte, 18)), "Truncated"},
+		{"BC1", ktxFile(0, 0, COMPRESSED_RGB_S3TC_DXT1, 8, 8, make([]byte, 32)), ""},
+		{"Short BC1", ktxFile(0, 0, COMPRESSED_RGB_S3TC_DXT1, 8, 8, make([]byte, 8)), "Truncated"},
+		{"Unknown type", ktxFile(0x1234, gl.RGBA, gl.RGBA8, 4, 4, make([]byte, 64)), "Unsupported"},
+		{"Zero width", ktxFile(gl.UNSIGNED_BYTE, gl.RGBA, gl.RGBA8, 0, 4, nil), "Invalid texture size"},
+	}
+
+	for _, test := range tests {
+		_, err := parseKTX(test.Data)
+		if test.Error == "" && err != nil {
+			t.Errorf("%v: %v", test.Name, err)
+		}
+		if test.Error != "" && (err == nil || !strings.Contains(err.Error(), test.Error)) {
+			t.Errorf("%v: expected error containing '%v', got %v", test.Name, test.Error, err)
+		}
+	}
+}
+
+func TestParseKTX2(t *testing.T) {
+	const levelOffset = 80 + 24
+	tests := []struct {
+		Name  string
+		Data  []byte
+		Error string
+	}{
+		{"RGBA8", ktx2File(37, 4, 4, levelOffset, 64, make([]byte, 64)), ""},
+		{"Short RGBA8", ktx2File(37, 4, 4, levelOffset, 4, make([]byte, 4)), "Truncated"},
+		{"Short BC7", ktx2File(145, 8, 8, levelOffset, 16, make([]byte, 16)), "Truncated"},
+		{"Overflowing offset", ktx2File(37, 1, 1, ^uint64(0)-2, 4, make([]byte, 4)), "Truncated"},
+	}
+
+	for _, test := range tests {
+		_, err := parseKTX2(test.Data)
+		if test.Error == "" && err != nil {
+			t.Errorf("%v: %v", test.Name, err)
+		}
+		if test.Error != "" && (err == nil || !strings.Contains(err.Error(), test.Error)) {
+			t.Errorf("%v: expected error containing '%v', got %v", test.Name, test.Error, err)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package dusk
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+type TextureMemoryUsage struct {
+	Used     int64
+	Budget   int64
+	Resident int
+	Loading  int
+	Evicted  int
+}
+
+// textureStream is the streaming state of a single Texture
+type textureStream struct {
+	streamer *TextureStreamer
+	filename string
+	options  TextureOptions
+	lastUsed uint64
+	loading  bool
+	evicted  bool
+
+	// Levels still waiting to be uploaded, smallest first, and the next level to upload
+	data      *textureData
+	nextLevel int
+}
+
+type textureStreamResult struct {
+	tex  *Texture
+	data *textureData
+	err  error
+}
+
+// TextureStreamer decodes textures in the background and uploads them a few mipmap levels per frame,
+// smallest first. When the GPU memory used goes over Budget, the least recently bound textures are
+// evicted and reloaded the next time they are bound.
+type TextureStreamer struct {
+	// Maximum bytes of texture memory, 0 for no limit
+	Budget int64
+
+	// Bytes uploaded per frame, at least one mipmap level is always uploaded
+	UploadBytesPerFrame int64
+
+	app         *App
+	textures    []*Texture
+	uploads     []*Texture
+	results     chan textureStreamResult
+	done        chan struct{}
+	used        int64
+	frame       uint64
+	placeholder uint32
+	update      func(data interface{})
+}
+
+func NewTextureStreamer(app *App, budget int64) *TextureStreamer {
+	streamer := &TextureStreamer{
+		Budget:              budget,
+		UploadBytesPerFrame: 4 * 1024 * 1024,
+		app:                 app,
+		results:             make(chan textureStreamResult, 64),
+		done:                make(chan struct{}),
+	}
+
+	// Textures show a single grey pixel until their first level arrives
+	grey := []byte{128, 128, 128, 255}
+	gl.GenTextures(1, &streamer.placeholder)
+	gl.BindTexture(gl.TEXTURE_2D, streamer.placeholder)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, 1, 1, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(grey))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+
+	streamer.update = func(data interface{}) {
+		streamer.Update()
+	}
+	app.EvtUpdate.Subscribe(&streamer.update)
+
+	return streamer
+}
+
+func (streamer *TextureStreamer) Cleanup() {
+	streamer.app.EvtUpdate.Unsubscribe(&streamer.update)
+
+	// Decodes still running drop their results instead of waiting for an Update that won't come
+	close(streamer.done)
+
+	for _, tex := range streamer.textures {
+		if tex.glId != streamer.placeholder {
+			gl.DeleteTextures(1, &tex.glId)
+		}
+		tex.glId = 0
+		tex.stream = nil
+	}
+	streamer.textures = nil
+	streamer.uploads = nil
+
+	gl.DeleteTextures(1, &streamer.placeholder)
+}
+
+// Load returns a Texture immediately, which is filled in over the following frames. The file is read
+// and its header parsed right away, so Size is correct before the image is decoded.
+func (streamer *TextureStreamer) Load(filename string, opts TextureOptions) (*Texture, error) {
+	LogLoad("Texture '%v' (streamed)", filename)
+
+	data, err := streamer.app.AssetFunction(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load texture '%v': %v", filename, err)
+	}
+	width, height, err := parseTextureSize(filename, data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load texture '%v': %v", filename, err)
+	}
+
+	tex := &Texture{
+		glId:    streamer.placeholder,
+		options: opts,
+		width:   width,
+		height:  height,
+		stream: &textureStream{
+			streamer: streamer,
+			filename: filename,
+			options:  opts,
+			lastUsed: streamer.frame,
+		},
+	}
+
+	streamer.textures = append(streamer.textures, tex)
+	streamer.request(tex, data)
+
+	return tex, nil
+}
+
+// request decodes the texture in the background, reading its file again if data is nil
+func (streamer *TextureStreamer) request(tex *Texture, data []byte) {
+	stream := tex.stream
+	stream.loading = true
+
+	app := streamer.app
+	results := streamer.results
+	done := streamer.done
+
+	go func() {
+		var texData *textureData
+		var err error
+		if data != nil {
+			texData, err = parseTextureData(stream.filename, data)
+		} else {
+			texData, err = loadTextureData(app, stream.filename)
+		}
+		if err == nil {
+			// Build the mipmaps here, as they can't be generated until the whole image is uploaded
+			format := texData.Format
+			if stream.options.Mipmaps && len(texData.Levels) == 1 &&
+				!format.Compressed && format.Type == gl.UNSIGNED_BYTE && format.BlockBytes == 4 {
+				texData.Levels = generateMipmapsRGBA(texData.Levels[0], texData.Width, texData.Height)
+			}
+		}
+		select {
+		case results <- textureStreamResult{tex: tex, data: texData, err: err}:
+		case <-done:
+		}
+	}()
+}
+
+func (streamer *TextureStreamer) remove(tex *Texture) {
+	streamer.evict(tex)
+	for i := range streamer.textures {
+		if streamer.textures[i] == tex {
+			streamer.textures = append(streamer.textures[:i], streamer.textures[i+1:]...)
+			break
+		}
+	}
+	tex.stream = nil
+	tex.glId = 0
+}
+
+// touch marks the texture as used this frame, and reloads it if it was evicted
+func (stream *textureStream) touch(tex *Texture) {
+	stream.lastUsed = stream.streamer.frame
+	if stream.evicted && !stream.loading {
+		stream.evicted = false
+		stream.streamer.request(tex, nil)
+	}
+}
+
+// Update receives decoded textures, uploads pending levels, and evicts textures over the budget.
+// It is called from EvtUpdate.
+func (streamer *TextureStreamer) Update() {
+	streamer.frame++
+
+	for {
+		select {
+		case result := <-streamer.results:
+			streamer.receive(result)
+			continue
+		default:
+		}
+		break
+	}
+
+	uploaded := int64(0)
+	for len(streamer.uploads) > 0 && (uploaded == 0 || uploaded < streamer.UploadBytesPerFrame) {
+		tex := streamer.uploads[0]
+		uploaded += streamer.uploadNextLevel(tex)
+		if tex.stream == nil || tex.stream.data == nil {
+			streamer.uploads = streamer.uploads[1:]
+		}
+	}
+
+	if streamer.Budget > 0 && streamer.used > streamer.Budget {
+		streamer.evictOverBudget()
+	}
+}
+
+func (streamer *TextureStreamer) receive(result textureStreamResult) {
+	tex := result.tex
+	if tex.stream == nil {
+		// Cleaned up while loading
+		return
+	}
+
+	tex.stream.loading = false
+	if result.err != nil {
+		LogError("Failed to stream texture '%v': %v", tex.stream.filename, result.err)
+		return
+	}
+
+	format := result.data.Format
+	if tex.stream.options.SRGB {
+		format = format.withSRGB()
+	}
+	if !format.supported(streamer.app) {
+		LogError("Failed to stream texture '%v': format 0x%X is not supported", tex.stream.filename, format.InternalFormat)
+		return
+	}
+	result.data.Format = format
+
+	tex.format = format
+	tex.width = result.data.Width
+	tex.height = result.data.Height
+	tex.stream.data = result.data
+	tex.stream.nextLevel = len(result.data.Levels) - 1
+	streamer.uploads = append(streamer.uploads, tex)
+}
+
+// uploadNextLevel uploads the smallest level not yet on the GPU, returning the bytes uploaded
+func (streamer *TextureStreamer) uploadNextLevel(tex *Texture) int64 {
+	stream := tex.stream
+	if stream == nil || stream.data == nil {
+		return 0
+	}
+
+	texData := stream.data
+	level := stream.nextLevel
+	lastLevel := len(texData.Levels) - 1
+	format := texData.Format
+
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	// The first level uploaded allocates the whole chain in a new texture, which replaces the
+	// placeholder. The whole chain counts towards the budget from then on.
+	if level == lastLevel {
+		var glId uint32
+		gl.GenTextures(1, &glId)
+		gl.BindTexture(gl.TEXTURE_2D, glId)
+		memory := int64(0)
+		for i := range texData.Levels {
+			width, height := texData.levelSize(i)
+			uploadTextureLevel(format, i, width, height, nil)
+			memory += int64(format.levelSize(width, height))
+		}
+		tex.memory += memory
+		streamer.used += memory
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, int32(lastLevel))
+
+		opts := stream.options
+		opts.Mipmaps = lastLevel > 0
+		opts.apply(streamer.app, gl.TEXTURE_2D)
+		tex.options = opts
+
+		if tex.glId != streamer.placeholder {
+			gl.DeleteTextures(1, &tex.glId)
+		}
+		tex.glId = glId
+	} else {
+		gl.BindTexture(gl.TEXTURE_2D, tex.glId)
+	}
+
+	width, height := texData.levelSize(level)
+	data := texData.Levels[level]
+	if format.Compressed {
+		gl.CompressedTexSubImage2D(gl.TEXTURE_2D, int32(level), 0, 0,
+			int32(width), int32(height), format.InternalFormat, int32(len(data)), gl.Ptr(data))
+	} else {
+		gl.TexSubImage2D(gl.TEXTURE_2D, int32(level), 0, 0,
+			int32(width), int32(height), format.Format, format.Type, gl.Ptr(data))
+	}
+
+	// Sampling is limited to the levels that have arrived
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_BASE_LEVEL, int32(level))
+
+	stream.nextLevel--
+	if stream.nextLevel < 0 {
+		// Single level textures that want mipmaps generate them once complete
+		if lastLevel == 0 && stream.options.Mipmaps && !format.Compressed {
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, 1000)
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+			stream.options.apply(streamer.app, gl.TEXTURE_2D)
+			tex.options = stream.options
+			streamer.used += tex.memory / 3
+			tex.memory += tex.memory / 3
+		}
+		stream.data = nil
+	}
+
+	return int64(len(data))
+}
+
+// evictOverBudget frees the least recently used textures, sparing anything bound in the last frame
+func (streamer *TextureStreamer) evictOverBudget() {
+	candidates := []*Texture{}
+	for _, tex := range streamer.textures {
+		if tex.memory > 0 && tex.stream.lastUsed+1 < streamer.frame {
+			candidates = append(candidates, tex)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].stream.lastUsed < candidates[j].stream.lastUsed
+	})
+
+	for _, tex := range candidates {
+		if streamer.used <= streamer.Budget {
+			break
+		}
+		LogVerbose("Evicting texture '%v', %v", tex.stream.filename, formatBytes(tex.memory))
+		streamer.evict(tex)
+		tex.stream.evicted = true
+	}
+}
+
+func (streamer *TextureStreamer) evict(tex *Texture) {
+	if tex.glId != streamer.placeholder && tex.glId != 0 {
+		gl.DeleteTextures(1, &tex.glId)
+	}
+	tex.glId = streamer.placeholder
+	streamer.used -= tex.memory
+	tex.memory = 0
+
+	if tex.stream.data != nil {
+		tex.stream.data = nil
+		for i := range streamer.uploads {
+			if streamer.uploads[i] == tex {
+				streamer.uploads = append(streamer.uploads[:i], streamer.uploads[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (streamer *TextureStreamer) Usage() TextureMemoryUsage {
+	usage := TextureMemoryUsage{
+		Used:   streamer.used,
+		Budget: streamer.Budget,
+	}
+	for _, tex := range streamer.textures {
+		switch {
+		case tex.stream.loading || tex.stream.data != nil:
+			usage.Loading++
+		case tex.stream.evicted:
+			usage.Evicted++
+		case tex.memory > 0:
+			usage.Resident++
+		}
+	}
+	return usage
+}
+
+func (streamer *TextureStreamer) LogUsage() {
+	usage := streamer.Usage()
+	budget := "unlimited"
+	if usage.Budget > 0 {
+		budget = formatBytes(usage.Budget)
+	}
+	LogInfo("Texture Memory %v of %v, %v resident, %v loading, %v evicted",
+		formatBytes(usage.Used), budget, usage.Resident, usage.Loading, usage.Evicted)
+}
+
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1024*1024*1024:
+		return fmt.Sprintf("%.2f GiB", float64(bytes)/(1024*1024*1024))
+	case bytes >= 1024*1024:
+		return fmt.Sprintf("%.2f MiB", float64(bytes)/(1024*1024))
+	case bytes >= 1024:
+		return fmt.Sprintf("%.2f KiB", float64(bytes)/1024)
+	}
+	return fmt.Sprintf("%v B", bytes)
+}
+
+// generateMipmapsRGBA builds a full mipmap chain with a box filter
+func generateMipmapsRGBA(pix []byte, width, height int) [][]byte {
+	levels := [][]byte{pix}
+	for width > 1 || height > 1 {
+		newWidth, newHeight := maxInt(1, width/2), maxInt(1, height/2)
+		next := make([]byte, newWidth*newHeight*4)
+
+		for y := 0; y < newHeight; y++ {
+			y0 := minInt(y*2, height-1)
+			y1 := minInt(y*2+1, height-1)
+			for x := 0; x < newWidth; x++ {
+				x0 := minInt(x*2, width-1)
+				x1 := minInt(x*2+1, width-1)
+				for c := 0; c < 4; c++ {
+					sum := int(pix[(y0*width+x0)*4+c]) + int(pix[(y0*width+x1)*4+c]) +
+						int(pix[(y1*width+x0)*4+c]) + int(pix[(y1*width+x1)*4+c])
+					next[(y*newWidth+x)*4+c] = byte((sum + 2) / 4)
+				}
+			}
+		}
+
+		levels = append(levels, next)
+		pix, width, height = next, newWidth, newHeight
+	}
+	return levels
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
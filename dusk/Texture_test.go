@@ -0,0 +1,42 @@
+package dusk
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+func TestParseTextureSize(t *testing.T) {
+	var pngData bytes.Buffer
+	png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 5, 3)))
+
+	tests := []struct {
+		Filename string
+		Data     []byte
+		Width    int
+		Height   int
+	}{
+		{"a.png", pngData.Bytes(), 5, 3},
+		{"a.ktx", ktxFile(gl.UNSIGNED_BYTE, gl.RGBA, gl.RGBA8, 7, 2, nil), 7, 2},
+		{"a.ktx2", ktx2File(37, 6, 4, 0, 0, nil), 6, 4},
+		{"a.hdr", []byte("#?RADIANCE\n\n-Y 9 +X 11\n"), 11, 9},
+	}
+
+	for _, test := range tests {
+		width, height, err := parseTextureSize(test.Filename, test.Data)
+		if err != nil {
+			t.Errorf("%v: %v", test.Filename, err)
+			continue
+		}
+		if width != test.Width || height != test.Height {
+			t.Errorf("%v: size %vx%v, expected %vx%v", test.Filename, width, height, test.Width, test.Height)
+		}
+	}
+
+	if _, _, err := parseTextureSize("a.png", []byte("not a png")); err == nil {
+		t.Errorf("Expected an error for an invalid PNG")
+	}
+}
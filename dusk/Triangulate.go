@@ -0,0 +1,145 @@
+package dusk
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// triangulator keeps its buffers between polygons, so loaders don't allocate per face
+type triangulator struct {
+	flat      []mgl32.Vec2
+	remaining []int
+	triangles [][3]int
+}
+
+// triangulatePolygon splits a polygon into triangles by ear clipping, returning indices into points.
+// Points are projected onto the polygon's plane first, so non-planar and concave polygons work.
+// If ear clipping fails, e.g. for self-intersecting polygons, it falls back to a fan.
+func triangulatePolygon(points []mgl32.Vec3) [][3]int {
+	return (&triangulator{}).Triangulate(points)
+}
+
+// Triangulate is triangulatePolygon, the result is only valid until the next call
+func (t *triangulator) Triangulate(points []mgl32.Vec3) [][3]int {
+	count := len(points)
+	t.triangles = t.triangles[:0]
+	if count < 3 {
+		return nil
+	}
+	if count == 3 {
+		t.triangles = append(t.triangles, [3]int{0, 1, 2})
+		return t.triangles
+	}
+
+	// Newell's method gives a robust normal for any simple polygon
+	normal := mgl32.Vec3{}
+	for i := range points {
+		cur := points[i]
+		next := points[(i+1)%count]
+		normal[0] += (cur[1] - next[1]) * (cur[2] + next[2])
+		normal[1] += (cur[2] - next[2]) * (cur[0] + next[0])
+		normal[2] += (cur[0] - next[0]) * (cur[1] + next[1])
+	}
+
+	// Drop the axis the normal points along the most
+	axisU, axisV := 0, 1
+	absX, absY, absZ := abs32(normal[0]), abs32(normal[1]), abs32(normal[2])
+	if absX >= absY && absX >= absZ {
+		axisU, axisV = 1, 2
+	} else if absY >= absZ {
+		axisU, axisV = 2, 0
+	}
+
+	flat := t.flat[:0]
+	for _, p := range points {
+		flat = append(flat, mgl32.Vec2{p[axisU], p[axisV]})
+	}
+	t.flat = flat
+
+	// Make the winding counter-clockwise in the projected plane
+	area := float32(0)
+	for i := range flat {
+		cur := flat[i]
+		next := flat[(i+1)%count]
+		area += cur[0]*next[1] - next[0]*cur[1]
+	}
+
+	remaining := t.remaining[:0]
+	for i := 0; i < count; i++ {
+		remaining = append(remaining, i)
+	}
+	t.remaining = remaining
+	if area < 0 {
+		for i, j := 0, count-1; i < j; i, j = i+1, j-1 {
+			remaining[i], remaining[j] = remaining[j], remaining[i]
+		}
+	}
+
+	triangles := t.triangles
+
+	for len(remaining) > 3 {
+		found := false
+		for i := range remaining {
+			prev := remaining[(i+len(remaining)-1)%len(remaining)]
+			cur := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+
+			if cross2(flat[prev], flat[cur], flat[next]) <= 0 {
+				continue
+			}
+
+			// An ear can't contain any other remaining point
+			ear := true
+			for _, other := range remaining {
+				if other == prev || other == cur || other == next {
+					continue
+				}
+				if pointInTriangle(flat[other], flat[prev], flat[cur], flat[next]) {
+					ear = false
+					break
+				}
+			}
+			if !ear {
+				continue
+			}
+
+			triangles = append(triangles, [3]int{prev, cur, next})
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			found = true
+			break
+		}
+
+		if !found {
+			t.triangles = triangles[:0]
+			for i := 1; i < count-1; i++ {
+				t.triangles = append(t.triangles, [3]int{0, i, i + 1})
+			}
+			return t.triangles
+		}
+	}
+	triangles = append(triangles, [3]int{remaining[0], remaining[1], remaining[2]})
+
+	// Restore the original winding
+	if area < 0 {
+		for i := range triangles {
+			triangles[i][1], triangles[i][2] = triangles[i][2], triangles[i][1]
+		}
+	}
+
+	t.triangles = triangles
+	return triangles
+}
+
+func cross2(a, b, c mgl32.Vec2) float32 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func pointInTriangle(p, a, b, c mgl32.Vec2) bool {
+	return cross2(a, b, p) >= 0 && cross2(b, c, p) >= 0 && cross2(c, a, p) >= 0
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,177 @@
+package dusk
+
+import (
+	"math"
+)
+
+// Tuning values from Tom Forsyth's "Linear-Speed Vertex Cache Optimisation"
+const (
+	vcacheSize            = 32
+	vcacheDecayPower      = 1.5
+	vcacheLastTriScore    = 0.75
+	vcacheValenceScale    = 2.0
+	vcacheValencePower    = 0.5
+	vcacheMaxValenceScore = 64
+)
+
+// vcacheVertex tracks a vertex during optimization
+type vcacheVertex struct {
+	cachePos  int
+	score     float32
+	remaining int
+	triStart  int
+	triCount  int
+}
+
+// vcacheScore returns the score of a vertex given its position in the simulated cache and the
+// number of triangles still using it
+func vcacheScore(cachePos, remaining int) float32 {
+	if remaining == 0 {
+		return -1
+	}
+
+	score := float32(0)
+	if cachePos >= 0 {
+		if cachePos < 3 {
+			// The last triangle's vertices get a fixed score so it is not immediately reused
+			score = vcacheLastTriScore
+		} else {
+			scale := 1 / float64(vcacheSize-3)
+			score = float32(math.Pow(1-float64(cachePos-3)*scale, vcacheDecayPower))
+		}
+	}
+
+	// Favor vertices with few remaining triangles, to get rid of lone triangles
+	if remaining > vcacheMaxValenceScore {
+		remaining = vcacheMaxValenceScore
+	}
+	score += vcacheValenceScale * float32(math.Pow(float64(remaining), -vcacheValencePower))
+	return score
+}
+
+// optimizeVertexCache reorders a triangle list in place so that vertices are reused while they
+// are still in the GPU's post-transform cache. Vertex indices must be in [base, base+count).
+// It returns the original index of each triangle in the new order, or nil if nothing moved.
+func optimizeVertexCache(indices []uint32, base, count int) []int {
+	triCount := len(indices) / 3
+	if triCount < 2 || count == 0 {
+		return nil
+	}
+
+	verts := make([]vcacheVertex, count)
+	for _, index := range indices {
+		verts[int(index)-base].triCount++
+	}
+
+	// Build the per-vertex triangle lists in one array
+	start := 0
+	for v := range verts {
+		verts[v].cachePos = -1
+		verts[v].triStart = start
+		verts[v].remaining = verts[v].triCount
+		start += verts[v].triCount
+		verts[v].triCount = 0
+	}
+	vertTris := make([]int, start)
+	for t := 0; t < triCount; t++ {
+		for i := 0; i < 3; i++ {
+			vert := &verts[int(indices[t*3+i])-base]
+			vertTris[vert.triStart+vert.triCount] = t
+			vert.triCount++
+		}
+	}
+
+	for v := range verts {
+		verts[v].score = vcacheScore(-1, verts[v].remaining)
+	}
+
+	triAdded := make([]bool, triCount)
+	triScore := func(t int) float32 {
+		return verts[int(indices[t*3])-base].score +
+			verts[int(indices[t*3+1])-base].score +
+			verts[int(indices[t*3+2])-base].score
+	}
+
+	output := make([]uint32, 0, len(indices))
+	order := make([]int, 0, triCount)
+	cache := make([]int, 0, vcacheSize+3)
+	newCache := make([]int, 0, vcacheSize+3)
+
+	bestTri := -1
+	bestScore := float32(-1)
+	for t := 0; t < triCount; t++ {
+		if score := triScore(t); score > bestScore {
+			bestTri, bestScore = t, score
+		}
+	}
+
+	nextTri := 0
+	for len(output) < len(indices) {
+		if bestTri < 0 {
+			// Nothing in the cache touches a remaining triangle, take the next one in order
+			for triAdded[nextTri] {
+				nextTri++
+			}
+			bestTri = nextTri
+		}
+
+		triAdded[bestTri] = true
+		order = append(order, bestTri)
+		tri := indices[bestTri*3 : bestTri*3+3]
+		output = append(output, tri[0], tri[1], tri[2])
+
+		// Move the triangle's vertices to the front of the cache
+		newCache = newCache[:0]
+		for i := 0; i < 3; i++ {
+			v := int(tri[i]) - base
+			newCache = append(newCache, v)
+
+			// Remove the triangle from the vertex's list of remaining triangles
+			vert := &verts[v]
+			list := vertTris[vert.triStart : vert.triStart+vert.remaining]
+			for j, t := range list {
+				if t == bestTri {
+					list[j] = list[len(list)-1]
+					break
+				}
+			}
+			vert.remaining--
+		}
+		for _, v := range cache {
+			if v != newCache[0] && v != newCache[1] && v != newCache[2] {
+				newCache = append(newCache, v)
+			}
+		}
+		cache, newCache = newCache, cache
+
+		// Update the scores of vertices in and falling out of the cache
+		for pos, v := range cache {
+			vert := &verts[v]
+			if pos < vcacheSize {
+				vert.cachePos = pos
+			} else {
+				vert.cachePos = -1
+			}
+			vert.score = vcacheScore(vert.cachePos, vert.remaining)
+		}
+
+		// Rescore the triangles touching the cache, and find the best one
+		bestTri = -1
+		bestScore = -1
+		for _, v := range cache {
+			vert := &verts[v]
+			for _, t := range vertTris[vert.triStart : vert.triStart+vert.remaining] {
+				if score := triScore(t); score > bestScore {
+					bestTri, bestScore = t, score
+				}
+			}
+		}
+
+		if len(cache) > vcacheSize {
+			cache = cache[:vcacheSize]
+		}
+	}
+
+	copy(indices, output)
+	return order
+}
@@ -1,9 +1,26 @@
 #version 330 core
 
-const uint AMBIENT_MAP_FLAG  = 1u; // 00001
-const uint DIFFUSE_MAP_FLAG  = 2u; // 00010
-const uint SPECULAR_MAP_FLAG = 4u; // 00100
-const uint BUMP_MAP_FLAG     = 8u; // 01000
+const uint AMBIENT_MAP_FLAG   = 1u;   // 00000001
+const uint DIFFUSE_MAP_FLAG   = 2u;   // 00000010
+const uint SPECULAR_MAP_FLAG  = 4u;   // 00000100
+const uint BUMP_MAP_FLAG      = 8u;   // 00001000
+const uint ALPHA_MAP_FLAG     = 16u;  // 00010000
+const uint EMISSIVE_MAP_FLAG  = 32u;  // 00100000
+const uint NORMAL_MAP_FLAG    = 64u;  // 01000000
+const uint SHININESS_MAP_FLAG = 128u; // 10000000
+const uint METALLIC_MAP_FLAG  = 256u;
+const uint OCCLUSION_MAP_FLAG = 512u;
+
+const int AMBIENT_TEXID   = 0;
+const int DIFFUSE_TEXID   = 1;
+const int SPECULAR_TEXID  = 2;
+const int BUMP_TEXID      = 3;
+const int ALPHA_TEXID     = 4;
+const int EMISSIVE_TEXID  = 5;
+const int NORMAL_TEXID    = 6;
+const int SHININESS_TEXID = 7;
+const int METALLIC_TEXID  = 8;
+const int OCCLUSION_TEXID = 9;
 
 uniform mat4 _Model;
 uniform mat4 _View;
@@ -13,15 +30,32 @@ uniform mat4 _MVP;
 uniform vec3 _Ambient;
 uniform vec3 _Diffuse;
 uniform vec3 _Specular;
+uniform vec3 _Emissive;
 
 uniform float _Shininess;
 uniform float _Dissolve;
+uniform float _IOR;
+uniform int _Illum;
+uniform float _BumpMultiplier;
+uniform float _AlphaCutoff;
+uniform float _Metallic;
+uniform float _Roughness;
+uniform vec4 _AlphaChannel;
+
+// Scale in xy and offset in zw for each map
+uniform vec4 _MapTransforms[10];
 
 uniform uint _MapFlags;
 uniform sampler2D _AmbientMap;
 uniform sampler2D _DiffuseMap;
 uniform sampler2D _SpecularMap;
 uniform sampler2D _BumpMap;
+uniform sampler2D _AlphaMap;
+uniform sampler2D _EmissiveMap;
+uniform sampler2D _NormalMap;
+uniform sampler2D _ShininessMap;
+uniform sampler2D _MetallicRoughnessMap;
+uniform sampler2D _OcclusionMap;
 
 in vec3 p_LightDir;
 in vec3 p_ViewDir;
@@ -29,40 +63,132 @@ in vec3 p_ViewDir;
 in vec4 p_Vertex;
 in vec4 p_Normal;
 in vec2 p_TexCoord;
+in vec4 p_Color;
+in vec4 p_Tangent;
 
 out vec4 o_Color;
 
+vec2 mapCoord(int id) {
+    return p_TexCoord * _MapTransforms[id].xy + _MapTransforms[id].zw;
+}
+
+// Moves a tangent space normal from a map into world space. Without tangents the map is used
+// as a model space normal.
+vec4 mapNormal(vec3 n, vec4 normal) {
+    n.xy *= _BumpMultiplier;
+
+    vec3 tangent = p_Tangent.xyz - normal.xyz * dot(normal.xyz, p_Tangent.xyz);
+    if (dot(tangent, tangent) < 1e-8) {
+        return normalize(_Model * vec4(n, 0.0));
+    }
+
+    tangent = normalize(tangent);
+    vec3 bitangent = cross(normal.xyz, tangent) * p_Tangent.w;
+    return vec4(normalize(mat3(tangent, bitangent, normal.xyz) * n), 0.0);
+}
+
 void main() {
     vec4 normal = normalize(p_Normal);
 
-    if ((_MapFlags & BUMP_MAP_FLAG) > 0u) {
-        normal = _Model * (texture(_BumpMap, p_TexCoord).rgba * 2.0 - 1.0);
+    if ((_MapFlags & NORMAL_MAP_FLAG) > 0u) {
+        vec3 n = texture(_NormalMap, mapCoord(NORMAL_TEXID)).rgb * 2.0 - 1.0;
+        normal = mapNormal(n, normal);
+    }
+    else if ((_MapFlags & BUMP_MAP_FLAG) > 0u) {
+        vec3 n = texture(_BumpMap, mapCoord(BUMP_TEXID)).rgb * 2.0 - 1.0;
+        normal = mapNormal(n, normal);
     }
 
+    // Vertex colors tint the ambient and diffuse colors
     vec3 ambient = _Ambient;
 
     if ((_MapFlags & AMBIENT_MAP_FLAG) > 0u) {
-        ambient = texture(_AmbientMap, p_TexCoord).rgb;
+        ambient = texture(_AmbientMap, mapCoord(AMBIENT_TEXID)).rgb;
     }
 
-    float diffuseMult = max(0.0, dot(normal.xyz, p_LightDir));
-    vec3 diffuse = diffuseMult * _Diffuse;
+    ambient *= p_Color.rgb;
+
+    vec3 diffuseColor = _Diffuse;
 
     if ((_MapFlags & DIFFUSE_MAP_FLAG) > 0u) {
-        diffuse = diffuseMult * texture(_DiffuseMap, p_TexCoord).rgb;
+        diffuseColor = texture(_DiffuseMap, mapCoord(DIFFUSE_TEXID)).rgb;
+    }
+
+    diffuseColor *= p_Color.rgb;
+
+    vec3 emissive = _Emissive;
+
+    if ((_MapFlags & EMISSIVE_MAP_FLAG) > 0u) {
+        emissive = texture(_EmissiveMap, mapCoord(EMISSIVE_TEXID)).rgb;
+    }
+
+    float alpha = _Dissolve * p_Color.a;
+
+    if ((_MapFlags & ALPHA_MAP_FLAG) > 0u) {
+        alpha *= dot(texture(_AlphaMap, mapCoord(ALPHA_TEXID)), _AlphaChannel);
+    }
+
+    if (_AlphaCutoff > 0.0 && alpha < _AlphaCutoff) {
+        discard;
+    }
+
+    if ((_MapFlags & OCCLUSION_MAP_FLAG) > 0u) {
+        ambient *= texture(_OcclusionMap, mapCoord(OCCLUSION_TEXID)).r;
     }
 
-    vec3 halfwayDir = normalize(p_LightDir + p_ViewDir);
-    float specularMult = max(0.0, dot(normal.xyz, halfwayDir));
-    if (_Shininess > 0) {
-        specularMult = pow(specularMult, _Shininess);
+    // Roughness is in the green channel and metallic in the blue channel, as in glTF
+    float metallic = _Metallic;
+    float roughness = _Roughness;
+
+    if ((_MapFlags & METALLIC_MAP_FLAG) > 0u) {
+        vec4 metallicRoughness = texture(_MetallicRoughnessMap, mapCoord(METALLIC_TEXID));
+        roughness *= metallicRoughness.g;
+        metallic *= metallicRoughness.b;
+    }
+
+    // Materials without metallic-roughness parameters keep their Phong specular color and shininess
+    bool isMetallicRoughness = metallic > 0.0 || (_MapFlags & METALLIC_MAP_FLAG) > 0u;
+
+    // Illumination model 0 is a constant color
+    if (_Illum == 0) {
+        o_Color = vec4(diffuseColor + emissive, alpha);
+        return;
     }
 
-    vec3 specular = vec3(specularMult);
+    float diffuseMult = max(0.0, dot(normal.xyz, p_LightDir));
+    vec3 diffuse = diffuseMult * diffuseColor * (1.0 - metallic);
+
+    vec3 specular = vec3(0.0);
+
+    // Illumination model 1 has no highlights
+    if (_Illum > 1) {
+        float shininess = _Shininess;
+
+        if ((_MapFlags & SHININESS_MAP_FLAG) > 0u) {
+            shininess *= texture(_ShininessMap, mapCoord(SHININESS_TEXID)).r;
+        }
+
+        // Dielectrics reflect about 4%, metals reflect their base color
+        vec3 specularColor = _Specular;
+
+        if (isMetallicRoughness) {
+            float r4 = roughness * roughness * roughness * roughness;
+            shininess = clamp(2.0 / max(r4, 1e-4) - 2.0, 1.0, 1024.0);
+            specularColor = mix(vec3(0.04), diffuseColor, metallic);
+        }
+
+        vec3 halfwayDir = normalize(p_LightDir + p_ViewDir);
+        float specularMult = max(0.0, dot(normal.xyz, halfwayDir));
+        if (shininess > 0) {
+            specularMult = pow(specularMult, shininess);
+        }
+
+        specular = specularMult * specularColor;
 
-    if ((_MapFlags & SPECULAR_MAP_FLAG) > 0u) {
-        specular = specularMult * texture(_SpecularMap, p_TexCoord).rgb;
+        if ((_MapFlags & SPECULAR_MAP_FLAG) > 0u && !isMetallicRoughness) {
+            specular = specularMult * texture(_SpecularMap, mapCoord(SPECULAR_TEXID)).rgb;
+        }
     }
 
-    o_Color = vec4(ambient + diffuse + specular, 1.0);
+    o_Color = vec4(ambient + diffuse + specular + emissive, alpha);
 }
@@ -3,12 +3,29 @@
 layout(location = 0) in vec3 _Vertex;
 layout(location = 1) in vec3 _Normal;
 layout(location = 2) in vec2 _TexCoord;
+layout(location = 3) in vec4 _Color;
+layout(location = 4) in vec4 _Tangent;
+
+// Per-instance transform and color, the identity and white when not drawing instances
+layout(location = 5) in mat4 _Instance;
+layout(location = 9) in vec4 _InstanceColor;
+
+// Skin joints and their weights, zero when the model isn't skinned
+layout(location = 10) in vec4 _JointIndices;
+layout(location = 11) in vec4 _JointWeights;
 
 uniform mat4 _Model;
 uniform mat4 _View;
 uniform mat4 _Proj;
 uniform mat4 _MVP;
 
+// Transform of the model part being drawn, applied before _Model
+uniform mat4 _Part;
+
+// Joint matrices of the part being drawn, used when it's skinned
+uniform bool _Skinned;
+uniform mat4 _JointMatrices[64];
+
 uniform vec3 _LightPos;
 uniform vec3 _ViewPos;
 
@@ -18,14 +35,28 @@ out vec3 p_ViewDir;
 out vec4 p_Vertex;
 out vec4 p_Normal;
 out vec2 p_TexCoord;
+out vec4 p_Color;
+out vec4 p_Tangent;
 
 void main() {
-	p_Vertex = _Model * vec4(_Vertex, 1.0);
-	p_Normal = _Model * vec4(_Normal, 1.0);
+	mat4 skin = mat4(1.0);
+	if (_Skinned) {
+		skin = _JointWeights.x * _JointMatrices[int(_JointIndices.x)] +
+			_JointWeights.y * _JointMatrices[int(_JointIndices.y)] +
+			_JointWeights.z * _JointMatrices[int(_JointIndices.z)] +
+			_JointWeights.w * _JointMatrices[int(_JointIndices.w)];
+	}
+
+	mat4 model = _Model * _Instance * _Part * skin;
+
+	p_Vertex = model * vec4(_Vertex, 1.0);
+	p_Normal = model * vec4(_Normal, 0.0);
 	p_TexCoord = vec2(_TexCoord.x, 1.0 - _TexCoord.y);
+	p_Color = _Color * _InstanceColor;
+	p_Tangent = vec4((model * vec4(_Tangent.xyz, 0.0)).xyz, _Tangent.w);
 
     p_LightDir = normalize(_LightPos - p_Vertex.xyz);
     p_ViewDir = normalize(_ViewPos - p_Vertex.xyz);
 
-	gl_Position = _MVP * vec4(_Vertex, 1.0);
+	gl_Position = _MVP * _Instance * _Part * skin * vec4(_Vertex, 1.0);
 }
@@ -37,6 +37,7 @@ var render = func(data interface{}) {
 	gl.Uniform3fv(shader.GetUniformLocation("_LightPos"), 1, &eye[0])
 	gl.Uniform3fv(shader.GetUniformLocation("_ViewPos"), 1, &eye[0])
 
+	model.SelectLOD(camera)
 	model.Render(shader)
 }
 
@@ -74,5 +75,11 @@ func main() {
 	}
 	defer model.Cleanup()
 
+	err = model.GenerateLODs(0.5, 0.25, 0.1)
+	if err != nil {
+		dusk.LogError("%v", err)
+		return
+	}
+
 	app.Start()
 }